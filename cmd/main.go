@@ -3,12 +3,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	"github.com/nirmata/kyverno-mcp/pkg/history"
+	"github.com/nirmata/kyverno-mcp/pkg/metrics"
 	"github.com/nirmata/kyverno-mcp/pkg/tools"
+	"github.com/nirmata/kyverno-mcp/pkg/tracing"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -29,6 +36,28 @@ var tlsCert string
 // tlsKey specifies the path to the TLS key file.
 var tlsKey string
 
+// metricsAddr specifies the address the Prometheus metrics endpoint binds to; empty disables it.
+var metricsAddr string
+
+// otlpEndpoint specifies the OTLP/gRPC endpoint traces are exported to; empty disables tracing.
+var otlpEndpoint string
+
+// enablePprof exposes the net/http/pprof handlers under /debug/pprof/ on the HTTP and metrics servers.
+var enablePprof bool
+
+// historyDB specifies the database file scan summaries are recorded in; empty disables the history.
+var historyDB string
+
+// allowedNamespaces lists the namespaces tools are restricted to; empty allows every namespace.
+var allowedNamespaces string
+
+// tokenContexts specifies the file mapping the API tokens accepted by the HTTP server to cluster
+// credentials; empty accepts every request with the credentials of the server.
+var tokenContexts string
+
+// registryConfig specifies the directory holding the Docker config.json with registry credentials.
+var registryConfig string
+
 func init() {
 	flag.Usage = func() {
 		// Header
@@ -51,10 +80,14 @@ func init() {
 		}
 		msgs := []string{
 			"  list_contexts   – List all available Kubernetes contexts",
-			"  switch_context  – Switch to a different Kubernetes context (requires --context)",
+			"  switch_context  – Switch to a different Kubernetes context (requires --allow-writes)",
 			"  apply_policies  – Apply policies to a cluster",
 			"  help            – Get Kyverno documentation for installation and troubleshooting",
 			"  show_violations – Show violations for a given resource",
+			"  watch_violations – Send notifications when the violations of a namespace change",
+			"  session_defaults – Get or set the namespace, context, policy set and output format defaults of the session",
+			"  benchmark_policies – Measure per-rule evaluation latency to estimate admission overhead",
+			"  scan_history    – List and compare the summaries of previous scans (requires --history-db)",
 		}
 		for _, m := range msgs {
 			if _, err := fmt.Fprintln(flag.CommandLine.Output(), m); err != nil {
@@ -85,6 +118,54 @@ func main() {
 	if flag.Lookup("tls-key") == nil {
 		flag.StringVar(&tlsKey, "tls-key", "", "Path to the TLS key file to use. If not provided, defaults are used.")
 	}
+	if flag.Lookup("registry-config") == nil {
+		flag.StringVar(&registryConfig, "registry-config", "", "Directory containing a Docker config.json with the registry credentials used by image verification rules and OCI policy images. If not provided, ~/.docker is used.")
+	}
+	if flag.Lookup("metrics-addr") == nil {
+		flag.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics about tool calls on, at /metrics, e.g. \":9090\". If not provided, metrics are not served.")
+	}
+	if flag.Lookup("otlp-endpoint") == nil {
+		flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "URL of the OTLP/gRPC endpoint to export traces of tool calls to, e.g. \"http://otel-collector:4317\". If not provided, calls are not traced.")
+	}
+	if flag.Lookup("enable-pprof") == nil {
+		flag.BoolVar(&enablePprof, "enable-pprof", false, "Serve Go runtime profiles under /debug/pprof/ on the HTTP(S) server and the metrics server, to debug the CPU and memory usage of large scans. Profiles expose internals of the server, so only enable this on trusted networks.")
+	}
+	if flag.Lookup("list-page-size") == nil {
+		flag.Int64Var(&tools.ListPageSize, "list-page-size", tools.ListPageSize, "Maximum number of objects fetched from the API server per list request; larger collections are fetched in pages.")
+	}
+	if flag.Lookup("scan-cache-ttl") == nil {
+		flag.DurationVar(&tools.ScanCacheTTL, "scan-cache-ttl", tools.ScanCacheTTL, "How long apply_policies reuses the result of a cluster scan for calls scanning the same namespaces with the same policies, unless they set noCache. 0 disables the cache.")
+	}
+	if flag.Lookup("resource-cache") == nil {
+		flag.BoolVar(&tools.ResourceCache, "resource-cache", false, "Keep the resources matched by the policies of cluster scans in informers of the client session, so that repeated apply_policies calls of the session read them from memory instead of listing the cluster again. Cached resources are evaluated offline, like selected ones, so policies looking up other cluster objects need the cache disabled.")
+	}
+	if flag.Lookup("history-db") == nil {
+		flag.StringVar(&historyDB, "history-db", "", "Path of a database file recording the summary of every apply_policies scan, queried with the scan_history tool. If not provided, scans are not recorded.")
+	}
+	if flag.Lookup("max-result-bytes") == nil {
+		flag.IntVar(&tools.MaxResultBytes, "max-result-bytes", tools.MaxResultBytes, "Maximum size in bytes of the results returned by an apply_policies or show_violations call; further results are dropped with a truncation notice and a cursor to fetch them.")
+	}
+	if flag.Lookup("allow-writes") == nil {
+		flag.BoolVar(&tools.AllowWrites, "allow-writes", false, "Register the tools that modify cluster or kubeconfig state, such as switch_context. If not set, the server only reads.")
+	}
+	if flag.Lookup("allowed-namespaces") == nil {
+		flag.StringVar(&allowedNamespaces, "allowed-namespaces", "", "Comma-separated namespaces every tool is restricted to, whatever the namespace arguments of the calls, e.g. \"team-a,team-b\". namespace=\"all\" then selects these namespaces, and cluster-scoped resources and reports are left out. If not provided, every namespace is allowed.")
+	}
+	if flag.Lookup("token-contexts") == nil {
+		flag.StringVar(&tokenContexts, "token-contexts", "", "Path of a YAML file mapping API tokens to kubeconfig contexts or ServiceAccount tokens, so that a hosted HTTP(S) server serves several users each confined to their own cluster credentials. Requests must then present one of the tokens as \"Authorization: Bearer <token>\", and cannot impersonate other users, read files on the server or fetch policies from URLs. If not provided, every HTTP request uses the credentials of the server.")
+	}
+	if flag.Lookup("as") == nil {
+		flag.StringVar(&common.DefaultImpersonation.User, "as", "", "User to impersonate for every cluster request, like kubectl --as, so that scans only see what the user may read. Tool calls cannot select another user when set. If not provided, tool calls may impersonate a user with their as argument.")
+	}
+	if flag.Lookup("as-group") == nil {
+		flag.Func("as-group", "Group to impersonate together with --as, like kubectl --as-group. Can be repeated.", func(group string) error {
+			common.DefaultImpersonation.Groups = append(common.DefaultImpersonation.Groups, group)
+			return nil
+		})
+	}
+	if flag.Lookup("max-scan-resources") == nil {
+		flag.IntVar(&tools.MaxScanResources, "max-scan-resources", tools.MaxScanResources, "Maximum number of resources evaluated by a single apply_policies scan; the remaining namespaces are left out with a notice in the result. 0 disables the limit.")
+	}
 
 	// Parse CLI flags early so subsequent init can rely on them. Capture ErrHelp
 	if err := flag.CommandLine.Parse(os.Args[1:]); err == flag.ErrHelp {
@@ -105,6 +186,40 @@ func main() {
 		klog.InfoS("Using kubeconfig file: %s", kubeconfigPath)
 	}
 
+	if allowedNamespaces != "" {
+		common.AllowedNamespaces = common.ParseCommaSeparated(allowedNamespaces)
+		klog.InfoS("Restricting tools to namespaces", "namespaces", allowedNamespaces)
+	}
+
+	if tokenContexts != "" {
+		creds, err := common.LoadTokenCredentials(tokenContexts)
+		if err != nil {
+			klog.ErrorS(err, "failed to load token contexts", "path", tokenContexts)
+			klog.Flush()
+			os.Exit(1)
+		}
+		common.TokenCredentials = creds
+		klog.InfoS("Authenticating HTTP requests with API tokens", "path", tokenContexts, "tokens", len(creds))
+		if tlsCert == "" || tlsKey == "" {
+			klog.InfoS("API tokens are sent in clear text without --tls-cert and --tls-key")
+		}
+	}
+
+	if err := common.DefaultImpersonation.Validate(); err != nil {
+		klog.ErrorS(err, "invalid impersonation flags")
+		klog.Flush()
+		os.Exit(1)
+	}
+	if !common.DefaultImpersonation.IsZero() {
+		klog.InfoS("Impersonating for cluster requests", "user", common.DefaultImpersonation.User, "groups", common.DefaultImpersonation.Groups)
+	}
+
+	if registryConfig != "" {
+		// The Kyverno registry client and the OCI policy loader read credentials from DOCKER_CONFIG
+		_ = os.Setenv("DOCKER_CONFIG", registryConfig)
+		klog.InfoS("Using registry credentials", "dockerConfig", registryConfig)
+	}
+
 	// Setup logging to standard output
 	klog.SetOutput(os.Stderr)
 	klog.Info("Logging initialized to Stdout.")
@@ -114,12 +229,52 @@ func main() {
 	klog.Info("kyverno-mcp: ")
 	klog.Info("Starting Kyverno MCP server...")
 
+	if otlpEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), otlpEndpoint)
+		if err != nil {
+			klog.ErrorS(err, "failed to set up tracing")
+		} else {
+			klog.InfoS("Exporting traces", "endpoint", otlpEndpoint)
+			defer func() {
+				if err := shutdown(context.Background()); err != nil {
+					klog.ErrorS(err, "failed to flush traces")
+				}
+			}()
+		}
+	}
+
+	defer tools.RemoveCachedPolicies()
+
+	if historyDB != "" {
+		if err := history.Open(historyDB); err != nil {
+			klog.ErrorS(err, "failed to open scan history", "path", historyDB)
+		} else {
+			klog.InfoS("Recording scan history", "path", historyDB)
+			defer func() {
+				if err := history.Close(); err != nil {
+					klog.ErrorS(err, "failed to close scan history")
+				}
+			}()
+		}
+	}
+
 	// Create a new MCP server
 	klog.InfoS("Creating new MCP server instance...")
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(tools.StopViolationWatches)
+	hooks.AddOnUnregisterSession(tools.ForgetSessionDefaults)
+	hooks.AddOnUnregisterSession(tools.StopResourceCaches)
 	s := server.NewMCPServer(
 		"Kyverno MCP Server",
 		"1.0.0",
 		server.WithToolCapabilities(false),
+		server.WithPromptCapabilities(false),
+		server.WithLogging(),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(tracing.ToolMiddleware),
+		server.WithToolHandlerMiddleware(metrics.ToolMiddleware),
+		server.WithToolHandlerMiddleware(tools.SessionDefaultsMiddleware),
+		server.WithToolHandlerMiddleware(tools.ImpersonationMiddleware),
 		server.WithRecovery(),
 	)
 	klog.Info("MCP server instance created.")
@@ -130,6 +285,24 @@ func main() {
 	tools.ApplyPolicies(s)
 	tools.Help(s)
 	tools.ShowViolations(s)
+	tools.WatchViolations(s)
+	tools.SessionDefaults(s)
+	tools.BenchmarkPolicies(s)
+	tools.Prompts(s)
+	if history.Enabled() {
+		tools.ScanHistory(s)
+	}
+
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		klog.InfoS("Starting metrics server", "addr", metricsAddr)
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, withPprof(mux)); err != nil && err != http.ErrServerClosed {
+				klog.ErrorS(err, "metrics server terminated with error")
+			}
+		}()
+	}
 
 	// Prefer HTTPS when TLS credentials are supplied. If not, fall back to plain HTTP.
 	if tlsCert != "" && tlsKey != "" {
@@ -145,7 +318,7 @@ func main() {
 		// net/http server configuration (HTTPS)
 		httpServer := &http.Server{
 			Addr:    addr,
-			Handler: streamSrv,
+			Handler: withTokenAuth(withPprof(streamSrv)),
 		}
 
 		klog.InfoS("Starting Streamable HTTPS server", "addr", addr, "tlsCert", tlsCert, "tlsKey", tlsKey)
@@ -174,7 +347,7 @@ func main() {
 		// net/http server configuration (HTTP)
 		httpServer := &http.Server{
 			Addr:    httpAddr,
-			Handler: streamSrv,
+			Handler: withTokenAuth(withPprof(streamSrv)),
 		}
 
 		klog.InfoS("Starting Streamable HTTP server", "addr", httpAddr)
@@ -221,3 +394,39 @@ func main() {
 		klog.Info("Termination signal received. Exiting.")
 	}
 }
+
+// withTokenAuth confines each HTTP request to the credentials of the API token it presents as
+// "Authorization: Bearer <token>", and rejects requests without a known token, when
+// --token-contexts is set.
+func withTokenAuth(handler http.Handler) http.Handler {
+	if len(common.TokenCredentials) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		creds, known := common.CredentialsForToken(strings.TrimSpace(token))
+		if !ok || !known {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kyverno-mcp"`)
+			http.Error(w, "a valid API token is required", http.StatusUnauthorized)
+			return
+		}
+		klog.V(4).InfoS("Authenticated HTTP request", "credentials", creds.Name, "context", creds.Context)
+		handler.ServeHTTP(w, r.WithContext(common.WithCredentials(r.Context(), creds)))
+	})
+}
+
+// withPprof serves the net/http/pprof handlers under /debug/pprof/ in front of handler when
+// --enable-pprof is set, and returns handler unchanged otherwise.
+func withPprof(handler http.Handler) http.Handler {
+	if !enablePprof {
+		return handler
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/", handler)
+	return mux
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// discoveryTTL bounds how long cached discovery information is used before it is fetched again,
+// so that CRDs installed or removed in the meantime are eventually noticed.
+const discoveryTTL = 10 * time.Minute
+
+// ClusterClients holds the clients of one kubeconfig context. They are safe for concurrent use
+// and shared by all tools, so that connections and TLS sessions are reused across calls.
+type ClusterClients struct {
+	// Config is the configuration the clients were built from.
+	Config *rest.Config
+	// Kubernetes is the typed client of the built-in APIs.
+	Kubernetes kubernetes.Interface
+	// Dynamic is the client of arbitrary resources, such as Kyverno policies and policy reports.
+	Dynamic dynamic.Interface
+	// Discovery caches the API groups and resources served by the cluster.
+	Discovery discovery.CachedDiscoveryInterface
+	// Mapper maps kinds to resources using Discovery; a kind that is not found, such as a newly
+	// installed CRD, refreshes the cached discovery information.
+	Mapper meta.ResettableRESTMapper
+
+	discoveredAt time.Time
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*ClusterClients{}
+)
+
+// Clients returns the clients of the kubeconfig context selected with WithKubeContext, or of the
+// current context, with the credentials selected with WithCredentials and making requests as the
+// user selected with WithImpersonation. Clients are built once per credentials, context and
+// impersonation; the discovery cache expires after discoveryTTL, and everything is dropped by
+// InvalidateClients.
+func Clients(ctx context.Context) (*ClusterClients, error) {
+	name := KubeContext(ctx)
+	if i := ImpersonationOf(ctx); !i.IsZero() {
+		name += "\x00" + i.String()
+	}
+	if c, ok := CredentialsOf(ctx); ok {
+		name = c.Name + "\x01" + name
+	}
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[name]; ok {
+		if time.Since(c.discoveredAt) > discoveryTTL {
+			c.Discovery.Invalidate()
+			c.Mapper.Reset()
+			c.discoveredAt = time.Now()
+		}
+		return c, nil
+	}
+
+	cfg, err := KubeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	typed, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	disc := memory.NewMemCacheClient(typed.Discovery())
+	c := &ClusterClients{
+		Config:       cfg,
+		Kubernetes:   typed,
+		Dynamic:      dyn,
+		Discovery:    disc,
+		Mapper:       restmapper.NewDeferredDiscoveryRESTMapper(disc),
+		discoveredAt: time.Now(),
+	}
+	clients[name] = c
+	return c, nil
+}
+
+// InvalidateClients drops the clients of every context. It is called when the current
+// kubeconfig context changes, since the clients of the current context then target another
+// cluster or use other credentials.
+func InvalidateClients() {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients = map[string]*ClusterClients{}
+}
@@ -2,27 +2,104 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
+	"sort"
 	"strings"
 
+	"github.com/nirmata/kyverno-mcp/pkg/tracing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// KubeConfig returns InCluster config or falls back to ~/.kube/config.
-func KubeConfig() (*rest.Config, error) {
+// kubeContextKey is the context.Context key of the kubeconfig context selected for a call.
+type kubeContextKey struct{}
+
+// WithKubeContext returns a copy of ctx selecting the named kubeconfig context for the cluster
+// clients built from it. An empty name keeps the default behaviour. It has no effect when ctx
+// carries the credentials of an API token, which select the context themselves.
+func WithKubeContext(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, kubeContextKey{}, name)
+}
+
+// KubeContext returns the kubeconfig context of the credentials selected with WithCredentials,
+// or else the one selected with WithKubeContext, or "" for the current context.
+func KubeContext(ctx context.Context) string {
+	if c, ok := CredentialsOf(ctx); ok {
+		return c.Context
+	}
+	name, _ := ctx.Value(kubeContextKey{}).(string)
+	return name
+}
+
+// KubeConfig returns InCluster config or falls back to ~/.kube/config. When a kubeconfig context
+// was selected with WithKubeContext, that context of the kubeconfig is used instead. Requests
+// made with the config are traced, authenticated with the ServiceAccount token of the
+// credentials selected with WithCredentials, and made as the user selected with WithImpersonation.
+func KubeConfig(ctx context.Context) (*rest.Config, error) {
+	cfg, err := kubeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg = withServiceAccountToken(ctx, cfg)
+	impersonate(ctx, cfg)
+	cfg.Wrap(tracing.Transport)
+	return cfg, nil
+}
+
+func kubeConfig(ctx context.Context) (*rest.Config, error) {
+	if name := KubeContext(ctx); name != "" {
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{CurrentContext: name},
+		).ClientConfig()
+	}
 	if cfg, err := rest.InClusterConfig(); err == nil {
 		return cfg, nil
 	}
 	return clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
 }
 
+// ListNamespaces returns the sorted names of all namespaces in the cluster.
+func ListNamespaces(ctx context.Context) ([]string, error) {
+	return ListNamespacesMatching(ctx, "")
+}
+
+// ListNamespacesMatching returns the sorted names of the namespaces matching a label selector.
+// An empty selector matches every namespace.
+func ListNamespacesMatching(ctx context.Context, selector string) ([]string, error) {
+	c, err := Clients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 // ParseNamespaceExcludes builds a set from a comma-separated string.
 func ParseNamespaceExcludes(s string) map[string]struct{} {
+	return ParseCommaSeparated(s)
+}
+
+// ParseCommaSeparated builds a set of trimmed, non-empty values from a comma-separated string.
+func ParseCommaSeparated(s string) map[string]struct{} {
 	set := map[string]struct{}{}
-	for _, ns := range strings.Split(s, ",") {
-		if ns = strings.TrimSpace(ns); ns != "" {
-			set[ns] = struct{}{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = struct{}{}
 		}
 	}
 	return set
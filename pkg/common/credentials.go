@@ -0,0 +1,131 @@
+package common
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+// Credentials are the cluster credentials an API token of the HTTP server is confined to, so
+// that a single hosted server can serve several users with their own permissions.
+type Credentials struct {
+	// Name identifies the credentials in logs and caches. It must be unique.
+	Name string `json:"name"`
+	// Token is the API token clients present as "Authorization: Bearer <token>".
+	Token string `json:"token"`
+	// Context is the kubeconfig context used by the calls. Empty selects the current context,
+	// or the in-cluster config.
+	Context string `json:"context,omitempty"`
+	// ServiceAccountToken replaces the credentials of the context with a bearer token, such as
+	// the token of a ServiceAccount bound to the permissions of the user.
+	ServiceAccountToken string `json:"serviceAccountToken,omitempty"`
+	// ServiceAccountTokenFile is like ServiceAccountToken, with the token read from a file that
+	// is reloaded when it changes.
+	ServiceAccountTokenFile string `json:"serviceAccountTokenFile,omitempty"`
+}
+
+// tokenFile is the format of the file read by LoadTokenCredentials.
+type tokenFile struct {
+	Tokens []Credentials `json:"tokens"`
+}
+
+// TokenCredentials maps the API tokens accepted by the HTTP server to cluster credentials. When
+// empty, the HTTP server accepts every request with the credentials of the server. It is set
+// from the --token-contexts flag.
+var TokenCredentials []Credentials
+
+// LoadTokenCredentials reads the credentials of each API token from a YAML or JSON file, e.g.
+//
+//	tokens:
+//	  - name: team-a
+//	    token: 4f1c...
+//	    context: team-a
+//	  - name: ci
+//	    token: 9b2e...
+//	    serviceAccountTokenFile: /var/run/secrets/ci/token
+func LoadTokenCredentials(path string) ([]Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file tokenFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid token file %s: %w", path, err)
+	}
+	if len(file.Tokens) == 0 {
+		return nil, fmt.Errorf("token file %s does not define any token", path)
+	}
+	names := map[string]struct{}{}
+	tokens := map[string]struct{}{}
+	for _, c := range file.Tokens {
+		if c.Name == "" || c.Token == "" {
+			return nil, errors.New("every token requires a name and a token")
+		}
+		if c.ServiceAccountToken != "" && c.ServiceAccountTokenFile != "" {
+			return nil, fmt.Errorf("token %q: serviceAccountToken and serviceAccountTokenFile are mutually exclusive", c.Name)
+		}
+		if _, ok := names[c.Name]; ok {
+			return nil, fmt.Errorf("token name %q is used more than once", c.Name)
+		}
+		if _, ok := tokens[c.Token]; ok {
+			return nil, fmt.Errorf("token %q reuses the token of another entry", c.Name)
+		}
+		names[c.Name] = struct{}{}
+		tokens[c.Token] = struct{}{}
+	}
+	return file.Tokens, nil
+}
+
+// CredentialsForToken returns the credentials of an API token. Tokens are compared in constant
+// time, so that response times do not reveal them.
+func CredentialsForToken(token string) (Credentials, bool) {
+	var found *Credentials
+	for i := range TokenCredentials {
+		if subtle.ConstantTimeCompare([]byte(TokenCredentials[i].Token), []byte(token)) == 1 {
+			found = &TokenCredentials[i]
+		}
+	}
+	if found == nil {
+		return Credentials{}, false
+	}
+	return *found, true
+}
+
+// credentialsKey is the context.Context key of the credentials of the calling API token.
+type credentialsKey struct{}
+
+// WithCredentials returns a copy of ctx confining the cluster clients built from it to the given
+// credentials. The kubeconfig context they select cannot be overridden with WithKubeContext.
+func WithCredentials(ctx context.Context, c Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, c)
+}
+
+// CredentialsOf returns the credentials selected with WithCredentials, if any.
+func CredentialsOf(ctx context.Context) (Credentials, bool) {
+	c, ok := ctx.Value(credentialsKey{}).(Credentials)
+	return c, ok
+}
+
+// hasServiceAccountToken reports whether the credentials replace those of their context.
+func (c Credentials) hasServiceAccountToken() bool {
+	return c.ServiceAccountToken != "" || c.ServiceAccountTokenFile != ""
+}
+
+// withServiceAccountToken returns cfg authenticating with the ServiceAccount token of the
+// credentials selected for ctx, if any, instead of its own credentials.
+func withServiceAccountToken(ctx context.Context, cfg *rest.Config) *rest.Config {
+	c, ok := CredentialsOf(ctx)
+	if !ok || !c.hasServiceAccountToken() {
+		return cfg
+	}
+	// The cluster and its CA are kept, client certificates and auth plugins are dropped
+	cfg = rest.AnonymousClientConfig(cfg)
+	cfg.BearerToken = c.ServiceAccountToken
+	cfg.BearerTokenFile = c.ServiceAccountTokenFile
+	return cfg
+}
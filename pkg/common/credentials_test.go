@@ -0,0 +1,136 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestLoadTokenCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "contexts and ServiceAccount tokens",
+			content: `tokens:
+  - name: team-a
+    token: token-a
+    context: team-a
+  - name: ci
+    token: token-ci
+    serviceAccountTokenFile: /var/run/secrets/ci/token
+`,
+			wantNames: []string{"team-a", "ci"},
+		},
+		{name: "JSON", content: `{"tokens": [{"name": "team-a", "token": "token-a"}]}`, wantNames: []string{"team-a"}},
+		{name: "no tokens", content: "tokens: []\n", wantErr: true},
+		{name: "missing token", content: "tokens:\n  - name: team-a\n", wantErr: true},
+		{name: "missing name", content: "tokens:\n  - token: token-a\n", wantErr: true},
+		{name: "unknown field", content: "tokens:\n  - name: team-a\n    token: token-a\n    namespace: team-a\n", wantErr: true},
+		{name: "duplicate name", content: "tokens:\n  - name: team-a\n    token: token-a\n  - name: team-a\n    token: token-b\n", wantErr: true},
+		{name: "duplicate token", content: "tokens:\n  - name: team-a\n    token: token-a\n  - name: team-b\n    token: token-a\n", wantErr: true},
+		{
+			name:    "both ServiceAccount token sources",
+			content: "tokens:\n  - name: ci\n    token: token-ci\n    serviceAccountToken: sa\n    serviceAccountTokenFile: /var/run/secrets/ci/token\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			creds, err := LoadTokenCredentials(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadTokenCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(creds) != len(tt.wantNames) {
+				t.Fatalf("LoadTokenCredentials() returned %d credentials, want %d", len(creds), len(tt.wantNames))
+			}
+			for i, c := range creds {
+				if c.Name != tt.wantNames[i] {
+					t.Errorf("credentials %d = %q, want %q", i, c.Name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+
+	if _, err := LoadTokenCredentials(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadTokenCredentials() of a missing file succeeded")
+	}
+}
+
+func TestCredentialsForToken(t *testing.T) {
+	previous := TokenCredentials
+	t.Cleanup(func() { TokenCredentials = previous })
+	TokenCredentials = []Credentials{
+		{Name: "team-a", Token: "token-a", Context: "team-a"},
+		{Name: "ci", Token: "token-ci", ServiceAccountToken: "sa"},
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantName string
+		wantOK   bool
+	}{
+		{name: "known token", token: "token-a", wantName: "team-a", wantOK: true},
+		{name: "other known token", token: "token-ci", wantName: "ci", wantOK: true},
+		{name: "unknown token", token: "token-b"},
+		{name: "prefix of a token", token: "token"},
+		{name: "empty token", token: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := CredentialsForToken(tt.token)
+			if ok != tt.wantOK || c.Name != tt.wantName {
+				t.Errorf("CredentialsForToken(%q) = %q, %v, want %q, %v", tt.token, c.Name, ok, tt.wantName, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWithServiceAccountToken(t *testing.T) {
+	cfg := &rest.Config{
+		Host:            "https://cluster.example",
+		TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca"), CertData: []byte("cert"), KeyData: []byte("key")},
+		BearerToken:     "kubeconfig-token",
+	}
+	tests := []struct {
+		name      string
+		creds     *Credentials
+		wantToken string
+		wantFile  string
+		wantCert  bool
+	}{
+		{name: "no credentials", wantToken: "kubeconfig-token", wantCert: true},
+		{name: "context only", creds: &Credentials{Name: "team-a", Context: "team-a"}, wantToken: "kubeconfig-token", wantCert: true},
+		{name: "ServiceAccount token", creds: &Credentials{Name: "ci", ServiceAccountToken: "sa"}, wantToken: "sa"},
+		{name: "ServiceAccount token file", creds: &Credentials{Name: "ci", ServiceAccountTokenFile: "/var/run/secrets/ci/token"}, wantFile: "/var/run/secrets/ci/token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.creds != nil {
+				ctx = WithCredentials(ctx, *tt.creds)
+			}
+			got := withServiceAccountToken(ctx, rest.CopyConfig(cfg))
+			if got.BearerToken != tt.wantToken || got.BearerTokenFile != tt.wantFile {
+				t.Errorf("withServiceAccountToken() token = %q, file = %q, want %q, %q", got.BearerToken, got.BearerTokenFile, tt.wantToken, tt.wantFile)
+			}
+			if hasCert := len(got.CertData) > 0; hasCert != tt.wantCert {
+				t.Errorf("withServiceAccountToken() kept the client certificate = %v, want %v", hasCert, tt.wantCert)
+			}
+			if got.Host != cfg.Host || string(got.CAData) != "ca" {
+				t.Errorf("withServiceAccountToken() changed the cluster to %q", got.Host)
+			}
+		})
+	}
+}
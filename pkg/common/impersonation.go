@@ -0,0 +1,143 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Impersonation identifies the user cluster requests are made as, like the --as and --as-group
+// flags of kubectl, so that a server running with broad credentials only sees what that user may
+// see.
+type Impersonation struct {
+	User   string   `json:"user,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// DefaultImpersonation is used by calls that do not select an impersonation with
+// WithImpersonation. It is set from the --as and --as-group flags.
+var DefaultImpersonation Impersonation
+
+// IsZero reports whether no impersonation is configured.
+func (i Impersonation) IsZero() bool {
+	return i.User == "" && len(i.Groups) == 0
+}
+
+// Validate checks that groups are only impersonated together with a user, which the API server
+// requires.
+func (i Impersonation) Validate() error {
+	if i.User == "" && len(i.Groups) > 0 {
+		return errors.New("impersonating groups requires a user to impersonate")
+	}
+	return nil
+}
+
+// String renders the impersonation for cache keys and logs, e.g. "alice:team-a,team-b".
+func (i Impersonation) String() string {
+	if i.IsZero() {
+		return ""
+	}
+	groups := slices.Clone(i.Groups)
+	slices.Sort(groups)
+	return i.User + ":" + strings.Join(groups, ",")
+}
+
+// impersonationKey is the context.Context key of the impersonation selected for a call.
+type impersonationKey struct{}
+
+// WithImpersonation returns a copy of ctx making the cluster requests of the clients built from it
+// as the given user. A zero impersonation keeps DefaultImpersonation.
+func WithImpersonation(ctx context.Context, i Impersonation) context.Context {
+	if i.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, impersonationKey{}, i)
+}
+
+// ImpersonationOf returns the impersonation selected with WithImpersonation, or
+// DefaultImpersonation.
+func ImpersonationOf(ctx context.Context) Impersonation {
+	if i, ok := ctx.Value(impersonationKey{}).(Impersonation); ok {
+		return i
+	}
+	return DefaultImpersonation
+}
+
+// impersonate sets the impersonation selected for ctx on cfg.
+func impersonate(ctx context.Context, cfg *rest.Config) {
+	if i := ImpersonationOf(ctx); !i.IsZero() {
+		cfg.Impersonate = rest.ImpersonationConfig{UserName: i.User, Groups: i.Groups}
+	}
+}
+
+// WriteKubeConfig writes a kubeconfig for the cluster and credentials KubeConfig selects for ctx,
+// with the ServiceAccount token of the credentials selected for ctx and impersonating the user
+// selected for ctx, for clients that can only be configured with a kubeconfig file such as the
+// Kyverno CLI. It returns "" when neither a ServiceAccount token nor an impersonation is
+// selected, in which case the kubeconfig and KubeContext can be used as they are. The caller is
+// responsible for removing the file.
+func WriteKubeConfig(ctx context.Context) (string, error) {
+	i := ImpersonationOf(ctx)
+	creds, _ := CredentialsOf(ctx)
+	if i.IsZero() && !creds.hasServiceAccountToken() {
+		return "", nil
+	}
+
+	var config *clientcmdapi.Config
+	if cfg, err := rest.InClusterConfig(); err == nil && KubeContext(ctx) == "" {
+		config = clientcmdapi.NewConfig()
+		config.Clusters["in-cluster"] = &clientcmdapi.Cluster{Server: cfg.Host, CertificateAuthority: cfg.TLSClientConfig.CAFile}
+		config.AuthInfos["in-cluster"] = &clientcmdapi.AuthInfo{TokenFile: cfg.BearerTokenFile, Token: cfg.BearerToken}
+		config.Contexts["in-cluster"] = &clientcmdapi.Context{Cluster: "in-cluster", AuthInfo: "in-cluster"}
+		config.CurrentContext = "in-cluster"
+	} else {
+		raw, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{CurrentContext: KubeContext(ctx)},
+		).RawConfig()
+		if err != nil {
+			return "", err
+		}
+		if name := KubeContext(ctx); name != "" {
+			raw.CurrentContext = name
+		}
+		// Only the selected context is kept, with certificate files inlined so that the file
+		// does not depend on paths relative to the original kubeconfig
+		if err := clientcmdapi.MinifyConfig(&raw); err != nil {
+			return "", err
+		}
+		if err := clientcmdapi.FlattenConfig(&raw); err != nil {
+			return "", err
+		}
+		config = &raw
+	}
+	for name, authInfo := range config.AuthInfos {
+		if creds.hasServiceAccountToken() {
+			authInfo = &clientcmdapi.AuthInfo{Token: creds.ServiceAccountToken, TokenFile: creds.ServiceAccountTokenFile}
+			config.AuthInfos[name] = authInfo
+		}
+		authInfo.Impersonate = i.User
+		authInfo.ImpersonateGroups = i.Groups
+	}
+
+	f, err := os.CreateTemp("", "kyverno-kubeconfig-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
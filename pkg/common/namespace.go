@@ -0,0 +1,226 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// AllNamespaces is the namespace argument value that selects every namespace.
+	AllNamespaces = "all"
+	// DefaultNamespace is used when no namespace argument is supplied.
+	DefaultNamespace = "default"
+	// DefaultNamespaceExcludes lists the namespaces skipped by default when scanning all namespaces.
+	DefaultNamespaceExcludes = "kube-system,kyverno"
+)
+
+// AllowedNamespaces restricts every tool to the listed namespaces, whatever their arguments, for
+// servers shared by tenants. Cluster-scoped resources are then out of scope as well. It is set
+// from the --allowed-namespaces flag; an empty set allows every namespace.
+var AllowedNamespaces map[string]struct{}
+
+// NamespaceAllowed reports whether tools may access namespace ns, or cluster-scoped resources
+// when ns is empty.
+func NamespaceAllowed(ns string) bool {
+	if len(AllowedNamespaces) == 0 {
+		return true
+	}
+	_, ok := AllowedNamespaces[ns]
+	return ok
+}
+
+// CheckNamespacesAllowed fails if any of namespaces is outside AllowedNamespaces.
+func CheckNamespacesAllowed(namespaces ...string) error {
+	var denied []string
+	for _, ns := range namespaces {
+		if !NamespaceAllowed(ns) {
+			denied = append(denied, ns)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("namespaces not allowed on this server: %s (allowed: %s)", strings.Join(denied, ", "), strings.Join(allowedNamespaces(), ", "))
+	}
+	return nil
+}
+
+// allowedNamespaces returns AllowedNamespaces sorted.
+func allowedNamespaces() []string {
+	names := make([]string, 0, len(AllowedNamespaces))
+	for ns := range AllowedNamespaces {
+		names = append(names, ns)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NamespaceScope describes the namespaces targeted by a tool invocation.
+//
+// The namespace argument of every tool is resolved the same way:
+//   - "" selects the default namespace
+//   - "all" selects every namespace except those in the exclude list
+//   - "a" or "a,b,c" selects exactly the listed namespaces; the exclude list is ignored
+//
+// When AllowedNamespaces is set, "all" selects the allowed namespaces except those in the exclude
+// list, and listing other namespaces is an error.
+//
+// Exclude list entries are namespace names or regular expressions such as "kube-.*", which must
+// match the whole namespace name.
+type NamespaceScope struct {
+	// All is set when every namespace is targeted.
+	All bool
+	// Namespaces lists the targeted namespaces, in argument order, when All is not set.
+	Namespaces []string
+	// Exclude holds the namespaces to skip when All is set.
+	Exclude map[string]struct{}
+	// ExcludePatterns holds the anchored patterns of namespaces to skip when All is set.
+	ExcludePatterns []*regexp.Regexp
+}
+
+// ResolveNamespaces builds a NamespaceScope from the namespace and namespace_exclude tool
+// arguments. It fails if an exclude pattern is not a valid regular expression.
+func ResolveNamespaces(namespace, exclude string) (NamespaceScope, error) {
+	namespace = strings.TrimSpace(namespace)
+	if namespace == AllNamespaces {
+		scope := NamespaceScope{All: true, Exclude: map[string]struct{}{}}
+		for entry := range ParseNamespaceExcludes(exclude) {
+			// Plain names are matched exactly, anything else is treated as a pattern
+			if regexp.QuoteMeta(entry) == entry {
+				scope.Exclude[entry] = struct{}{}
+				continue
+			}
+			re, err := regexp.Compile("^(?:" + entry + ")$")
+			if err != nil {
+				return NamespaceScope{}, fmt.Errorf("invalid namespace_exclude pattern %q: %w", entry, err)
+			}
+			scope.ExcludePatterns = append(scope.ExcludePatterns, re)
+		}
+		if len(AllowedNamespaces) == 0 {
+			return scope, nil
+		}
+		restricted := NamespaceScope{}
+		for _, ns := range allowedNamespaces() {
+			if scope.Includes(ns) {
+				restricted.Namespaces = append(restricted.Namespaces, ns)
+			}
+		}
+		if len(restricted.Namespaces) == 0 {
+			return NamespaceScope{}, fmt.Errorf("every namespace allowed on this server is excluded by namespace_exclude")
+		}
+		return restricted, nil
+	}
+
+	var namespaces []string
+	seen := map[string]struct{}{}
+	for _, ns := range strings.Split(namespace, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		if _, dup := seen[ns]; dup {
+			continue
+		}
+		seen[ns] = struct{}{}
+		namespaces = append(namespaces, ns)
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{DefaultNamespace}
+		// A server restricted to a single namespace defaults to it
+		if allowed := allowedNamespaces(); len(allowed) == 1 {
+			namespaces = allowed
+		}
+	}
+	if err := CheckNamespacesAllowed(namespaces...); err != nil {
+		return NamespaceScope{}, err
+	}
+	return NamespaceScope{Namespaces: namespaces}, nil
+}
+
+// ExcludeMatching adds the namespaces matching a label selector to the exclude list of a scope
+// targeting all namespaces. It has no effect on scopes listing explicit namespaces.
+func (s *NamespaceScope) ExcludeMatching(ctx context.Context, selector string) error {
+	if !s.All || strings.TrimSpace(selector) == "" {
+		return nil
+	}
+	names, err := ListNamespacesMatching(ctx, selector)
+	if err != nil {
+		return fmt.Errorf("list namespaces matching %q: %w", selector, err)
+	}
+	for _, ns := range names {
+		s.Exclude[ns] = struct{}{}
+	}
+	return nil
+}
+
+// Includes reports whether resources in namespace ns fall within the scope. Cluster-scoped
+// resources, which have an empty namespace, are included unless AllowedNamespaces is set.
+func (s NamespaceScope) Includes(ns string) bool {
+	if ns == "" {
+		return NamespaceAllowed("")
+	}
+	if s.All {
+		if _, excluded := s.Exclude[ns]; excluded {
+			return false
+		}
+		for _, re := range s.ExcludePatterns {
+			if re.MatchString(ns) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, n := range s.Namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the scope in the form of the namespace argument, e.g. "team-a,team-b" or "all".
+func (s NamespaceScope) String() string {
+	if s.All {
+		return AllNamespaces
+	}
+	return strings.Join(s.Namespaces, ",")
+}
+
+// Single returns the targeted namespace if the scope selects exactly one namespace.
+func (s NamespaceScope) Single() (string, bool) {
+	if s.All || len(s.Namespaces) != 1 {
+		return "", false
+	}
+	return s.Namespaces[0], true
+}
+
+// Validate checks that every explicitly listed namespace exists in the cluster. Scopes
+// targeting all namespaces are always valid. If the namespaces cannot be listed, for instance
+// because of missing RBAC permissions, the check is skipped rather than failing the call.
+func (s NamespaceScope) Validate(ctx context.Context) error {
+	if s.All {
+		return nil
+	}
+	existing, err := ListNamespaces(ctx)
+	if err != nil {
+		klog.V(2).InfoS("skipping namespace validation", "error", err)
+		return nil
+	}
+	known := make(map[string]struct{}, len(existing))
+	for _, ns := range existing {
+		known[ns] = struct{}{}
+	}
+	var missing []string
+	for _, ns := range s.Namespaces {
+		if _, ok := known[ns]; !ok {
+			missing = append(missing, ns)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("namespaces not found in the cluster: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
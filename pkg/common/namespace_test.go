@@ -0,0 +1,67 @@
+package common
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveNamespaces(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowed        []string
+		namespace      string
+		exclude        string
+		wantAll        bool
+		wantNamespaces []string
+		included       []string
+		excluded       []string
+		wantErr        bool
+	}{
+		{name: "default namespace", namespace: "", wantNamespaces: []string{DefaultNamespace}, included: []string{DefaultNamespace, ""}, excluded: []string{"team-a"}},
+		{name: "listed namespaces are trimmed and deduplicated", namespace: " team-a , team-b,,team-a", exclude: "team-a", wantNamespaces: []string{"team-a", "team-b"}, included: []string{"team-a", "team-b"}, excluded: []string{DefaultNamespace}},
+		{name: "all with plain excludes", namespace: AllNamespaces, exclude: DefaultNamespaceExcludes, wantAll: true, included: []string{"team-a", "kube-public", ""}, excluded: []string{"kube-system", "kyverno"}},
+		{name: "all with an exclude pattern", namespace: AllNamespaces, exclude: "kube-.*", wantAll: true, included: []string{"kyverno", "kube"}, excluded: []string{"kube-system", "kube-public"}},
+		{name: "exclude patterns match whole names", namespace: AllNamespaces, exclude: "team-(a|b), kyverno", wantAll: true, included: []string{"team-c", "team-ab", "my-team-a"}, excluded: []string{"team-a", "team-b", "kyverno"}},
+		{name: "invalid exclude pattern", namespace: AllNamespaces, exclude: "team-[", wantErr: true},
+		{name: "single allowed namespace is the default", allowed: []string{"team-a"}, namespace: "", wantNamespaces: []string{"team-a"}, included: []string{"team-a"}, excluded: []string{DefaultNamespace, ""}},
+		{name: "default namespace not allowed", allowed: []string{"team-a", "team-b"}, namespace: "", wantErr: true},
+		{name: "listed namespace not allowed", allowed: []string{"team-a"}, namespace: "team-a,team-b", wantErr: true},
+		{name: "all is restricted to allowed namespaces", allowed: []string{"team-b", "team-a", "team-c"}, namespace: AllNamespaces, exclude: "team-c", wantNamespaces: []string{"team-a", "team-b"}, included: []string{"team-a", "team-b"}, excluded: []string{"team-c", "team-d", ""}},
+		{name: "every allowed namespace excluded", allowed: []string{"team-a"}, namespace: AllNamespaces, exclude: "team-.*", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := AllowedNamespaces
+			t.Cleanup(func() { AllowedNamespaces = previous })
+			AllowedNamespaces = nil
+			if len(tt.allowed) > 0 {
+				AllowedNamespaces = ParseCommaSeparated(strings.Join(tt.allowed, ","))
+			}
+
+			scope, err := ResolveNamespaces(tt.namespace, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveNamespaces(%q, %q) error = %v, wantErr %v", tt.namespace, tt.exclude, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if scope.All != tt.wantAll {
+				t.Errorf("All = %v, want %v", scope.All, tt.wantAll)
+			}
+			if !slices.Equal(scope.Namespaces, tt.wantNamespaces) {
+				t.Errorf("Namespaces = %v, want %v", scope.Namespaces, tt.wantNamespaces)
+			}
+			for _, ns := range tt.included {
+				if !scope.Includes(ns) {
+					t.Errorf("Includes(%q) = false, want true", ns)
+				}
+			}
+			for _, ns := range tt.excluded {
+				if scope.Includes(ns) {
+					t.Errorf("Includes(%q) = true, want false", ns)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cursorPrefix namespaces the opaque pagination cursors handed out to clients.
+const cursorPrefix = "offset:"
+
+// PageRequest describes which slice of a stably ordered result set a client asked for.
+//
+// Every tool returning a list paginates the same way:
+//   - "limit" caps the number of items returned; 0 returns everything
+//   - "cursor", or its alias "continue", resumes after the items already returned
+//   - a paginated response is wrapped as {results, total, nextCursor}, where nextCursor is
+//     omitted on the last page
+//
+// Items must be sorted with SortStable, or another deterministic order, before Paginate so that
+// cursors stay valid across calls.
+type PageRequest struct {
+	// Offset is the index of the first item to return.
+	Offset int
+	// Limit is the maximum number of items to return; 0 means "everything from Offset onwards".
+	Limit int
+}
+
+// NewPageRequest validates the limit, cursor and continue tool arguments. Both cursor and
+// continue may be set as long as they agree.
+func NewPageRequest(limit int, cursor, cont string) (PageRequest, error) {
+	if limit < 0 {
+		return PageRequest{}, fmt.Errorf("invalid limit %d: must not be negative", limit)
+	}
+	if cont != "" {
+		if cursor != "" && cursor != cont {
+			return PageRequest{}, fmt.Errorf("cursor and continue must not be set to different values")
+		}
+		cursor = cont
+	}
+	offset, err := decodeCursor(cursor)
+	if err != nil {
+		return PageRequest{}, err
+	}
+	return PageRequest{Offset: offset, Limit: limit}, nil
+}
+
+// Enabled reports whether the client asked for paginated output.
+func (p PageRequest) Enabled() bool {
+	return p.Limit > 0 || p.Offset > 0
+}
+
+// Page is the envelope of a paginated list response.
+type Page[T any] struct {
+	Results    []T    `json:"results"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// NewPage returns the requested page of items wrapped in its envelope.
+func NewPage[T any](items []T, p PageRequest) Page[T] {
+	results, next := Paginate(items, p)
+	return Page[T]{Results: results, Total: len(items), NextCursor: next}
+}
+
+// Paginate returns the requested page of items together with the cursor of the next page,
+// which is empty once the last page has been returned.
+func Paginate[T any](items []T, p PageRequest) ([]T, string) {
+	if p.Offset >= len(items) {
+		return []T{}, ""
+	}
+	end := len(items)
+	if p.Limit > 0 && p.Offset+p.Limit < end {
+		end = p.Offset + p.Limit
+	}
+	var next string
+	if end < len(items) {
+		next = encodeCursor(end)
+	}
+	return items[p.Offset:end], next
+}
+
+// SortStable orders items by the given key, keeping the relative order of items with equal
+// keys, so that the same items always land on the same page.
+func SortStable[T any](items []T, key func(T) string) {
+	sort.SliceStable(items, func(i, j int) bool { return key(items[i]) < key(items[j]) })
+}
+
+// CursorAt returns the cursor of the page starting at offset, e.g. to resume a page that had to
+// be cut short.
+func CursorAt(offset int) string {
+	return encodeCursor(offset)
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4}
+	tests := []struct {
+		name     string
+		page     PageRequest
+		want     []int
+		wantNext string
+	}{
+		{name: "everything", page: PageRequest{}, want: items},
+		{name: "first page", page: PageRequest{Limit: 2}, want: []int{0, 1}, wantNext: CursorAt(2)},
+		{name: "middle page", page: PageRequest{Offset: 2, Limit: 2}, want: []int{2, 3}, wantNext: CursorAt(4)},
+		{name: "last page", page: PageRequest{Offset: 4, Limit: 2}, want: []int{4}},
+		{name: "limit reaching the end", page: PageRequest{Offset: 3, Limit: 2}, want: []int{3, 4}},
+		{name: "offset without limit", page: PageRequest{Offset: 3}, want: []int{3, 4}},
+		{name: "offset at the end", page: PageRequest{Offset: 5, Limit: 2}, want: []int{}},
+		{name: "offset past the end", page: PageRequest{Offset: 9}, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := Paginate(items, tt.page)
+			// An empty page must encode as [] rather than null
+			if !slices.Equal(got, tt.want) || got == nil {
+				t.Errorf("Paginate() = %#v, want %v", got, tt.want)
+			}
+			if next != tt.wantNext {
+				t.Errorf("Paginate() next cursor = %q, want %q", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestCursorAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+	}{
+		{name: "start", offset: 0},
+		{name: "offset", offset: 42},
+		{name: "large offset", offset: 1 << 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := NewPageRequest(10, CursorAt(tt.offset), "")
+			if err != nil {
+				t.Fatalf("NewPageRequest() error = %v", err)
+			}
+			if page.Offset != tt.offset {
+				t.Errorf("NewPageRequest(CursorAt(%d)) offset = %d", tt.offset, page.Offset)
+			}
+			if page.Limit != 10 {
+				t.Errorf("NewPageRequest() limit = %d, want 10", page.Limit)
+			}
+		})
+	}
+}
+
+func TestNewPageRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		cursor  string
+		cont    string
+		want    PageRequest
+		wantErr bool
+	}{
+		{name: "no arguments", want: PageRequest{}},
+		{name: "limit", limit: 5, want: PageRequest{Limit: 5}},
+		{name: "cursor", limit: 5, cursor: CursorAt(10), want: PageRequest{Offset: 10, Limit: 5}},
+		{name: "continue alias", cont: CursorAt(10), want: PageRequest{Offset: 10}},
+		{name: "cursor and continue agree", cursor: CursorAt(3), cont: CursorAt(3), want: PageRequest{Offset: 3}},
+		{name: "cursor and continue disagree", cursor: CursorAt(3), cont: CursorAt(4), wantErr: true},
+		{name: "negative limit", limit: -1, wantErr: true},
+		{name: "malformed cursor", cursor: "not a cursor", wantErr: true},
+		{name: "cursor without prefix", cursor: "MTA", wantErr: true},
+		{name: "negative offset", cursor: "b2Zmc2V0Oi0x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPageRequest(tt.limit, tt.cursor, tt.cont)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPageRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("NewPageRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
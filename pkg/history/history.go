@@ -0,0 +1,210 @@
+// Package history records the summaries of policy scans in an embedded bbolt database, so that
+// previous runs can be listed and compared across restarts of the server.
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// scansBucket holds the recorded scans, keyed by their big-endian ID so that iteration follows
+// the order in which they were recorded.
+var scansBucket = []byte("scans")
+
+// ErrDisabled is returned when the history is used without having been opened.
+var ErrDisabled = errors.New("scan history is disabled; start the server with --history-db")
+
+// Counts counts results by status.
+type Counts struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+// Sub returns the difference between c and o, status by status.
+func (c Counts) Sub(o Counts) Counts {
+	return Counts{Pass: c.Pass - o.Pass, Fail: c.Fail - o.Fail, Warn: c.Warn - o.Warn, Error: c.Error - o.Error, Skip: c.Skip - o.Skip}
+}
+
+// Scan is the summary of a scan recorded in the history.
+type Scan struct {
+	// ID is assigned when the scan is recorded and increases with every scan.
+	ID   uint64    `json:"id"`
+	Time time.Time `json:"time"`
+	// Credentials names the credentials of the API token the scan was made with; empty for
+	// scans made with the credentials of the server.
+	Credentials string `json:"credentials,omitempty"`
+	// Context is the kubeconfig context the scan ran against; empty for the current context.
+	Context string `json:"context,omitempty"`
+	// Namespace is the scanned namespace scope, e.g. "default", "team-a,team-b" or "all".
+	Namespace  string `json:"namespace"`
+	PolicySets string `json:"policySets,omitempty"`
+	// Sampled and Incremental mark scans that did not evaluate every resource again.
+	Sampled     bool `json:"sampled,omitempty"`
+	Incremental bool `json:"incremental,omitempty"`
+	// Filters describes the arguments the counts were narrowed down by, e.g.
+	// "minSeverity=high; labelSelector=app=web"; empty for an unfiltered scan. Only scans with the
+	// same filters are comparable.
+	Filters          string `json:"filters,omitempty"`
+	ResourcesScanned int    `json:"resourcesScanned"`
+	PoliciesApplied  int    `json:"policiesApplied"`
+	Duration         string `json:"duration"`
+	Totals           Counts `json:"totals"`
+	// ByPolicy and ByNamespace break the totals down.
+	ByPolicy    map[string]Counts `json:"byPolicy,omitempty"`
+	ByNamespace map[string]Counts `json:"byNamespace,omitempty"`
+}
+
+// Filter selects recorded scans. Empty fields match every scan, except Credentials.
+type Filter struct {
+	// Credentials only matches the scans made with the same credentials, so that the users of
+	// different API tokens cannot see each other's scans. Empty matches the scans made with the
+	// credentials of the server.
+	Credentials string
+	Context     string
+	Namespace   string
+	PolicySets  string
+	// Since drops the scans recorded before it.
+	Since time.Time
+	// Limit bounds the number of scans returned, newest first; 0 returns every scan.
+	Limit int
+}
+
+func (f Filter) matches(s Scan) bool {
+	return f.Credentials == s.Credentials &&
+		(f.Context == "" || f.Context == s.Context) &&
+		(f.Namespace == "" || f.Namespace == s.Namespace) &&
+		(f.PolicySets == "" || f.PolicySets == s.PolicySets) &&
+		!s.Time.Before(f.Since)
+}
+
+var (
+	mu sync.RWMutex
+	db *bolt.DB
+)
+
+// Open opens, or creates, the history database at path. Until Open is called, scans are not
+// recorded.
+func Open(path string) error {
+	d, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+	if err := d.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(scansBucket)
+		return err
+	}); err != nil {
+		_ = d.Close()
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	db = d
+	return nil
+}
+
+// Close closes the history database.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if db == nil {
+		return nil
+	}
+	err := db.Close()
+	db = nil
+	return err
+}
+
+// Enabled reports whether the history database is open.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return db != nil
+}
+
+// Record stores a scan, assigning its ID, and its time when not set, and returns it.
+func Record(scan Scan) (Scan, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if db == nil {
+		return scan, ErrDisabled
+	}
+	if scan.Time.IsZero() {
+		scan.Time = time.Now().UTC()
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scansBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		scan.ID = id
+		data, err := json.Marshal(scan)
+		if err != nil {
+			return err
+		}
+		return b.Put(key(id), data)
+	})
+	return scan, err
+}
+
+// List returns the scans matching f, newest first.
+func List(f Filter) ([]Scan, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if db == nil {
+		return nil, ErrDisabled
+	}
+	var scans []Scan
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(scansBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var s Scan
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			if !f.matches(s) {
+				continue
+			}
+			scans = append(scans, s)
+			if f.Limit > 0 && len(scans) == f.Limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	return scans, err
+}
+
+// Get returns the scan with the given ID. The boolean is false if there is none.
+func Get(id uint64) (Scan, bool, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if db == nil {
+		return Scan{}, false, ErrDisabled
+	}
+	var s Scan
+	var found bool
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(scansBucket).Get(key(id))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &s)
+	})
+	return s, found, err
+}
+
+func key(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
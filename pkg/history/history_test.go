@@ -0,0 +1,114 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDisabled(t *testing.T) {
+	if Enabled() {
+		t.Fatal("Enabled() = true before Open")
+	}
+	if _, err := Record(Scan{}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Record() error = %v, want ErrDisabled", err)
+	}
+	if _, err := List(Filter{}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("List() error = %v, want ErrDisabled", err)
+	}
+	if _, _, err := Get(1); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Get() error = %v, want ErrDisabled", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	if err := Open(path); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, s := range []Scan{
+		{Namespace: "all", PolicySets: "pod-security"},
+		{Namespace: "team-a", PolicySets: "pod-security"},
+		{Namespace: "all", PolicySets: "rbac-best-practices", Context: "staging"},
+		{Namespace: "all", PolicySets: "pod-security", Credentials: "team-a-token"},
+		{Namespace: "all", PolicySets: "pod-security", Totals: Counts{Pass: 3, Fail: 1}},
+	} {
+		s.Time = start.Add(time.Duration(i) * time.Hour)
+		recorded, err := Record(s)
+		if err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if recorded.ID != uint64(i+1) {
+			t.Fatalf("Record() assigned ID %d, want %d", recorded.ID, i+1)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantIDs []uint64
+	}{
+		{name: "every scan of the server credentials", wantIDs: []uint64{5, 3, 2, 1}},
+		{name: "scans of an API token", filter: Filter{Credentials: "team-a-token"}, wantIDs: []uint64{4}},
+		{name: "namespace", filter: Filter{Namespace: "team-a"}, wantIDs: []uint64{2}},
+		{name: "policy sets", filter: Filter{PolicySets: "pod-security"}, wantIDs: []uint64{5, 2, 1}},
+		{name: "context", filter: Filter{Context: "staging"}, wantIDs: []uint64{3}},
+		{name: "since", filter: Filter{Since: start.Add(2 * time.Hour)}, wantIDs: []uint64{5, 3}},
+		{name: "limit", filter: Filter{Limit: 2}, wantIDs: []uint64{5, 3}},
+		{name: "no match", filter: Filter{Namespace: "team-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans, err := List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var ids []uint64
+			for _, s := range scans {
+				ids = append(ids, s.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("List() = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	scan, found, err := Get(5)
+	if err != nil || !found {
+		t.Fatalf("Get(5) = %v, %v, want the recorded scan", found, err)
+	}
+	if scan.Totals != (Counts{Pass: 3, Fail: 1}) || !scan.Time.Equal(start.Add(4*time.Hour)) {
+		t.Errorf("Get(5) = %+v, want the fifth recorded scan", scan)
+	}
+	if _, found, err := Get(42); err != nil || found {
+		t.Errorf("Get(42) = %v, %v, want no scan", found, err)
+	}
+}
+
+func TestCountsSub(t *testing.T) {
+	tests := []struct {
+		name string
+		c, o Counts
+		want Counts
+	}{
+		{name: "no change", c: Counts{Pass: 2, Fail: 1}, o: Counts{Pass: 2, Fail: 1}},
+		{name: "fixed violations", c: Counts{Pass: 5, Fail: 1}, o: Counts{Pass: 3, Fail: 3}, want: Counts{Pass: 2, Fail: -2}},
+		{name: "every status", c: Counts{Pass: 1, Fail: 2, Warn: 3, Error: 4, Skip: 5}, want: Counts{Pass: 1, Fail: 2, Warn: 3, Error: 4, Skip: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Sub(tt.o); got != tt.want {
+				t.Errorf("Sub() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
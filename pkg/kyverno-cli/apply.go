@@ -0,0 +1,443 @@
+// Package kyverno provides a shim for the Kyverno CLI.
+package kyverno
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	kyvernov2 "github.com/kyverno/kyverno/api/kyverno/v2"
+	policiesv1alpha1 "github.com/kyverno/kyverno/api/policies.kyverno.io/v1alpha1"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/apis/v1alpha1"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/commands/apply"
+	clicontext "github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/context"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/data"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/deprecations"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/exception"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/policy"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/processor"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/resource"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/source"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/store"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/userinfo"
+	clicommon "github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/utils/common"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/values"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/variables"
+	celengine "github.com/kyverno/kyverno/pkg/cel/engine"
+	"github.com/kyverno/kyverno/pkg/cel/libs"
+	"github.com/kyverno/kyverno/pkg/cel/matching"
+	ivpolengine "github.com/kyverno/kyverno/pkg/cel/policies/ivpol/engine"
+	"github.com/kyverno/kyverno/pkg/clients/dclient"
+	kyvernoconfig "github.com/kyverno/kyverno/pkg/config"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	gctxstore "github.com/kyverno/kyverno/pkg/globalcontext/store"
+	"github.com/kyverno/kyverno/pkg/imageverification/imagedataloader"
+	gitutils "github.com/kyverno/kyverno/pkg/utils/git"
+	policyvalidation "github.com/kyverno/kyverno/pkg/validation/policy"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	k8scorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
+)
+
+// SkippedPolicy names a policy that failed validation and was not applied.
+type SkippedPolicy struct {
+	Name   string
+	Reason string
+}
+
+// ApplyResult represents the result of applying policies to resources
+type ApplyResult struct {
+	ResultCounts    *processor.ResultCounts
+	Unstructured    []*unstructured.Unstructured
+	EngineResponses []engineapi.EngineResponse
+	// SkippedPolicies lists the policies that failed validation, in load order.
+	SkippedPolicies []SkippedPolicy
+	// Warnings lists the problems that did not fail the call, such as policy documents or
+	// resource files that could not be loaded.
+	Warnings []string
+}
+
+// loadedPolicies holds the policies of every type loaded from the policy paths of a call.
+type loadedPolicies struct {
+	policy.LoaderResults
+	warnings []string
+}
+
+// Apply applies the policies of config to resources the way the apply command of the Kyverno CLI
+// does, on top of its exported loading and processing packages. When resources is nil, they are
+// loaded from config.ResourcePaths, or fetched from the cluster in cluster mode. Nothing is
+// printed and no process-wide state such as os.Stdout is modified, so concurrent calls from
+// parallel scans and sessions do not interfere with each other.
+func Apply(ctx context.Context, config *apply.ApplyCommandConfig, resources []*unstructured.Unstructured) (*ApplyResult, error) {
+	if err := checkArguments(config, resources != nil); err != nil {
+		return nil, err
+	}
+	result := &ApplyResult{ResultCounts: &processor.ResultCounts{}}
+
+	var userInfo *kyvernov2.RequestInfo
+	if config.UserInfoPath != "" {
+		info, err := userinfo.Load(nil, config.UserInfoPath, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load request info (%w)", err)
+		}
+		if deprecations.CheckUserInfo(nil, config.UserInfoPath, info) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("user infos file (%s) uses a deprecated schema", config.UserInfoPath))
+		}
+		userInfo = &info.RequestInfo
+	}
+	// The values file is loaded here rather than by variables.New, so that a deprecated schema is
+	// reported as a warning
+	var valuesSpec *v1alpha1.ValuesSpec
+	if config.ValuesFile != "" {
+		vals, err := values.Load(nil, config.ValuesFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load variable file: %s (%w)", config.ValuesFile, err)
+		}
+		if deprecations.CheckValues(nil, config.ValuesFile, vals) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("values file (%s) uses a deprecated schema", config.ValuesFile))
+		}
+		valuesSpec = &vals.ValuesSpec
+	}
+	vars, err := variables.New(nil, nil, "", "", valuesSpec, config.Variables...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode yaml (%w)", err)
+	}
+
+	loaded, err := loadPolicies(config)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(result.Warnings, loaded.warnings...)
+
+	var s store.Store
+	s.SetLocal(true)
+	s.SetRegistryAccess(config.RegistryAccess)
+	var dClient dclient.Interface
+	if config.Cluster {
+		s.AllowApiCall(true)
+		if dClient, err = newClusterClient(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if resources == nil {
+		var warnings []string
+		if config.Cluster {
+			resources, err = clicommon.GetResourceAccordingToResourcePath(io.Discard, nil, config.ResourcePaths, true, loaded.Policies, loaded.VAPs, dClient, config.Namespace, config.PolicyReport, "")
+		} else {
+			resources, warnings, err = loadResourceFiles(config.ResourcePaths)
+		}
+		result.Warnings = append(result.Warnings, warnings...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load resources (%w)", err)
+		}
+	}
+
+	var exceptions []*kyvernov2.PolicyException
+	var celExceptions []*policiesv1alpha1.PolicyException
+	if len(config.Exception) > 0 {
+		loadedExceptions, err := exception.Load(config.Exception...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load exceptions (%w)", err)
+		}
+		if loadedExceptions != nil {
+			exceptions = loadedExceptions.Exceptions
+			celExceptions = loadedExceptions.CELExceptions
+		}
+	}
+
+	vars.SetInStore(&s)
+	sa := kyvernoconfig.KyvernoUserName(kyvernoconfig.KyvernoServiceAccountName())
+	validPolicies := make([]kyvernov1.PolicyInterface, 0, len(loaded.Policies))
+	for _, p := range loaded.Policies {
+		if _, err := policyvalidation.Validate(p, nil, nil, true, sa, sa); err != nil {
+			result.ResultCounts.IncrementError(1)
+			result.SkippedPolicies = append(result.SkippedPolicies, SkippedPolicy{
+				Name:   PolicyKey(engineapi.NewKyvernoPolicy(p)),
+				Reason: "failed policy validation: " + err.Error(),
+			})
+			continue
+		}
+		validPolicies = append(validPolicies, p)
+	}
+
+	for _, r := range resources {
+		pp := processor.PolicyProcessor{
+			Store:                             &s,
+			Policies:                          validPolicies,
+			ValidatingAdmissionPolicies:       loaded.VAPs,
+			ValidatingAdmissionPolicyBindings: loaded.VAPBindings,
+			ValidatingPolicies:                loaded.ValidatingPolicies,
+			Resource:                          *r,
+			PolicyExceptions:                  exceptions,
+			CELExceptions:                     celExceptions,
+			Variables:                         vars,
+			ContextPath:                       config.ContextPath,
+			UserInfo:                          userInfo,
+			PolicyReport:                      config.PolicyReport,
+			NamespaceSelectorMap:              vars.NamespaceSelectors(),
+			Rc:                                result.ResultCounts,
+			Cluster:                           config.Cluster,
+			Client:                            dClient,
+			AuditWarn:                         config.AuditWarn,
+			Subresources:                      vars.Subresources(),
+			Out:                               io.Discard,
+		}
+		responses, err := pp.ApplyPoliciesOnResource()
+		if err != nil {
+			if config.ContinueOnFail {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("failed to apply policies on resource %s: %v", r.GetName(), err))
+				continue
+			}
+			return nil, fmt.Errorf("failed to apply policies on resource %s (%w)", r.GetName(), err)
+		}
+		result.EngineResponses = append(result.EngineResponses, responses...)
+	}
+	result.Unstructured = resources
+
+	responses, err := applyImageValidatingPolicies(ctx, config, loaded.ImageValidatingPolicies, resources, celExceptions, vars.Namespace, userInfo, result.ResultCounts, dClient)
+	if err != nil {
+		return nil, err
+	}
+	result.EngineResponses = append(result.EngineResponses, responses...)
+	return result, nil
+}
+
+// checkArguments rejects the configurations the apply command of the Kyverno CLI rejects.
+// Resources passed to Apply take the place of resource paths.
+func checkArguments(config *apply.ApplyCommandConfig, hasResources bool) error {
+	if config.ValuesFile != "" && config.Variables != nil {
+		return fmt.Errorf("pass the values either using set flag or values_file flag")
+	}
+	if len(config.PolicyPaths) == 0 {
+		return fmt.Errorf("require policy")
+	}
+	if len(config.ResourcePaths) == 0 && !config.Cluster && !hasResources {
+		return fmt.Errorf("resource file(s) or cluster required")
+	}
+	return nil
+}
+
+// loadPolicies loads the policies of every policy path of config, cloning the repository of git
+// URLs. Paths and documents that cannot be loaded are skipped with a warning, as the Kyverno CLI
+// skips them.
+func loadPolicies(config *apply.ApplyCommandConfig) (*loadedPolicies, error) {
+	loaded := &loadedPolicies{}
+	add := func(path string, results *policy.LoaderResults, err error) {
+		if results != nil {
+			for _, e := range results.NonFatalErrors {
+				loaded.warnings = append(loaded.warnings, fmt.Sprintf("skipped a policy document of %s: %v", e.Path, e.Error))
+			}
+		}
+		if err != nil {
+			loaded.warnings = append(loaded.warnings, fmt.Sprintf("failed to load policies from %s: %v", path, err))
+			return
+		}
+		loaded.Policies = append(loaded.Policies, results.Policies...)
+		loaded.VAPs = append(loaded.VAPs, results.VAPs...)
+		loaded.VAPBindings = append(loaded.VAPBindings, results.VAPBindings...)
+		loaded.ValidatingPolicies = append(loaded.ValidatingPolicies, results.ValidatingPolicies...)
+		loaded.ImageValidatingPolicies = append(loaded.ImageValidatingPolicies, results.ImageValidatingPolicies...)
+	}
+	gitBranch := config.GitBranch
+	for _, path := range config.PolicyPaths {
+		if !source.IsGit(path) {
+			results, err := policy.Load(nil, "", path)
+			add(path, results, err)
+			continue
+		}
+		gitSourceURL, err := url.Parse(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policies (%w)", err)
+		}
+		pathElems := strings.Split(gitSourceURL.Path[1:], "/")
+		if len(pathElems) <= 1 {
+			return nil, fmt.Errorf("failed to parse URL (invalid URL path %s - expected https://<any_git_source_domain>/:owner/:repository/:branch (without --git-branch flag) OR https://<any_git_source_domain>/:owner/:repository/:directory (with --git-branch flag))", gitSourceURL.Path)
+		}
+		gitSourceURL.Path = strings.Join([]string{pathElems[0], pathElems[1]}, "/")
+		repoURL := gitSourceURL.String()
+		var gitPathToYamls string
+		gitBranch, gitPathToYamls = clicommon.GetGitBranchOrPolicyPaths(gitBranch, repoURL, path)
+		fs := memfs.New()
+		if _, err := gitutils.Clone(repoURL, fs, gitBranch); err != nil {
+			return nil, fmt.Errorf("failed to clone repository (%w)", err)
+		}
+		policyYamls, err := gitutils.ListYamls(fs, gitPathToYamls)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list YAMLs in repository (%w)", err)
+		}
+		for _, policyYaml := range policyYamls {
+			results, err := policy.Load(fs, "", policyYaml)
+			add(policyYaml, results, err)
+		}
+	}
+	return loaded, nil
+}
+
+// newClusterClient builds the Kyverno client of cluster mode from the kubeconfig and context of
+// config.
+func newClusterClient(ctx context.Context, config *apply.ApplyCommandConfig) (dclient.Interface, error) {
+	restConfig, err := kyvernoconfig.CreateClientConfigWithContext(config.KubeConfig, config.Context)
+	if err != nil {
+		return nil, err
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return dclient.NewClient(ctx, dynamicClient, kubeClient, 15*time.Minute)
+}
+
+// loadResourceFiles loads the resources of local manifests and URLs. Directories are read one
+// level deep for YAML files. Paths that cannot be read are skipped with a warning, as the Kyverno
+// CLI skips them, while manifests that cannot be parsed fail the call.
+func loadResourceFiles(paths []string) ([]*unstructured.Unstructured, []string, error) {
+	var resources []*unstructured.Unstructured
+	var warnings []string
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, warnings, fmt.Errorf("failed to parse %v (%w)", path, err)
+			}
+			files = files[:0]
+			for _, entry := range entries {
+				if ext := filepath.Ext(entry.Name()); ext == ".yaml" || ext == ".yml" {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		for _, file := range files {
+			data, err := resource.GetFileBytes(file)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("failed to load resources from %s: %v", file, err))
+				continue
+			}
+			loaded, err := resource.GetUnstructuredResources(data)
+			if err != nil {
+				return nil, warnings, err
+			}
+			resources = append(resources, loaded...)
+		}
+	}
+	return resources, warnings, nil
+}
+
+// applyImageValidatingPolicies evaluates ImageValidatingPolicies, which the policy processor
+// does not handle, against resources, the way the apply command of the Kyverno CLI does.
+func applyImageValidatingPolicies(
+	ctx context.Context,
+	config *apply.ApplyCommandConfig,
+	ivps []policiesv1alpha1.ImageValidatingPolicy,
+	resources []*unstructured.Unstructured,
+	celExceptions []*policiesv1alpha1.PolicyException,
+	namespaceProvider func(string) *corev1.Namespace,
+	userInfo *kyvernov2.RequestInfo,
+	rc *processor.ResultCounts,
+	dClient dclient.Interface,
+) ([]engineapi.EngineResponse, error) {
+	if len(ivps) == 0 {
+		return nil, nil
+	}
+	provider, err := ivpolengine.NewProvider(ivps, celExceptions)
+	if err != nil {
+		return nil, err
+	}
+	loaderOptions := []imagedataloader.Option{imagedataloader.WithLocalCredentials(config.RegistryAccess)}
+	var secrets k8scorev1.SecretInterface
+	if dClient != nil {
+		secrets = dClient.GetKubeClient().CoreV1().Secrets("")
+	}
+	engine := ivpolengine.NewEngine(provider, namespaceProvider, matching.NewMatcher(), secrets, loaderOptions)
+
+	var restMapper meta.RESTMapper
+	var contextProvider libs.Context
+	if dClient != nil {
+		if contextProvider, err = libs.NewContextProvider(dClient, loaderOptions, gctxstore.New()); err != nil {
+			return nil, err
+		}
+		apiGroupResources, err := restmapper.GetAPIGroupResources(dClient.GetKubeClient().Discovery())
+		if err != nil {
+			return nil, err
+		}
+		restMapper = restmapper.NewDiscoveryRESTMapper(apiGroupResources)
+	} else {
+		apiGroupResources, err := data.APIGroupResources()
+		if err != nil {
+			return nil, err
+		}
+		restMapper = restmapper.NewDiscoveryRESTMapper(apiGroupResources)
+		fakeContextProvider := libs.NewFakeContextProvider()
+		if config.ContextPath != "" {
+			cliContext, err := clicontext.Load(nil, config.ContextPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range cliContext.ContextSpec.Resources {
+				gvk := r.GroupVersionKind()
+				mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+				if err != nil {
+					return nil, err
+				}
+				if err := fakeContextProvider.AddResource(mapping.Resource, &r); err != nil {
+					return nil, err
+				}
+			}
+		}
+		contextProvider = fakeContextProvider
+	}
+
+	var user authenticationv1.UserInfo
+	if userInfo != nil {
+		user = userInfo.AdmissionUserInfo
+	}
+	var responses []engineapi.EngineResponse
+	for _, r := range resources {
+		gvk := r.GroupVersionKind()
+		mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if config.ContinueOnFail {
+				klog.V(2).InfoS("failed to map kind to resource", "kind", gvk, "error", err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to map gvk to gvr %s (%w)", gvk, err)
+		}
+		request := celengine.Request(contextProvider, gvk, mapping.Resource, "", r.GetName(), r.GetNamespace(), admissionv1.Create, user, r, nil, false, nil)
+		engineResponse, _, err := engine.HandleMutating(ctx, request)
+		if err != nil {
+			if config.ContinueOnFail {
+				klog.V(2).InfoS("failed to apply image validating policies", "resource", r.GetName(), "error", err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to apply image validating policies on resource %s (%w)", r.GetName(), err)
+		}
+		for _, p := range engineResponse.Policies {
+			response := engineapi.EngineResponse{
+				Resource:       *r,
+				PolicyResponse: engineapi.PolicyResponse{Rules: []engineapi.RuleResponse{p.Result}},
+			}.WithPolicy(engineapi.NewImageValidatingPolicy(p.Policy))
+			rc.AddValidatingPolicyResponse(response)
+			responses = append(responses, response)
+		}
+	}
+	return responses, nil
+}
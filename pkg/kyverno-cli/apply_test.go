@@ -0,0 +1,89 @@
+package kyverno
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/commands/apply"
+)
+
+func TestCheckArguments(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       apply.ApplyCommandConfig
+		hasResources bool
+		wantErr      bool
+	}{
+		{name: "resource paths", config: apply.ApplyCommandConfig{PolicyPaths: []string{"p.yaml"}, ResourcePaths: []string{"r.yaml"}}},
+		{name: "cluster", config: apply.ApplyCommandConfig{PolicyPaths: []string{"p.yaml"}, Cluster: true}},
+		{name: "listed resources", config: apply.ApplyCommandConfig{PolicyPaths: []string{"p.yaml"}}, hasResources: true},
+		{name: "no policies", config: apply.ApplyCommandConfig{ResourcePaths: []string{"r.yaml"}}, wantErr: true},
+		{name: "no resources", config: apply.ApplyCommandConfig{PolicyPaths: []string{"p.yaml"}}, wantErr: true},
+		{name: "values file and variables", config: apply.ApplyCommandConfig{PolicyPaths: []string{"p.yaml"}, Cluster: true, ValuesFile: "values.yaml", Variables: []string{"a=b"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkArguments(&tt.config, tt.hasResources); (err != nil) != tt.wantErr {
+				t.Errorf("checkArguments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadResourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	pods := write("pods.yaml", "apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: Pod\nmetadata:\n  name: b\n")
+	write("manifests/ns.yml", "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: team-a\n")
+	write("manifests/notes.txt", "not a manifest")
+	broken := write("broken.yaml", "apiVersion: v1\nkind: [\n")
+
+	tests := []struct {
+		name         string
+		paths        []string
+		wantNames    []string
+		wantWarnings int
+		wantErr      bool
+	}{
+		{name: "file", paths: []string{pods}, wantNames: []string{"a", "b"}},
+		{name: "directory", paths: []string{filepath.Join(dir, "manifests")}, wantNames: []string{"team-a"}},
+		{name: "missing file", paths: []string{filepath.Join(dir, "missing.yaml"), pods}, wantNames: []string{"a", "b"}, wantWarnings: 1},
+		{name: "invalid manifest", paths: []string{broken}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, warnings, err := loadResourceFiles(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadResourceFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("loadResourceFiles() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+			if tt.wantErr {
+				return
+			}
+			var names []string
+			for _, r := range resources {
+				names = append(names, r.GetName())
+			}
+			if len(names) != len(tt.wantNames) {
+				t.Fatalf("loadResourceFiles() = %v, want %v", names, tt.wantNames)
+			}
+			for i := range names {
+				if names[i] != tt.wantNames[i] {
+					t.Errorf("loadResourceFiles() = %v, want %v", names, tt.wantNames)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package kyverno
 
 import (
+	"strings"
 	"time"
 
 	"github.com/kyverno/kyverno/api/kyverno"
@@ -11,12 +12,27 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// BuildPolicyReportResults builds policy report results from engine responses
-func BuildPolicyReportResults(auditWarn bool, engineResponses ...engineapi.EngineResponse) []policyreportv1alpha2.PolicyReportResult {
+// PropertyExceptions is the result property listing the PolicyExceptions, as comma-separated
+// namespace/name pairs, that exempted a resource from a rule.
+const PropertyExceptions = "exceptions"
+
+// PolicyKey identifies a policy in results the way Kyverno policy reports do: namespaced
+// policies are qualified with their namespace, cluster-wide ones use their bare name.
+func PolicyKey(policy engineapi.GenericPolicy) string {
+	if policy.IsNamespaced() && policy.GetNamespace() != "" {
+		return policy.GetNamespace() + "/" + policy.GetName()
+	}
+	return policy.GetName()
+}
+
+// BuildPolicyReportResults builds policy report results from engine responses.
+// Pass and skip results are only included when includePassing is set. A clean scan yields no
+// results at all, rather than a placeholder that would be counted as skipped.
+func BuildPolicyReportResults(auditWarn, includePassing bool, engineResponses ...engineapi.EngineResponse) []policyreportv1alpha2.PolicyReportResult {
 	var results []policyreportv1alpha2.PolicyReportResult
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
 	for _, engineResponse := range engineResponses {
-		policyName := engineResponse.Policy().GetName()
+		policyName := PolicyKey(engineResponse.Policy())
 		annotations := engineResponse.Policy().GetAnnotations()
 		scored := true
 		if policyScored, ok := annotations[kyverno.AnnotationPolicyScored]; ok {
@@ -27,10 +43,12 @@ func BuildPolicyReportResults(auditWarn bool, engineResponses ...engineapi.Engin
 		category := annotations[kyverno.AnnotationPolicyCategory]
 		severity := annotations[kyverno.AnnotationPolicySeverity]
 		for _, ruleResponse := range engineResponse.PolicyResponse.Rules {
-			if ruleResponse.RuleType() != engineapi.Validation {
+			// Image verification results, from verifyImages rules and ImageValidatingPolicies,
+			// are reported alongside validation results.
+			if ruleResponse.RuleType() != engineapi.Validation && ruleResponse.RuleType() != engineapi.ImageVerify {
 				continue
 			}
-			if ruleResponse.Status() == engineapi.RuleStatusPass || ruleResponse.Status() == engineapi.RuleStatusSkip {
+			if !includePassing && (ruleResponse.Status() == engineapi.RuleStatusPass || ruleResponse.Status() == engineapi.RuleStatusSkip) {
 				continue
 			}
 			result := policyreportv1alpha2.PolicyReportResult{
@@ -47,8 +65,12 @@ func BuildPolicyReportResults(auditWarn bool, engineResponses ...engineapi.Engin
 				Message: ruleResponse.Message(),
 			}
 
-			// Determine the result status. Pass and Skip statuses are already filtered out earlier.
-			if ruleResponse.Status() == engineapi.RuleStatusError {
+			// Determine the result status. Pass and Skip statuses are only present when includePassing is set.
+			if ruleResponse.Status() == engineapi.RuleStatusPass {
+				result.Result = policyreportv1alpha2.StatusPass
+			} else if ruleResponse.Status() == engineapi.RuleStatusSkip {
+				result.Result = policyreportv1alpha2.StatusSkip
+			} else if ruleResponse.Status() == engineapi.RuleStatusError {
 				result.Result = policyreportv1alpha2.StatusError
 			} else if ruleResponse.Status() == engineapi.RuleStatusFail {
 				if !scored {
@@ -64,6 +86,14 @@ func BuildPolicyReportResults(auditWarn bool, engineResponses ...engineapi.Engin
 				// Fallback: treat any unforeseen status as an error to surface the issue clearly
 				result.Result = policyreportv1alpha2.StatusError
 			}
+			// Record the PolicyExceptions that exempted the resource from the rule
+			if ruleResponse.IsException() {
+				var names []string
+				for _, exception := range ruleResponse.Exceptions() {
+					names = append(names, exception.GetNamespace()+"/"+exception.GetName())
+				}
+				result.Properties = map[string]string{PropertyExceptions: strings.Join(names, ",")}
+			}
 			result.Source = kyverno.ValueKyvernoApp
 			result.Timestamp = now
 			result.Category = category
@@ -71,15 +101,5 @@ func BuildPolicyReportResults(auditWarn bool, engineResponses ...engineapi.Engin
 			results = append(results, result)
 		}
 	}
-	if results == nil {
-		results = []policyreportv1alpha2.PolicyReportResult{
-			{
-				Policy:  "No policies applied",
-				Rule:    "No policies applied",
-				Result:  policyreportv1alpha2.StatusSkip,
-				Message: "No policies applied",
-			},
-		}
-	}
 	return results
 }
@@ -0,0 +1,116 @@
+// Package metrics exposes Prometheus metrics about the tool calls served by kyverno-mcp.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kyverno_mcp"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Number of tool calls, by tool.",
+	}, []string{"tool"})
+
+	toolErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_errors_total",
+		Help:      "Number of failed tool calls, by tool and error code.",
+	}, []string{"tool", "code"})
+
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_duration_seconds",
+		Help:      "Duration of tool calls, by tool.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"tool"})
+
+	toolResults = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_results",
+		Help:      "Number of results, e.g. policy results or violations, returned by a tool call, by tool.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 9),
+	}, []string{"tool"})
+
+	resourcesScanned = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "resources_scanned",
+		Help:      "Number of resources evaluated by a tool call, by tool.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 9),
+	}, []string{"tool"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		toolCalls, toolErrors, toolDuration, toolResults, resourcesScanned,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveResults records the number of results returned by a call of tool.
+func ObserveResults(tool string, results int) {
+	toolResults.WithLabelValues(tool).Observe(float64(results))
+}
+
+// ObserveResourcesScanned records the number of resources evaluated by a call of tool.
+func ObserveResourcesScanned(tool string, resources int) {
+	resourcesScanned.WithLabelValues(tool).Observe(float64(resources))
+}
+
+// ToolMiddleware counts and times every tool call. Failed calls are counted by the code of the
+// error envelope they return, "internal" when the handler itself failed, and "unknown" when the
+// error is not an envelope.
+func ToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		start := time.Now()
+		result, err := next(ctx, request)
+		toolDuration.WithLabelValues(tool).Observe(time.Since(start).Seconds())
+		toolCalls.WithLabelValues(tool).Inc()
+		switch {
+		case err != nil:
+			toolErrors.WithLabelValues(tool, "internal").Inc()
+		case result != nil && result.IsError:
+			toolErrors.WithLabelValues(tool, errorCode(result)).Inc()
+		}
+		return result, err
+	}
+}
+
+// errorCode extracts the code of the error envelope of a failed tool result.
+func errorCode(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		var envelope struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if json.Unmarshal([]byte(text.Text), &envelope) == nil && envelope.Error.Code != "" {
+			return envelope.Error.Code
+		}
+	}
+	return "unknown"
+}
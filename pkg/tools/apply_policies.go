@@ -2,21 +2,31 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/nirmata/kyverno-mcp/pkg/common"
 	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+	"github.com/nirmata/kyverno-mcp/pkg/metrics"
 
 	// Add import for Kyverno engine API to filter responses
 	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
 
+	kyvernov2 "github.com/kyverno/kyverno/api/kyverno/v2"
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	clikyvernov1alpha1 "github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/apis/v1alpha1"
 	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/commands/apply"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/klog/v2"
 
 	_ "embed"
@@ -36,74 +46,527 @@ func defaultPolicies() []byte {
 	return []byte(combinedPolicy)
 }
 
-func applyPolicy(policyKey string, namespace string, gitBranch string, namespaceExclude string) (string, error) {
-	// Select the appropriate embedded policy content based on the requested key
-	var policyData []byte
-	switch policyKey {
-	case "pod-security":
-		policyData = podSecurityPolicy
-	case "rbac-best-practices":
-		policyData = rbacBestPracticesPolicy
-	case "kubernetes-best-practices":
-		policyData = kubernetesBestPracticesPolicy
-	default:
-		policyData = defaultPolicies()
-	}
-
-	// Create a uniquely named temporary file to avoid collisions between concurrent requests.
-	tmpFile, err := os.CreateTemp("", "kyverno-policy-*.yaml")
+// selectPolicies returns the documents of a multi-document policy YAML whose metadata.name is
+// in names. It fails if any requested name is not part of the set, listing the names available.
+func selectPolicies(data []byte, names map[string]struct{}) ([]byte, error) {
+	docs, err := cachedPolicies.documentsOf(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp policy file: %w", err)
+		return nil, err
+	}
+	var selected [][]byte
+	var available []string
+	found := map[string]struct{}{}
+	for _, doc := range docs {
+		available = append(available, doc.name)
+		if _, ok := names[doc.name]; ok {
+			found[doc.name] = struct{}{}
+			selected = append(selected, doc.data)
+		}
 	}
 
-	// Ensure the file is cleaned up after we have finished processing.
-	// The cleanup is deferred *after* the temp file is successfully created so that
-	// the file is always removed regardless of subsequent failures.
-	defer func(name string) {
-		_ = os.Remove(name)
-	}(tmpFile.Name())
+	var missing []string
+	for name := range names {
+		if _, ok := found[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		sort.Strings(available)
+		return nil, fmt.Errorf("policies not found in the selected policy set: %s. Available policies: %s", strings.Join(missing, ", "), strings.Join(available, ", "))
+	}
+	return bytes.Join(selected, []byte("\n---\n")), nil
+}
 
-	// Write the selected policy content to the temporary file
-	if _, err := tmpFile.Write(policyData); err != nil {
+// skippedPolicies lists the policies that were not applied because they failed validation.
+func skippedPolicies(result *kyverno.ApplyResult) []skippedPolicy {
+	skipped := make([]skippedPolicy, 0, len(result.SkippedPolicies))
+	for _, p := range result.SkippedPolicies {
+		skipped = append(skipped, skippedPolicy{Policy: p.Name, Reason: p.Reason})
+	}
+	return skipped
+}
+
+// applyOptions holds the arguments of a single apply_policies invocation.
+type applyOptions struct {
+	policySets          string
+	namespaces          common.NamespaceScope
+	gitBranch           string
+	skipControllerOwned bool
+	filter              resultFilter
+	groupBy             string
+	page                common.PageRequest
+	includePassing      bool
+	values              map[string]any
+	valuesFile          string
+	userInfo            *clikyvernov1alpha1.UserInfo
+	auditAsWarn         bool
+	exceptionPaths      []string
+	clusterExceptions   bool
+	cluster             bool
+	resourcePaths       []string
+	resources           string
+	concurrency         int
+	progress            *progressReporter
+	summaryOnly         bool
+	policies            map[string]struct{}
+	includeMutations    bool
+	includeGenerated    bool
+	registryAccess      bool
+	policyPaths         []string
+	contextPath         string
+	contextResources    *clikyvernov1alpha1.Context
+	includeTimings      bool
+	selector            resourceSelector
+	noCache             bool
+	incremental         bool
+	sample              int
+	sampleSeed          int
+}
+
+// writeTempFile writes data to a uniquely named temporary file, avoiding collisions between
+// concurrent requests, and returns its path. The caller is responsible for removing the file.
+func writeTempFile(pattern string, data []byte) (string, error) {
+	tmpFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
 		if cerr := tmpFile.Close(); cerr != nil {
 			klog.ErrorS(cerr, "failed to close temp file after write error")
 		}
-		return "", fmt.Errorf("failed to write policy data to temp file: %w", err)
+		_ = os.Remove(tmpFile.Name())
+		return "", err
 	}
 
 	// Flush the file to disk before it's used by downstream helpers
 	if err := tmpFile.Close(); err != nil {
-		return "", fmt.Errorf("failed to close temp policy file: %w", err)
+		_ = os.Remove(tmpFile.Name())
+		return "", err
 	}
+	return tmpFile.Name(), nil
+}
 
-	applyCommandConfig := &apply.ApplyCommandConfig{
-		PolicyPaths:  []string{tmpFile.Name()},
-		Cluster:      true,
-		Namespace:    namespace,
-		PolicyReport: true,
-		OutputFormat: "json",
-		GitBranch:    gitBranch,
+// parseUserInfo converts the userInfo tool argument into the admission user info format
+// loaded by the Kyverno CLI --userinfo flag.
+func parseUserInfo(arg map[string]any) (*clikyvernov1alpha1.UserInfo, error) {
+	stringSlice := func(key string) ([]string, error) {
+		raw, ok := arg[key]
+		if !ok || raw == nil {
+			return nil, nil
+		}
+		items, ok := raw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid userInfo.%s: expected an array of strings", key)
+		}
+		var out []string
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid userInfo.%s: expected an array of strings", key)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+
+	username, _ := arg["username"].(string)
+	groups, err := stringSlice("groups")
+	if err != nil {
+		return nil, err
+	}
+	roles, err := stringSlice("roles")
+	if err != nil {
+		return nil, err
+	}
+	clusterRoles, err := stringSlice("clusterRoles")
+	if err != nil {
+		return nil, err
+	}
+
+	return &clikyvernov1alpha1.UserInfo{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "cli.kyverno.io/v1alpha1",
+			Kind:       "UserInfo",
+		},
+		RequestInfo: kyvernov2.RequestInfo{
+			Roles:        roles,
+			ClusterRoles: clusterRoles,
+			AdmissionUserInfo: authenticationv1.UserInfo{
+				Username: username,
+				Groups:   groups,
+			},
+		},
+	}, nil
+}
+
+// parseContextResources builds a Kyverno CLI Context from the contextResources tool argument.
+// The resources stand in for the cluster objects, such as ConfigMaps, that CEL policies look up
+// during offline scans.
+func parseContextResources(items []any) (*clikyvernov1alpha1.Context, error) {
+	cliContext := &clikyvernov1alpha1.Context{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "cli.kyverno.io/v1alpha1",
+			Kind:       "Context",
+		},
+	}
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid contextResources[%d]: expected a Kubernetes object", i)
+		}
+		u := unstructured.Unstructured{Object: obj}
+		if u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("invalid contextResources[%d]: kind and metadata.name are required", i)
+		}
+		cliContext.Resources = append(cliContext.Resources, u)
 	}
+	return cliContext, nil
+}
 
-	result, err := kyverno.ApplyCommandHelper(applyCommandConfig)
+// valuesToVariables converts inline values into the key=value pairs accepted by the Kyverno
+// CLI --set flag, sorted by key for deterministic invocations. Strings are passed as they are;
+// numbers, booleans, lists and objects are JSON-encoded so that they keep their literal form.
+func valuesToVariables(values map[string]any) []string {
+	vars := make([]string, 0, len(values))
+	for k, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				value = fmt.Sprintf("%v", v)
+			} else {
+				value = string(raw)
+			}
+		}
+		vars = append(vars, k+"="+value)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+func applyPolicy(ctx context.Context, opts applyOptions) (string, error) {
+	start := time.Now()
+
+	policyPaths, warnings, setsByPolicy, release, err := preparePolicies(ctx, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to apply policy: %w", err)
+		return "", err
+	}
+	defer release()
+
+	var userInfoPath string
+	if opts.userInfo != nil {
+		data, err := json.Marshal(opts.userInfo)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal user info: %w", err)
+		}
+		if userInfoPath, err = writeTempFile("kyverno-userinfo-*.yaml", data); err != nil {
+			return "", fmt.Errorf("failed to write user info to temp file: %w", err)
+		}
+		defer func() {
+			_ = os.Remove(userInfoPath)
+		}()
+	}
+
+	contextPath := opts.contextPath
+	if opts.contextResources != nil {
+		data, err := json.Marshal(opts.contextResources)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal context resources: %w", err)
+		}
+		if contextPath, err = writeTempFile("kyverno-context-*.yaml", data); err != nil {
+			return "", fmt.Errorf("failed to write context resources to temp file: %w", err)
+		}
+		defer func() {
+			_ = os.Remove(contextPath)
+		}()
+	}
+
+	// Directories and patterns are expanded here, since the CLI only reads the top level of the
+	// first directory it is given.
+	var manifestPaths []string
+	if len(opts.resourcePaths) > 0 {
+		files, _, err := expandPaths(opts.resourcePaths, resourceFileExtensions)
+		if err != nil {
+			return "", fmt.Errorf("failed to load resources: %w", err)
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("no resource manifests found in resourcePaths")
+		}
+		manifestPaths = files
+	}
+	if opts.resources != "" {
+		inlinePath, err := writeTempFile("kyverno-resources-*.yaml", []byte(opts.resources))
+		if err != nil {
+			return "", fmt.Errorf("failed to write resources to temp file: %w", err)
+		}
+		defer func() {
+			_ = os.Remove(inlinePath)
+		}()
+		manifestPaths = append(manifestPaths, inlinePath)
 	}
 
-	// Build a set of namespaces to exclude from the policy report results.
-	excludedNS := common.ParseNamespaceExcludes(namespaceExclude)
+	// Follow-up calls about the same cluster scan, e.g. for another page or filter, reuse its
+	// result instead of scanning the cluster again. Local manifests may change between calls.
+	var key string
+	if opts.cluster && len(manifestPaths) == 0 {
+		key = scanKey(ctx, opts, policyPaths)
+	}
+	var scan cachedScan
+	var cached bool
+	if key != "" && ScanCacheTTL > 0 && !opts.noCache && !opts.incremental {
+		scan, cached = scanResults.get(key)
+	}
+	var cachedAt string
+	var incremental *incrementalSummary
+	if cached {
+		cachedAt = scan.at.UTC().Format(time.RFC3339)
+		clientLog(ctx, mcp.LoggingLevelInfo, "reusing cached scan", "cachedAt", cachedAt)
+		warnings = append(warnings, fmt.Sprintf("results are from a scan of %s ago; set noCache to scan the cluster again", time.Since(scan.at).Round(time.Second)))
+	} else {
+		var result *kyverno.ApplyResult
+		var scanWarnings []string
+		var err error
+		var sampling *samplingSummary
+		switch {
+		case opts.sample > 0 && key != "":
+			result, scanWarnings, sampling, err = sampleScan(ctx, opts, policyPaths, userInfoPath, contextPath)
+		case opts.incremental && key != "":
+			result, scanWarnings, incremental, err = incrementalScan(ctx, opts, key, policyPaths, userInfoPath, contextPath)
+		default:
+			result, scanWarnings, err = runScan(ctx, opts, policyPaths, manifestPaths, userInfoPath, contextPath)
+		}
+		if err != nil {
+			return "", err
+		}
+		scan = cachedScan{result: result, warnings: scanWarnings, sampling: sampling, at: time.Now()}
+		// Incremental results build on a baseline of their own and must not be served as the
+		// result of a full scan
+		if key != "" && ScanCacheTTL > 0 && !opts.incremental {
+			scanResults.put(key, scan)
+		}
+	}
+	result := scan.result
+	warnings = append(warnings, scan.warnings...)
+	warnings = append(warnings, result.Warnings...)
 
-	// Filter out engine responses that belong to excluded namespaces.
+	// Filter out engine responses that belong to namespaces outside the requested scope, and
+	// optionally those for controller-owned Pods/ReplicaSets so that each finding is reported
+	// once against its workload.
 	var filteredEngineResponses []engineapi.EngineResponse
+	ownership := newControllerOwnership(result.Unstructured)
+	duplicated, reported := map[string]struct{}{}, map[string]struct{}{}
 	for _, er := range result.EngineResponses {
-		if _, found := excludedNS[er.Resource.GetNamespace()]; found {
+		if !opts.namespaces.Includes(er.Resource.GetNamespace()) {
+			continue
+		}
+		// Embedded policy sets are narrowed down before the scan; caller-supplied ones afterwards.
+		if len(opts.policyPaths) > 0 && len(opts.policies) > 0 {
+			if er.Policy() == nil {
+				continue
+			}
+			// Namespaced policies can be selected by name or by namespace/name
+			_, byName := opts.policies[er.Policy().GetName()]
+			_, byKey := opts.policies[kyverno.PolicyKey(er.Policy())]
+			if !byName && !byKey {
+				continue
+			}
+		}
+		if !opts.selector.matches(er.Resource) {
 			continue
 		}
+		if opts.skipControllerOwned && ownership.duplicates(er) {
+			duplicated[identifierOf(er.Resource)] = struct{}{}
+			continue
+		}
+		reported[identifierOf(er.Resource)] = struct{}{}
 		filteredEngineResponses = append(filteredEngineResponses, er)
 	}
 
-	results := kyverno.BuildPolicyReportResults(false, filteredEngineResponses...)
-	jsonResults, err := json.MarshalIndent(results, "", "  ")
+	// Count the scanned resources and applied policies after the same exclusions.
+	resourcesScanned := 0
+	for _, u := range result.Unstructured {
+		if u == nil {
+			continue
+		}
+		if !opts.namespaces.Includes(u.GetNamespace()) {
+			continue
+		}
+		if !opts.selector.matches(*u) {
+			continue
+		}
+		// Resources whose findings were all reported against their controller are not counted
+		id := identifierOf(*u)
+		if _, ok := duplicated[id]; ok {
+			if _, ok := reported[id]; !ok {
+				continue
+			}
+		}
+		resourcesScanned++
+	}
+	policiesApplied := map[string]struct{}{}
+	rulesApplied := map[[2]string]struct{}{}
+	exempted := 0
+	for _, er := range filteredEngineResponses {
+		if er.Policy() != nil {
+			policiesApplied[kyverno.PolicyKey(er.Policy())] = struct{}{}
+		}
+		for _, rule := range er.PolicyResponse.Rules {
+			if er.Policy() != nil {
+				rulesApplied[[2]string{kyverno.PolicyKey(er.Policy()), rule.Name()}] = struct{}{}
+			}
+			if rule.IsException() {
+				exempted++
+			}
+		}
+	}
+
+	results := dedupeResults(kyverno.BuildPolicyReportResults(opts.auditAsWarn, opts.includePassing, filteredEngineResponses...))
+
+	// Apply the severity and category filters requested by the caller.
+	filtered := make([]policyreportv1alpha2.PolicyReportResult, 0, len(results))
+	for _, r := range results {
+		if opts.filter.matches(r.Severity, r.Category) {
+			filtered = append(filtered, r)
+		}
+	}
+	results = filtered
+
+	// Order results by resource, policy and rule so that pagination cursors remain stable
+	// across repeated scans of an unchanged cluster.
+	common.SortStable(results, func(r policyreportv1alpha2.PolicyReportResult) string {
+		f := policyReportResultGroupFields(r)
+		return strings.Join([]string{f.resource, f.policy, r.Rule}, "\x00")
+	})
+
+	var counts resultSummary
+	for _, r := range results {
+		counts.add(r.Result)
+	}
+	var sampling *samplingSummary
+	if scan.sampling != nil {
+		sampling = scan.sampling.estimate(results)
+	}
+	// Reused scans were recorded when they ran
+	if !cached {
+		recordScan(ctx, opts, results, resourcesScanned, len(policiesApplied), time.Since(start))
+	}
+	metrics.ObserveResourcesScanned("apply_policies", resourcesScanned)
+	metrics.ObserveResults("apply_policies", len(results))
+
+	skipped := skippedPolicies(result)
+
+	if opts.summaryOnly {
+		breakdown := newCountsBreakdown()
+		for _, r := range results {
+			f := policyReportResultGroupFields(r)
+			breakdown.add(f.policy, f.namespace, r.Result)
+		}
+		breakdown.Warnings = warnings
+		breakdown.Sampling = sampling
+		if reachedResourceLimit(result) {
+			breakdown.ResourceLimit = MaxScanResources
+		}
+		for _, p := range skipped {
+			breakdown.Warnings = append(breakdown.Warnings, fmt.Sprintf("policy %s was not applied: %s", p.Policy, p.Reason))
+		}
+		jsonSummary, err := json.MarshalIndent(breakdown, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal scan summary: %w", err)
+		}
+		return string(jsonSummary), nil
+	}
+
+	total := len(results)
+	withResults := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		if len(r.Resources) > 0 {
+			withResults[resourceIdentifier(r.Resources[0])] = struct{}{}
+		}
+	}
+	var nextCursor string
+	if opts.page.Enabled() {
+		results, nextCursor = common.Paginate(results, opts.page)
+	}
+
+	enriched := withRemediations(results, policyRemediations(filteredEngineResponses))
+	if setsByPolicy != nil {
+		withFlaggedBy(enriched, setsByPolicy)
+	}
+
+	summary := scanSummary{
+		resultSummary:    counts,
+		ResourcesScanned: resourcesScanned,
+		PoliciesApplied:  len(policiesApplied),
+		RulesApplied:     len(rulesApplied),
+		Exempted:         exempted,
+		Duration:         time.Since(start).Round(time.Millisecond).String(),
+		CachedAt:         cachedAt,
+		Incremental:      incremental,
+		Sampling:         sampling,
+	}
+	if rc := result.ResultCounts; rc != nil {
+		summary.Engine = &resultSummary{Pass: rc.Pass, Fail: rc.Fail, Warn: rc.Warn, Error: rc.Error, Skip: rc.Skip}
+	}
+	if reachedResourceLimit(result) {
+		summary.ResourceLimit = MaxScanResources
+	}
+
+	envelope := resultsEnvelope{Summary: summary, Total: total, NextCursor: nextCursor, Warnings: warnings, SkippedPolicies: skipped}
+
+	// Results are cut down to what fits up front, since groups are marshalled as a whole and the
+	// previews of the page take the room the results leave
+	fit, used, err := fitWithin(enriched, MaxResultBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal policy report results: %w", err)
+	}
+	if fit < len(enriched) {
+		truncateResults(&envelope, fit, len(enriched), opts.page.Offset)
+		enriched = enriched[:fit]
+	}
+	if opts.includeMutations || opts.includeGenerated {
+		responses := pageResponses(filteredEngineResponses, enriched, withResults, envelope.NextCursor == "")
+		if opts.includeMutations {
+			previews := mutationPreviews(responses)
+			n, size, err := fitWithin(previews, MaxResultBytes-used)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal mutation previews: %w", err)
+			}
+			if n < len(previews) {
+				envelope.Warnings = append(envelope.Warnings, previewsTruncated("mutation previews", n, len(previews)))
+			}
+			envelope.Mutations = previews[:n]
+			used += size
+		}
+		if opts.includeGenerated {
+			previews := generatePreviews(responses)
+			n, _, err := fitWithin(previews, MaxResultBytes-used)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal generated previews: %w", err)
+			}
+			if n < len(previews) {
+				envelope.Warnings = append(envelope.Warnings, previewsTruncated("generated resources", n, len(previews)))
+			}
+			envelope.Generated = previews[:n]
+		}
+	}
+	if opts.includeTimings {
+		envelope.Timings = ruleTimings(filteredEngineResponses)
+	}
+
+	if opts.groupBy == "" {
+		jsonResults, err := encodeEnvelope(envelope, enriched, opts.page.Offset)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal policy report results: %w", err)
+		}
+		return jsonResults, nil
+	}
+
+	envelope.Results = groupResults(enriched, func(r scanResult) string {
+		return groupKey(opts.groupBy, policyReportResultGroupFields(r.PolicyReportResult))
+	})
+	jsonResults, err := json.MarshalIndent(envelope, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal policy report results: %w", err)
 	}
@@ -111,27 +574,306 @@ func applyPolicy(policyKey string, namespace string, gitBranch string, namespace
 	return string(jsonResults), nil
 }
 
+// preparePolicies writes the policies selected by opts, from policyPaths or the embedded and
+// installed policy sets, to files for the Kyverno CLI and returns their paths, with warnings about
+// skipped documents and, when several policy sets were requested, the sets containing each
+// policy. The returned function releases the files.
+func preparePolicies(ctx context.Context, opts applyOptions) (policyPaths, warnings []string, setsByPolicy map[string][]string, release func(), err error) {
+	// Policies supplied by the caller replace the embedded policy sets
+	release = func() {}
+	if len(opts.policyPaths) > 0 {
+		var data []byte
+		var passthrough []string
+		data, passthrough, warnings, err = loadPolicyFiles(ctx, opts.policyPaths)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("failed to load policies: %w", err)
+		}
+		if len(data) > 0 {
+			var policyPath string
+			policyPath, release, err = cachedPolicies.file("policyPaths", data)
+			if err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("failed to write policy data to temp file: %w", err)
+			}
+			policyPaths = append(policyPaths, policyPath)
+		}
+		policyPaths = append(policyPaths, passthrough...)
+		if len(policyPaths) == 0 {
+			return nil, nil, nil, nil, fmt.Errorf("no policies found in policyPaths")
+		}
+	} else {
+		// Policies contained in several of the requested sets are only evaluated once
+		var sets []string
+		if sets, err = parsePolicySets(opts.policySets); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		var policyData []byte
+		if policyData, setsByPolicy, err = loadPolicySets(ctx, sets); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if len(sets) == 1 {
+			setsByPolicy = nil
+		}
+
+		if len(opts.policies) > 0 {
+			if policyData, err = selectPolicies(policyData, opts.policies); err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+
+		// Repeated scans with the same policies reuse the file written by the first one
+		var policyPath string
+		policyPath, release, err = cachedPolicies.file(opts.policySets, policyData)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("failed to write policy data to temp file: %w", err)
+		}
+		policyPaths = []string{policyPath}
+	}
+	return policyPaths, warnings, setsByPolicy, release, nil
+}
+
+// scanConfig builds the Kyverno CLI configuration of a scan. Exceptions installed in the cluster
+// are written to a temporary file, which the returned function removes.
+func scanConfig(ctx context.Context, opts applyOptions, policyPaths, manifestPaths []string, userInfoPath, contextPath string) (*apply.ApplyCommandConfig, func(), error) {
+	// In cluster mode the Kyverno CLI interprets ResourcePaths as resource names to select from
+	// the cluster, so local manifests are scanned in a separate offline pass instead.
+	var resourcePaths []string
+	if !opts.cluster {
+		resourcePaths = manifestPaths
+	}
+
+	// The CLI reads exception paths as single files, so directories and patterns are expanded here
+	exceptionPaths, _, err := expandPaths(opts.exceptionPaths, resourceFileExtensions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load exceptions: %w", err)
+	}
+	cleanup := func() {}
+	if opts.cluster && opts.clusterExceptions {
+		exceptionsPath, err := writeClusterExceptions(ctx)
+		if err != nil {
+			// Exceptions only reduce false positives, so a failure to fetch them must not block the scan.
+			klog.ErrorS(err, "failed to load PolicyExceptions from cluster")
+			clientLog(ctx, mcp.LoggingLevelWarning, "failed to load PolicyExceptions from cluster, scanning without them", "error", err.Error())
+		} else if exceptionsPath != "" {
+			cleanup = func() {
+				_ = os.Remove(exceptionsPath)
+			}
+			exceptionPaths = append(append([]string{}, exceptionPaths...), exceptionsPath)
+		}
+	}
+
+	// The Kyverno CLI builds its cluster clients from a kubeconfig, so ServiceAccount tokens and
+	// impersonation are passed in a kubeconfig of its own
+	var kubeConfigPath string
+	if opts.cluster {
+		if kubeConfigPath, err = common.WriteKubeConfig(ctx); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to write kubeconfig for the Kyverno CLI: %w", err)
+		}
+		if kubeConfigPath != "" {
+			removeExceptions := cleanup
+			cleanup = func() {
+				removeExceptions()
+				_ = os.Remove(kubeConfigPath)
+			}
+		}
+	}
+
+	// A single targeted namespace is passed to the Kyverno CLI; otherwise resources are fetched
+	// per namespace below, or every local manifest is loaded and filtered by namespace afterwards.
+	singleNamespace, _ := opts.namespaces.Single()
+
+	applyCommandConfig := &apply.ApplyCommandConfig{
+		PolicyPaths:    policyPaths,
+		ResourcePaths:  resourcePaths,
+		Cluster:        opts.cluster,
+		Namespace:      singleNamespace,
+		PolicyReport:   true,
+		OutputFormat:   "json",
+		GitBranch:      opts.gitBranch,
+		Variables:      valuesToVariables(opts.values),
+		ValuesFile:     opts.valuesFile,
+		UserInfoPath:   userInfoPath,
+		ContextPath:    contextPath,
+		AuditWarn:      opts.auditAsWarn,
+		Exception:      exceptionPaths,
+		RegistryAccess: opts.registryAccess,
+		Context:        common.KubeContext(ctx),
+	}
+	if kubeConfigPath != "" {
+		// The written kubeconfig only holds the selected context, as its current context
+		applyCommandConfig.KubeConfig = kubeConfigPath
+		applyCommandConfig.Context = ""
+	}
+	return applyCommandConfig, cleanup, nil
+}
+
+// runScan runs the Kyverno CLI over the cluster namespaces or local manifests selected by opts
+// and returns the merged result, together with warnings about the slices that failed.
+func runScan(ctx context.Context, opts applyOptions, policyPaths, manifestPaths []string, userInfoPath, contextPath string) (*kyverno.ApplyResult, []string, error) {
+	applyCommandConfig, cleanup, err := scanConfig(ctx, opts, policyPaths, manifestPaths, userInfoPath, contextPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+	resourcePaths := applyCommandConfig.ResourcePaths
+
+	var warnings []string
+	clientLog(ctx, mcp.LoggingLevelInfo, "scan started", "cluster", opts.cluster, "policySets", opts.policySets)
+	var result *kyverno.ApplyResult
+	if opts.cluster {
+		// Scan each namespace separately on a bounded worker pool rather than in a single
+		// serial run. Excluded namespaces are skipped up front instead of being filtered later.
+		namespaces := opts.namespaces.Namespaces
+		if opts.namespaces.All {
+			allNamespaces, err := common.ListNamespaces(ctx)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to list namespaces: %w", err)
+			}
+			namespaces = nil
+			for _, ns := range allNamespaces {
+				if opts.namespaces.Includes(ns) {
+					namespaces = append(namespaces, ns)
+				}
+			}
+		}
+		// The kinds the policies match are counted against the limit of resources per scan, and
+		// listed for the cluster-scoped resources when every namespace is scanned
+		kinds, kindWarnings, err := matchedKinds(ctx, policyPaths)
+		warnings = append(warnings, kindWarnings...)
+		if err != nil {
+			klog.V(2).InfoS("cannot resolve the kinds matched by the policies", "error", err)
+			if opts.namespaces.All {
+				warnings = append(warnings, fmt.Sprintf("skipped cluster-scoped resources: %v", err))
+			}
+		}
+		var failures []error
+		result, failures, err = scanNamespaces(ctx, *applyCommandConfig, opts, namespaces, kinds)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply policy: %w", err)
+		}
+		for _, failure := range failures {
+			warnings = append(warnings, fmt.Sprintf("skipped %v", failure))
+		}
+	} else if len(resourcePaths) > 1 {
+		var failures []error
+		result, failures, err = scanPaths(ctx, *applyCommandConfig, resourcePaths, opts.concurrency, opts.progress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply policy: %w", err)
+		}
+		for _, failure := range failures {
+			warnings = append(warnings, fmt.Sprintf("skipped %v", failure))
+		}
+	} else {
+		stopHeartbeat := opts.progress.heartbeat(ctx, "scanning resources")
+		result, err = tracedApply(ctx, "scan", applyCommandConfig, nil)
+		stopHeartbeat()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply policy: %w", err)
+		}
+		opts.progress.step(ctx, 1, fmt.Sprintf("scan complete (%d resources evaluated): %s", len(result.Unstructured), formatResultCounts(result.ResultCounts)))
+	}
+
+	// Local manifests supplied together with a cluster scan are evaluated offline and reported
+	// alongside the cluster resources.
+	if opts.cluster && len(manifestPaths) > 0 {
+		offlineConfig := *applyCommandConfig
+		offlineConfig.Cluster = false
+		offlineConfig.Namespace = ""
+		offlineConfig.ResourcePaths = nil
+		localResult, failures, err := scanPaths(ctx, offlineConfig, manifestPaths, opts.concurrency, opts.progress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply policy to local manifests: %w", err)
+		}
+		for _, failure := range failures {
+			warnings = append(warnings, fmt.Sprintf("skipped %v", failure))
+		}
+		mergeApplyResult(result, localResult)
+	}
+
+	capResources(result, opts.auditAsWarn)
+	if reachedResourceLimit(result) {
+		warnings = append(warnings, resourceLimitWarning())
+	}
+	return result, warnings, nil
+}
+
 func ApplyPolicies(s *server.MCPServer) {
 	klog.InfoS("Registering tool: apply_policies")
 	applyPoliciesTool := mcp.NewTool(
 		"apply_policies",
-		mcp.WithDescription(`Scan the cluster resources for policy violations with provided policies or default policy sets. Use "all" to scan all namespaces. If no namespace is provided i.e. "", the policies will be applied to the default namespace.`),
-		mcp.WithString("policySets", mcp.Description(`Policy set key: pod-security, rbac-best-practices, kubernetes-best-practices, all (default: all).`)),
-		mcp.WithString("namespace", mcp.Description(`Namespace to apply policies to (default: default)`)),
+		mcp.WithDescription(`Scan the cluster resources for policy violations with provided policies or default policy sets. Use "all" to scan all namespaces or a comma-separated list to scan several. If no namespace is provided i.e. "", the policies will be applied to the default namespace (or to every local manifest when cluster is false). Set cluster to false to scan local manifests without a cluster. Returns {summary: {pass, fail, warn, error, skip, resourcesScanned, policiesApplied, rulesApplied, exempted, duration, engine}, results, total, nextCursor, truncated}, where engine holds the raw counts of the Kyverno engine before any filtering. When the results would exceed the response size limit they are truncated, truncated is set and nextCursor fetches the rest. Scans stop at the limit of resources per scan of the server, in which case summary.resourceLimit is set and the namespaces left out are listed in warnings. Results exempted by a PolicyException are reported as skipped, with the exceptions listed in their properties. Policies that failed validation and were not applied are listed in skippedPolicies with the reason.`),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Apply Kyverno policies",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithString("policySets", mcp.Description(`Policy set key: pod-security, rbac-best-practices, kubernetes-best-practices, all, or installed to evaluate the ClusterPolicies and Policies currently installed in the cluster, or a comma-separated list of them such as "pod-security,installed". Findings of policies contained in several of the sets are reported once, with the sets containing the policy in flaggedBy (default: all).`)),
+		mcp.WithString("policies", mcp.Description(`Comma-separated names of policies to apply from the selected policy set or policyPaths, e.g. "disallow-privileged-containers". Namespaced Policies from policyPaths can also be selected as namespace/name (default: all policies in the set)`)),
+		mcp.WithArray("policyPaths", mcp.Description(`Paths on the server to YAML or JSON policy manifests or directories, glob patterns such as "policies/**/*.yaml", HTTPS URLs, or OCI images pushed with "kyverno oci push" such as "oci://ghcr.io/org/policies:v1", to apply instead of the embedded policy sets. Directories are read recursively and documents that are not policies are skipped with a warning. Kyverno ClusterPolicies and Policies, ValidatingAdmissionPolicies and CEL-based ValidatingPolicies and ImageValidatingPolicies (policies.kyverno.io) are supported`), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("namespace", mcp.Description(`Namespace to apply policies to, a comma-separated list of namespaces (e.g. "team-a,team-b"), or "all" for all namespaces (default: default)`)),
 		mcp.WithString("gitBranch", mcp.Description(`Git branch to apply policies from (default: main)`)),
-		mcp.WithString("namespace_exclude", mcp.Description(`Namespace to exclude from applying policies to (default: kube-system, kyverno)`)),
+		mcp.WithString("namespace_exclude", mcp.Description(`Comma-separated namespaces or regular expressions such as "kube-.*" to exclude when namespace="all" (default: kube-system,kyverno)`)),
+		mcp.WithBoolean("skipControllerOwned", mcp.Description(`Drop results for Pods and ReplicaSets whose controller is part of the scan and matched by the autogenerated rules of the policy, reporting them only for the owning workload (default: true)`), mcp.DefaultBool(true)),
+		mcp.WithString("minSeverity", mcp.Description(`Only return results at or above this severity: info, low, medium, high, critical (default: all severities)`), mcp.Enum("info", "low", "medium", "high", "critical")),
+		mcp.WithString("labelSelector", mcp.Description(`Only scan resources matching this label selector, e.g. "app=web,tier!=cache". Cluster resources are listed with the selector and evaluated without cluster lookups, like incremental scans (default: all resources)`)),
+		mcp.WithString("fieldSelector", mcp.Description(`Only scan resources matching this field selector on metadata.name or metadata.namespace, e.g. "metadata.name!=legacy". Cluster resources are listed with the selector and evaluated without cluster lookups, like incremental scans (default: all resources)`)),
+		mcp.WithString("categories", mcp.Description(`Comma-separated policy categories to include, e.g. "Pod Security Standards (Baseline)" (default: all categories)`)),
+		mcp.WithString("groupBy", mcp.Description(`Return results as a JSON object grouped by policy, resource, namespace, kind or severity instead of a flat array (default: no grouping)`), mcp.Enum(groupByValues...)),
+		mcp.WithNumber("limit", mcp.Description(`Maximum number of results to return; fetch further pages with the returned nextCursor (default: no limit)`)),
+		mcp.WithString("cursor", mcp.Description(`Opaque cursor returned as nextCursor by a previous call, used to fetch the next page of results`)),
+		mcp.WithString("continue", mcp.Description(`Alias of cursor, for clients used to Kubernetes list pagination`)),
+		mcp.WithBoolean("includeMutations", mcp.Description(`Also return a preview of the changes mutate rules would make to each resource, as JSON patches and the patched resource. Previews cover the resources whose results are on the returned page; those of resources without results come with the last page. Nothing is written to the cluster (default: false)`)),
+		mcp.WithBoolean("includeGenerated", mcp.Description(`Also return the resources generate rules would create for the evaluated trigger resources, with their kind, name, namespace and contents. Previews cover the trigger resources whose results are on the returned page; those of resources without results come with the last page. Nothing is written to the cluster (default: false)`)),
+		mcp.WithBoolean("includeTimings", mcp.Description(`Also return how long each policy and rule took to evaluate, slowest rules first, to find rules that are expensive to enforce at admission (default: false)`)),
+		mcp.WithBoolean("includePassing", mcp.Description(`Also return pass and skip results to demonstrate compliance, and count them in the summary (default: false)`)),
+		mcp.WithObject("values", mcp.Description(`Variables to set for policy evaluation, equivalent to "kyverno apply --set key=value", e.g. {"request.operation": "CREATE"}`)),
+		mcp.WithString("valuesFile", mcp.Description(`Path to a Kyverno values file on the server providing policy, global and namespace-selector variables`)),
+		mcp.WithObject("userInfo",
+			mcp.Description(`Simulated admission request user for policies that match on request.userInfo, e.g. {"username": "alice", "groups": ["system:masters"]}`),
+			mcp.Properties(map[string]any{
+				"username":     map[string]any{"type": "string", "description": "Requesting user name"},
+				"groups":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Groups the user belongs to"},
+				"roles":        map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Roles bound to the user, as namespace:name"},
+				"clusterRoles": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "ClusterRoles bound to the user"},
+			}),
+		),
+		mcp.WithArray("contextResources", mcp.Description(`Kubernetes objects, such as ConfigMaps, served to the resource lookups of CEL policies instead of live cluster objects, so such policies can be evaluated offline. Context entries of Kyverno ClusterPolicies are resolved against the cluster in cluster mode and from values or valuesFile otherwise`), mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithString("contextPath", mcp.Description(`Path on the server to a Kyverno CLI Context file (cli.kyverno.io/v1alpha1) with the objects served to the resource lookups of CEL policies. Mutually exclusive with contextResources`)),
+		mcp.WithBoolean("registryAccess", mcp.Description(`Allow policies to contact image registries, which verifyImages rules, including keyless verification, and image data lookups require. Credentials are taken from the Docker config set with the server's --registry-config flag (~/.docker by default) and cloud credential helpers (default: false)`)),
+		mcp.WithBoolean("auditAsWarn", mcp.Description(`Report failures of policies in Audit mode as warnings, matching how the cluster treats them (default: false)`)),
+		mcp.WithArray("exceptionPaths", mcp.Description(`Paths on the server to PolicyException manifests, directories or glob patterns to honor during the scan, like "kyverno apply --exceptions". Kyverno and CEL PolicyExceptions are supported`), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("clusterExceptions", mcp.Description(`Honor the PolicyExceptions installed in the cluster so exempted resources are not reported (default: true)`), mcp.DefaultBool(true)),
+		mcp.WithBoolean("cluster", mcp.Description(`Scan live cluster resources. Set to false to scan only the supplied resourcePaths and resources, without a kubeconfig (default: true)`), mcp.DefaultBool(true)),
+		mcp.WithArray("resourcePaths", mcp.Description(`Paths on the server to YAML or JSON resource manifests, directories, which are read recursively, or glob patterns such as "manifests/**/*.yaml". They are scanned instead of the cluster when cluster is false, and in addition to the cluster resources otherwise`), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("resources", mcp.Description(`Inline YAML or JSON resource manifests to scan, instead of the cluster when cluster is false and in addition to the cluster resources otherwise: multiple documents separated by ---, a JSON array of objects, or a List such as the output of "kubectl get -o json"`)),
+		mcp.WithNumber("concurrency", mcp.Description(`Maximum number of namespaces, or resource paths when cluster is false, scanned in parallel (default: 4)`)),
+		mcp.WithBoolean("summaryOnly", mcp.Description(`Return only result counts overall, per policy and per namespace instead of individual results. Useful as a first pass before drilling into details (default: false)`)),
+		mcp.WithBoolean("incremental", mcp.Description(`Only evaluate the cluster resources created or changed since the previous incremental scan of the same namespaces with the same policies, according to their resourceVersion, and merge them with its results. The first incremental scan is a full scan. Useful in remediation loops; changed resources are evaluated without cluster lookups, and kinds absent from the first scan are not picked up, so run a full scan to confirm (default: false)`)),
+		mcp.WithNumber("sample", mcp.Description(`Evaluate at most this many resources of every kind and namespace instead of all of them, and report in summary.sampling the result counts extrapolated to every resource. A quick health read on clusters too large for full scans; sampled resources are evaluated without cluster lookups (default: 0, scan everything)`)),
+		mcp.WithNumber("sampleSeed", mcp.Description(`Seed selecting the sampled resources; the same seed samples the same resources of an unchanged cluster (default: 0)`)),
+		asArgument,
+		asGroupsArgument,
+		mcp.WithBoolean("noCache", mcp.Description(`Scan the cluster again instead of reusing the result of a recent scan of the same namespaces with the same policies. Recent scans are reused for a short time so that follow-up calls, e.g. for another page, filter or grouping, are fast (default: false)`)),
 	)
 
-	s.AddTool(applyPoliciesTool, func(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.AddTool(applyPoliciesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args, ok := request.Params.Arguments.(map[string]any)
 		if !ok {
-			return mcp.NewToolResultError("Error: invalid arguments format"), nil
+			return invalidArgument("arguments must be a JSON object"), nil
+		}
+		if result := serverPathsDenied(ctx, request, "policyPaths", "resourcePaths", "exceptionPaths", "valuesFile", "contextPath"); result != nil {
+			return result, nil
 		}
 
 		policySets := "all"
 		if args["policySets"] != nil {
 			policySets = args["policySets"].(string)
 		}
+		if _, err := parsePolicySets(policySets); err != nil {
+			return invalidArgument("%v", err), nil
+		}
 
 		namespace := ""
 		if args["namespace"] != nil {
@@ -143,15 +885,203 @@ func ApplyPolicies(s *server.MCPServer) {
 			gitBranch = args["gitBranch"].(string)
 		}
 
-		namespaceExclude := "kube-system,kyverno"
+		namespaceExclude := common.DefaultNamespaceExcludes
 		if args["namespace_exclude"] != nil {
 			namespaceExclude = args["namespace_exclude"].(string)
 		}
 
-		results, err := applyPolicy(policySets, namespace, gitBranch, namespaceExclude)
+		skipControllerOwned := true
+		if v, ok := args["skipControllerOwned"].(bool); ok {
+			skipControllerOwned = v
+		}
+
+		minSeverity, _ := args["minSeverity"].(string)
+		categories, _ := args["categories"].(string)
+		filter, err := newResultFilter(minSeverity, categories)
+		if err != nil {
+			return invalidArgument("%v", err), nil
+		}
+
+		labelSelector, _ := args["labelSelector"].(string)
+		fieldSelector, _ := args["fieldSelector"].(string)
+		selector, err := newResourceSelector(labelSelector, fieldSelector)
+		if err != nil {
+			return invalidArgument("%v", err), nil
+		}
+
+		groupBy, _ := args["groupBy"].(string)
+		if err := validateGroupBy(groupBy); err != nil {
+			return invalidArgument("%v", err), nil
+		}
+
+		limit, _ := args["limit"].(float64)
+		cursor, _ := args["cursor"].(string)
+		cont, _ := args["continue"].(string)
+		page, err := common.NewPageRequest(int(limit), cursor, cont)
+		if err != nil {
+			return invalidArgument("%v", err), nil
+		}
+
+		includePassing, _ := args["includePassing"].(bool)
+		includeMutations, _ := args["includeMutations"].(bool)
+		includeGenerated, _ := args["includeGenerated"].(bool)
+		includeTimings, _ := args["includeTimings"].(bool)
+
+		values, _ := args["values"].(map[string]any)
+		valuesFile, _ := args["valuesFile"].(string)
+		if valuesFile != "" {
+			if _, err := os.Stat(valuesFile); err != nil {
+				return errorResult(fmt.Errorf("invalid valuesFile: %w", err)), nil
+			}
+		}
+
+		var userInfo *clikyvernov1alpha1.UserInfo
+		if arg, ok := args["userInfo"].(map[string]any); ok {
+			if userInfo, err = parseUserInfo(arg); err != nil {
+				return invalidArgument("%v", err), nil
+			}
+		}
+
+		var contextResources *clikyvernov1alpha1.Context
+		if items, ok := args["contextResources"].([]any); ok && len(items) > 0 {
+			if contextResources, err = parseContextResources(items); err != nil {
+				return invalidArgument("%v", err), nil
+			}
+		}
+		contextPath, _ := args["contextPath"].(string)
+		if contextPath != "" {
+			if contextResources != nil {
+				return invalidArgument("contextPath and contextResources are mutually exclusive"), nil
+			}
+			if _, err := os.Stat(contextPath); err != nil {
+				return errorResult(fmt.Errorf("invalid contextPath: %w", err)), nil
+			}
+		}
+
+		auditAsWarn, _ := args["auditAsWarn"].(bool)
+		registryAccess, _ := args["registryAccess"].(bool)
+
+		exceptionPaths := request.GetStringSlice("exceptionPaths", nil)
+		if err := validateExceptionPaths(exceptionPaths); err != nil {
+			return errorResult(err), nil
+		}
+
+		clusterExceptions := true
+		if v, ok := args["clusterExceptions"].(bool); ok {
+			clusterExceptions = v
+		}
+
+		cluster := true
+		if v, ok := args["cluster"].(bool); ok {
+			cluster = v
+		}
+
+		policyPaths := request.GetStringSlice("policyPaths", nil)
+		for _, p := range policyPaths {
+			if isRemotePath(p) || isOCIPath(p) || isGlobPattern(p) {
+				continue
+			}
+			if _, err := os.Stat(p); err != nil {
+				return errorResult(fmt.Errorf("invalid policyPaths entry: %w", err)), nil
+			}
+		}
+
+		resourcePaths := request.GetStringSlice("resourcePaths", nil)
+		for _, p := range resourcePaths {
+			if isGlobPattern(p) {
+				continue
+			}
+			if _, err := os.Stat(p); err != nil {
+				return errorResult(fmt.Errorf("invalid resourcePaths entry: %w", err)), nil
+			}
+		}
+		resources, _ := args["resources"].(string)
+		if !cluster && len(resourcePaths) == 0 && strings.TrimSpace(resources) == "" {
+			return invalidArgument("resourcePaths or resources is required when cluster is false"), nil
+		}
+		if strings.TrimSpace(resources) != "" {
+			normalized, err := normalizeInlineResources(resources)
+			if err != nil {
+				return invalidArgument("invalid resources: %v", err), nil
+			}
+			resources = string(normalized)
+		}
+
+		// Offline scans cover every supplied manifest unless namespaces are requested explicitly.
+		if !cluster && strings.TrimSpace(namespace) == "" {
+			namespace = common.AllNamespaces
+		}
+		namespaces, err := common.ResolveNamespaces(namespace, namespaceExclude)
+		if err != nil {
+			return invalidArgument("%v", err), nil
+		}
+		if cluster {
+			if err := namespaces.Validate(ctx); err != nil {
+				return notFound("list the namespaces of the cluster and pick existing ones", "%v", err), nil
+			}
+		}
+
+		concurrency, _ := args["concurrency"].(float64)
+		summaryOnly, _ := args["summaryOnly"].(bool)
+		noCache, _ := args["noCache"].(bool)
+		incremental, _ := args["incremental"].(bool)
+		if incremental && (!cluster || len(resourcePaths) > 0 || resources != "") {
+			return invalidArgument("incremental scans only cover cluster resources: set cluster to true and leave resourcePaths and resources out"), nil
+		}
+		sample, _ := args["sample"].(float64)
+		sampleSeed, _ := args["sampleSeed"].(float64)
+		if sample < 0 {
+			return invalidArgument("invalid sample %v: must be positive", sample), nil
+		}
+		if sample > 0 && (!cluster || len(resourcePaths) > 0 || resources != "" || incremental) {
+			return invalidArgument("sampled scans only cover cluster resources: set cluster to true, leave resourcePaths and resources out and do not set incremental"), nil
+		}
+
+		var policies map[string]struct{}
+		if v, ok := args["policies"].(string); ok {
+			if set := common.ParseCommaSeparated(v); len(set) > 0 {
+				policies = set
+			}
+		}
+
+		results, err := applyPolicy(ctx, applyOptions{
+			policySets:          policySets,
+			namespaces:          namespaces,
+			gitBranch:           gitBranch,
+			skipControllerOwned: skipControllerOwned,
+			filter:              filter,
+			groupBy:             groupBy,
+			page:                page,
+			includePassing:      includePassing,
+			values:              values,
+			valuesFile:          valuesFile,
+			userInfo:            userInfo,
+			auditAsWarn:         auditAsWarn,
+			exceptionPaths:      exceptionPaths,
+			clusterExceptions:   clusterExceptions,
+			cluster:             cluster,
+			resourcePaths:       resourcePaths,
+			resources:           resources,
+			concurrency:         int(concurrency),
+			progress:            newProgressReporter(ctx, request),
+			summaryOnly:         summaryOnly,
+			policies:            policies,
+			includeMutations:    includeMutations,
+			includeGenerated:    includeGenerated,
+			registryAccess:      registryAccess,
+			policyPaths:         policyPaths,
+			contextPath:         contextPath,
+			contextResources:    contextResources,
+			includeTimings:      includeTimings,
+			selector:            selector,
+			noCache:             noCache,
+			incremental:         incremental,
+			sample:              int(sample),
+			sampleSeed:          int(sampleSeed),
+		})
 		if err != nil {
 			// Surface the error back to the MCP client without terminating the server.
-			return mcp.NewToolResultError(err.Error()), nil
+			return errorResult(err), nil
 		}
 		return mcp.NewToolResultText(results), nil
 	})
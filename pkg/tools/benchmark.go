@@ -0,0 +1,371 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/policy"
+	"github.com/kyverno/kyverno/pkg/autogen"
+	kubeutils "github.com/kyverno/kyverno/pkg/utils/kube"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultBenchmarkIterations = 10
+	maxBenchmarkIterations     = 100
+	// defaultBenchmarkSample is the number of resources of every kind and namespace a sampled
+	// benchmark evaluates when the call sets no sample.
+	defaultBenchmarkSample = 3
+)
+
+// syntheticAPIVersions holds the apiVersion of the synthetic resources generated for kinds
+// matched by policies without a group and version.
+var syntheticAPIVersions = map[string]string{
+	"Pod":                   "v1",
+	"Service":               "v1",
+	"ConfigMap":             "v1",
+	"Secret":                "v1",
+	"ServiceAccount":        "v1",
+	"Namespace":             "v1",
+	"PersistentVolumeClaim": "v1",
+	"Deployment":            "apps/v1",
+	"StatefulSet":           "apps/v1",
+	"DaemonSet":             "apps/v1",
+	"ReplicaSet":            "apps/v1",
+	"Job":                   "batch/v1",
+	"CronJob":               "batch/v1",
+	"Ingress":               "networking.k8s.io/v1",
+	"NetworkPolicy":         "networking.k8s.io/v1",
+	"Role":                  "rbac.authorization.k8s.io/v1",
+	"ClusterRole":           "rbac.authorization.k8s.io/v1",
+	"RoleBinding":           "rbac.authorization.k8s.io/v1",
+	"ClusterRoleBinding":    "rbac.authorization.k8s.io/v1",
+}
+
+// clusterScopedKinds lists the kinds of syntheticAPIVersions that are not namespaced.
+var clusterScopedKinds = map[string]struct{}{
+	"Namespace":          {},
+	"ClusterRole":        {},
+	"ClusterRoleBinding": {},
+}
+
+// latencyStats summarizes evaluation times in milliseconds.
+type latencyStats struct {
+	P50Ms float64 `json:"p50Ms"`
+	P95Ms float64 `json:"p95Ms"`
+	MaxMs float64 `json:"maxMs"`
+}
+
+// ruleLatency is the evaluation latency of a single rule across every resource and iteration.
+type ruleLatency struct {
+	Policy      string `json:"policy"`
+	Rule        string `json:"rule"`
+	Evaluations int    `json:"evaluations"`
+	latencyStats
+}
+
+// benchmarkReport is the result of the benchmark_policies tool.
+type benchmarkReport struct {
+	Source     string `json:"source"`
+	Iterations int    `json:"iterations"`
+	Resources  int    `json:"resources"`
+	// PerResource is the time all rules took on a single resource, which approximates the
+	// overhead the policies add to an admission request for it.
+	PerResource latencyStats `json:"perResource"`
+	// Rules is ordered from the slowest to the fastest rule at p95.
+	Rules    []ruleLatency `json:"rules"`
+	Warnings []string      `json:"warnings,omitempty"`
+}
+
+// newLatencyStats returns the nearest-rank percentiles of durations, which it sorts.
+func newLatencyStats(durations []time.Duration) latencyStats {
+	if len(durations) == 0 {
+		return latencyStats{}
+	}
+	slices.Sort(durations)
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(durations)))) - 1
+		return durationMs(durations[max(i, 0)])
+	}
+	return latencyStats{P50Ms: percentile(50), P95Ms: percentile(95), MaxMs: durationMs(durations[len(durations)-1])}
+}
+
+// benchmarkPolicies evaluates the policies in policyPaths against resources the given number of
+// times, offline, and reports the latency of each rule and the time all rules took per resource.
+func benchmarkPolicies(ctx context.Context, opts applyOptions, policyPaths []string, resources []*unstructured.Unstructured, iterations int, progress *progressReporter) (*benchmarkReport, error) {
+	byRule := map[[2]string][]time.Duration{}
+	var perResource []time.Duration
+	for i := range iterations {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := scanResources(ctx, opts, policyPaths, "", "", resources)
+		if err != nil {
+			return nil, err
+		}
+		byResource := map[string]time.Duration{}
+		for _, er := range result.EngineResponses {
+			if er.Policy() == nil {
+				continue
+			}
+			name := kyverno.PolicyKey(er.Policy())
+			for _, rule := range er.PolicyResponse.Rules {
+				d := rule.Stats().ProcessingTime()
+				key := [2]string{name, rule.Name()}
+				byRule[key] = append(byRule[key], d)
+				byResource[resourceKey(er.Resource)] += d
+			}
+		}
+		for _, d := range byResource {
+			perResource = append(perResource, d)
+		}
+		progress.step(ctx, iterations, fmt.Sprintf("iteration %d of %d complete", i+1, iterations))
+	}
+
+	report := &benchmarkReport{Iterations: iterations, Resources: len(resources), PerResource: newLatencyStats(perResource), Rules: []ruleLatency{}}
+	for key, durations := range byRule {
+		report.Rules = append(report.Rules, ruleLatency{Policy: key[0], Rule: key[1], Evaluations: len(durations), latencyStats: newLatencyStats(durations)})
+	}
+	sort.Slice(report.Rules, func(i, j int) bool {
+		a, b := report.Rules[i], report.Rules[j]
+		if a.P95Ms != b.P95Ms {
+			return a.P95Ms > b.P95Ms
+		}
+		return a.Policy+"/"+a.Rule < b.Policy+"/"+b.Rule
+	})
+	return report, nil
+}
+
+// syntheticResources generates a minimal resource of every kind matched by the rules of the
+// Kyverno policies in policyPaths, with warnings about the kinds it cannot generate.
+func syntheticResources(policyPaths []string) ([]*unstructured.Unstructured, []string, error) {
+	loaded, err := policy.Load(nil, "", policyPaths...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	var selectors []string
+	for _, p := range loaded.Policies {
+		for _, rule := range autogen.Default.ComputeRules(p, "") {
+			selectors = append(selectors, ruleKinds(rule)...)
+		}
+	}
+	slices.Sort(selectors)
+	selectors = slices.Compact(selectors)
+
+	var resources []*unstructured.Unstructured
+	var warnings []string
+	if len(loaded.VAPs) > 0 || len(loaded.ValidatingPolicies) > 0 || len(loaded.ImageValidatingPolicies) > 0 {
+		warnings = append(warnings, "synthetic resources are only generated for the kinds matched by Kyverno ClusterPolicies and Policies; pass resources to benchmark other policy types")
+	}
+	generated := map[string]struct{}{}
+	for _, selector := range selectors {
+		group, version, kind, subresource := kubeutils.ParseKindSelector(selector)
+		if subresource != "" {
+			continue
+		}
+		apiVersion, ok := syntheticAPIVersions[kind]
+		if !ok && group != "*" && version != "*" {
+			apiVersion, ok = strings.TrimPrefix(group+"/"+version, "/"), true
+		}
+		if !ok || kind == "*" {
+			warnings = append(warnings, fmt.Sprintf("no synthetic resource is generated for kind %s; pass resources or use source=sampled to cover it", selector))
+			continue
+		}
+		if _, ok := generated[kind]; ok {
+			continue
+		}
+		generated[kind] = struct{}{}
+		resources = append(resources, syntheticResource(apiVersion, kind))
+	}
+	if len(resources) == 0 {
+		return nil, warnings, fmt.Errorf("no synthetic resources could be generated for the kinds matched by the policies; pass resources or use source=sampled")
+	}
+	return resources, warnings, nil
+}
+
+// syntheticResource returns a minimal resource of the given kind. Workloads run a single
+// container without a security context, so that pod security rules evaluate every check.
+func syntheticResource(apiVersion, kind string) *unstructured.Unstructured {
+	name := "benchmark-" + strings.ToLower(kind)
+	labels := map[string]any{"app": name}
+	metadata := map[string]any{"name": name, "labels": labels}
+	if _, ok := clusterScopedKinds[kind]; !ok {
+		metadata["namespace"] = common.DefaultNamespace
+	}
+	podSpec := func(restartPolicy string) map[string]any {
+		return map[string]any{
+			"restartPolicy": restartPolicy,
+			"containers": []any{map[string]any{
+				"name":  "app",
+				"image": "nginx:1.27",
+				"ports": []any{map[string]any{"containerPort": int64(80)}},
+			}},
+		}
+	}
+	template := map[string]any{"metadata": map[string]any{"labels": labels}, "spec": podSpec("Always")}
+
+	obj := map[string]any{"apiVersion": apiVersion, "kind": kind, "metadata": metadata}
+	switch kind {
+	case "Pod":
+		obj["spec"] = podSpec("Always")
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+		obj["spec"] = map[string]any{"selector": map[string]any{"matchLabels": labels}, "template": template}
+	case "Job":
+		obj["spec"] = map[string]any{"template": map[string]any{"spec": podSpec("Never")}}
+	case "CronJob":
+		obj["spec"] = map[string]any{
+			"schedule":    "0 * * * *",
+			"jobTemplate": map[string]any{"spec": map[string]any{"template": map[string]any{"spec": podSpec("Never")}}},
+		}
+	case "Service":
+		obj["spec"] = map[string]any{"selector": labels, "ports": []any{map[string]any{"port": int64(80)}}}
+	case "Role", "ClusterRole":
+		obj["rules"] = []any{map[string]any{"apiGroups": []any{""}, "resources": []any{"pods"}, "verbs": []any{"get", "list"}}}
+	case "RoleBinding", "ClusterRoleBinding":
+		roleKind := strings.TrimSuffix(kind, "Binding")
+		obj["roleRef"] = map[string]any{"apiGroup": "rbac.authorization.k8s.io", "kind": roleKind, "name": "benchmark-" + strings.ToLower(roleKind)}
+		obj["subjects"] = []any{map[string]any{"kind": "ServiceAccount", "name": "default", "namespace": common.DefaultNamespace}}
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+// parseResources converts normalized inline manifests to resources.
+func parseResources(manifests []byte) ([]*unstructured.Unstructured, error) {
+	docs, err := splitDocuments(manifests)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]*unstructured.Unstructured, 0, len(docs))
+	for i, doc := range docs {
+		var obj map[string]any
+		if err := yaml.Unmarshal(doc, &obj); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		resources = append(resources, &unstructured.Unstructured{Object: obj})
+	}
+	return resources, nil
+}
+
+// BenchmarkPolicies registers the benchmark_policies tool with the MCP server.
+func BenchmarkPolicies(s *server.MCPServer) {
+	klog.InfoS("Registering tool: benchmark_policies")
+	s.AddTool(mcp.NewTool("benchmark_policies",
+		mcp.WithDescription(`Measure how long policies take to evaluate, to estimate the overhead they would add to the admission webhook before enforcing them. The policies are evaluated the given number of times against synthetic resources of the kinds they match, resources sampled from the cluster, or the supplied resources. Returns {source, iterations, resources, perResource: {p50Ms, p95Ms, maxMs}, rules: [{policy, rule, evaluations, p50Ms, p95Ms, maxMs}]}, where perResource is the time all rules took on one resource and rules are ordered slowest first at p95. Resources are evaluated without cluster lookups, so the time of API calls and context lookups made at admission is not included.`),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Benchmark Kyverno policies",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithString("policySets", mcp.Description(`Policy set key: pod-security, rbac-best-practices, kubernetes-best-practices, all, or installed for the policies installed in the cluster, or a comma-separated list of them (default: all)`)),
+		mcp.WithString("policies", mcp.Description(`Comma-separated names of policies to benchmark from the selected policy set (default: all policies in the set)`)),
+		mcp.WithArray("policyPaths", mcp.Description(`Paths on the server to policy manifests, directories, glob patterns, HTTPS URLs or OCI images to benchmark instead of the embedded policy sets`), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("source", mcp.Description(`Resources to evaluate the policies against: synthetic to generate a minimal resource of every kind matched by the policies, or sampled to pick resources of those kinds from the cluster. Ignored when resources is set (default: synthetic)`), mcp.Enum("synthetic", "sampled"), mcp.DefaultString("synthetic")),
+		mcp.WithString("resources", mcp.Description(`Inline YAML or JSON resource manifests to evaluate the policies against instead of synthetic or sampled resources`)),
+		mcp.WithString("namespace", mcp.Description(`Namespace to sample resources from when source is sampled, a comma-separated list of namespaces, or "all" (default: default)`)),
+		mcp.WithNumber("sample", mcp.Description(`Maximum number of resources of every kind and namespace to sample when source is sampled (default: 3)`)),
+		mcp.WithNumber("iterations", mcp.Description(`Number of times to evaluate the policies against every resource, at most 100 (default: 10)`)),
+		asArgument,
+		asGroupsArgument,
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if result := serverPathsDenied(ctx, request, "policyPaths"); result != nil {
+			return result, nil
+		}
+		iterations := request.GetInt("iterations", defaultBenchmarkIterations)
+		if iterations < 1 || iterations > maxBenchmarkIterations {
+			return invalidArgument("invalid iterations %d: must be between 1 and %d", iterations, maxBenchmarkIterations), nil
+		}
+		if _, err := parsePolicySets(request.GetString("policySets", "all")); err != nil {
+			return invalidArgument("%v", err), nil
+		}
+		source := request.GetString("source", "synthetic")
+		if source != "synthetic" && source != "sampled" {
+			return invalidArgument("invalid source %q: must be synthetic or sampled", source), nil
+		}
+
+		opts := applyOptions{
+			policySets:  request.GetString("policySets", "all"),
+			policyPaths: request.GetStringSlice("policyPaths", nil),
+			sample:      request.GetInt("sample", defaultBenchmarkSample),
+		}
+		if set := common.ParseCommaSeparated(request.GetString("policies", "")); len(set) > 0 {
+			opts.policies = set
+		}
+		if opts.sample < 1 {
+			return invalidArgument("invalid sample %d: must be positive", opts.sample), nil
+		}
+
+		var inline []*unstructured.Unstructured
+		if resources := request.GetString("resources", ""); strings.TrimSpace(resources) != "" {
+			normalized, err := normalizeInlineResources(resources)
+			if err != nil {
+				return invalidArgument("invalid resources: %v", err), nil
+			}
+			if inline, err = parseResources(normalized); err != nil {
+				return invalidArgument("invalid resources: %v", err), nil
+			}
+			source = "resources"
+		} else if source == "sampled" {
+			namespaces, err := common.ResolveNamespaces(request.GetString("namespace", ""), common.DefaultNamespaceExcludes)
+			if err != nil {
+				return invalidArgument("%v", err), nil
+			}
+			if err := namespaces.Validate(ctx); err != nil {
+				return notFound("list the namespaces of the cluster and pick existing ones", "%v", err), nil
+			}
+			opts.namespaces = namespaces
+		}
+
+		policyPaths, warnings, _, release, err := preparePolicies(ctx, opts)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer release()
+
+		var resources []*unstructured.Unstructured
+		var resourceWarnings []string
+		switch source {
+		case "resources":
+			resources = inline
+		case "sampled":
+			resources, resourceWarnings, _, err = sampleResources(ctx, opts, policyPaths)
+			if err == nil && len(resources) == 0 {
+				return notFound("sample other namespaces, or use source=synthetic", "no resources of the kinds matched by the policies were found"), nil
+			}
+		default:
+			resources, resourceWarnings, err = syntheticResources(policyPaths)
+		}
+		if err != nil {
+			return errorResult(err), nil
+		}
+		warnings = append(warnings, resourceWarnings...)
+
+		report, err := benchmarkPolicies(ctx, opts, policyPaths, resources, iterations, newProgressReporter(ctx, request))
+		if err != nil {
+			return errorResult(err), nil
+		}
+		report.Source = source
+		report.Warnings = warnings
+
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
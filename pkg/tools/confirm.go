@@ -0,0 +1,61 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// confirmationTTL bounds how long a confirmation token issued for a write operation stays valid.
+const confirmationTTL = 5 * time.Minute
+
+// confirmations tracks the tokens handed out for pending write operations. A write tool first
+// describes the change and issues a token; the change is only made when the tool is called again
+// with that token, giving the client a round-trip in which to ask the user for confirmation.
+type confirmations struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation
+}
+
+// pendingConfirmation is the operation a token was issued for.
+type pendingConfirmation struct {
+	action  string
+	expires time.Time
+}
+
+// writeConfirmations holds the pending confirmations of all write tools.
+var writeConfirmations = &confirmations{pending: map[string]pendingConfirmation{}}
+
+// issue returns a new single-use token confirming action, e.g. "switch_context prod".
+func (c *confirmations) issue(action string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for t, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, t)
+		}
+	}
+	c.pending[token] = pendingConfirmation{action: action, expires: now.Add(confirmationTTL)}
+	return token, nil
+}
+
+// redeem consumes token and reports whether it was issued for action and has not expired.
+func (c *confirmations) redeem(token, action string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[token]
+	if !ok {
+		return false
+	}
+	delete(c.pending, token)
+	return p.action == action && time.Now().Before(p.expires)
+}
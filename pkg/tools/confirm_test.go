@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestConfirmations(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		unknown    bool
+		expire     bool
+		redeemed   bool
+		wantRedeem bool
+	}{
+		{name: "issued token", action: "switch_context prod", wantRedeem: true},
+		{name: "other action", action: "switch_context staging"},
+		{name: "unknown token", action: "switch_context prod", unknown: true},
+		{name: "expired token", action: "switch_context prod", expire: true},
+		{name: "token already used", action: "switch_context prod", redeemed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &confirmations{pending: map[string]pendingConfirmation{}}
+			token, err := c.issue("switch_context prod")
+			if err != nil {
+				t.Fatalf("issue() error = %v", err)
+			}
+			if tt.expire {
+				p := c.pending[token]
+				p.expires = time.Now().Add(-time.Second)
+				c.pending[token] = p
+			}
+			if tt.redeemed && !c.redeem(token, tt.action) {
+				t.Fatal("first redeem() = false, want true")
+			}
+			if tt.unknown {
+				token = "0123456789abcdef"
+			}
+			if got := c.redeem(token, tt.action); got != tt.wantRedeem {
+				t.Errorf("redeem() = %v, want %v", got, tt.wantRedeem)
+			}
+			// Tokens are single use, whether or not they matched
+			if _, ok := c.pending[token]; ok {
+				t.Error("redeem() kept the token")
+			}
+		})
+	}
+}
+
+func TestConfirmationsIssueDropsExpired(t *testing.T) {
+	c := &confirmations{pending: map[string]pendingConfirmation{
+		"expired": {action: "switch_context prod", expires: time.Now().Add(-time.Second)},
+		"pending": {action: "switch_context staging", expires: time.Now().Add(time.Minute)},
+	}}
+	token, err := c.issue("switch_context prod")
+	if err != nil {
+		t.Fatalf("issue() error = %v", err)
+	}
+	if _, ok := c.pending["expired"]; ok {
+		t.Error("issue() kept an expired token")
+	}
+	if len(c.pending) != 2 || c.pending[token].action != "switch_context prod" {
+		t.Errorf("pending = %v, want the new token and the pending one", c.pending)
+	}
+}
+
+func TestSwitchContextConfirmation(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: "https://dev.example"}
+- name: prod
+  cluster: {server: "https://prod.example"}
+users:
+- name: admin
+  user: {token: secret}
+contexts:
+- name: dev
+  context: {cluster: dev, user: admin}
+- name: prod
+  context: {cluster: prod, user: admin}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", kubeconfig)
+	previous := AllowWrites
+	t.Cleanup(func() { AllowWrites = previous })
+	AllowWrites = true
+
+	s := server.NewMCPServer("test", "1.0.0")
+	SwitchContext(s)
+	issue := func(contextName string) string {
+		t.Helper()
+		result := callTool(t, s, "switch_context", map[string]any{"context": contextName})
+		if result.IsError {
+			t.Fatalf("switch_context without a token failed: %v", result.Content)
+		}
+		m := regexp.MustCompile(`confirmationToken "([0-9a-f]+)"`).FindStringSubmatch(result.Content[0].(mcp.TextContent).Text)
+		if m == nil {
+			t.Fatalf("switch_context without a token returned no token: %v", result.Content)
+		}
+		return m[1]
+	}
+
+	tests := []struct {
+		name        string
+		token       func() string
+		wantErrCode errorCode
+		wantCurrent string
+	}{
+		{name: "token of another context", token: func() string { return issue("dev") }, wantErrCode: codeInvalidArgument, wantCurrent: "dev"},
+		{name: "made up token", token: func() string { return "0123456789abcdef" }, wantErrCode: codeInvalidArgument, wantCurrent: "dev"},
+		{name: "confirmed switch", token: func() string { return issue("prod") }, wantCurrent: "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only a token issued for the same context switches it
+			token := tt.token()
+			result := callTool(t, s, "switch_context", map[string]any{"context": "prod", "confirmationToken": token})
+			if code := resultErrorCode(t, result); code != tt.wantErrCode {
+				t.Fatalf("result error code = %q, want %q", code, tt.wantErrCode)
+			}
+			cfg, err := clientcmd.LoadFromFile(kubeconfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.CurrentContext != tt.wantCurrent {
+				t.Errorf("current-context = %q, want %q", cfg.CurrentContext, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+// callTool calls a tool of s the way a client does.
+func callTool(t *testing.T, s *server.MCPServer, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	message, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": map[string]any{"name": name, "arguments": args}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := s.HandleMessage(context.Background(), message)
+	response, ok := reply.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("tools/call %s failed: %v", name, reply)
+	}
+	result, ok := response.Result.(mcp.CallToolResult)
+	if !ok {
+		t.Fatalf("tools/call %s result = %T, want a tool result", name, response.Result)
+	}
+	return &result
+}
@@ -0,0 +1,98 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// annotationPolicyDocsURL optionally links a policy to its documentation.
+const annotationPolicyDocsURL = "policies.kyverno.io/docs-url"
+
+// clusterPolicyGVR and policyGVR are the Kyverno policy resources, whose annotations may carry a
+// docs URL.
+var (
+	clusterPolicyGVR = schema.GroupVersionResource{Group: "kyverno.io", Version: "v1", Resource: "clusterpolicies"}
+	policyGVR        = schema.GroupVersionResource{Group: "kyverno.io", Version: "v1", Resource: "policies"}
+)
+
+var (
+	embeddedDocsURLsOnce sync.Once
+	embeddedDocsURLs     map[string]string
+)
+
+// embeddedPolicyDocsURLs indexes the docs URL annotations of the policies embedded in the server
+// by policy name. Embedded policies without the annotation are left out.
+func embeddedPolicyDocsURLs() map[string]string {
+	embeddedDocsURLsOnce.Do(func() {
+		embeddedDocsURLs = map[string]string{}
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(defaultPolicies())))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				klog.ErrorS(err, "failed to read embedded policies")
+				return
+			}
+			var meta metav1.PartialObjectMetadata
+			if err := yaml.Unmarshal(doc, &meta); err != nil || meta.Name == "" {
+				continue
+			}
+			if url := meta.Annotations[annotationPolicyDocsURL]; url != "" {
+				embeddedDocsURLs[meta.Name] = url
+			}
+		}
+	})
+	return embeddedDocsURLs
+}
+
+// policyDocsURLs resolves documentation links for the named policies, which are named the way
+// policy reports name them: ClusterPolicies by name and Policies by namespace/name. Only the
+// named policies are fetched. The docs URL annotation of an installed policy takes precedence
+// over the one of the embedded policy of the same name; policies without any docs URL
+// annotation are left out rather than pointed at a guessed page. Policies of namespaces the
+// server is not allowed to access are not fetched.
+func policyDocsURLs(ctx context.Context, dyn dynamic.Interface, policies map[string]struct{}) map[string]string {
+	urls := map[string]string{}
+	embedded := embeddedPolicyDocsURLs()
+	for name := range policies {
+		var ri dynamic.ResourceInterface = dyn.Resource(clusterPolicyGVR)
+		policyName := name
+		if namespace, nsName, namespaced := strings.Cut(name, "/"); namespaced {
+			if !common.NamespaceAllowed(namespace) {
+				continue
+			}
+			ri, policyName = dyn.Resource(policyGVR).Namespace(namespace), nsName
+		}
+		item, err := ri.Get(ctx, policyName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.V(2).InfoS("cannot get Kyverno policy", "policy", name, "error", err)
+		}
+		if err == nil {
+			if url := item.GetAnnotations()[annotationPolicyDocsURL]; url != "" {
+				urls[name] = url
+				continue
+			}
+		}
+		if url, ok := embedded[name]; ok {
+			urls[name] = url
+		}
+	}
+	return urls
+}
@@ -0,0 +1,85 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	"github.com/nirmata/kyverno-mcp/pkg/tracing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// policyExceptionGVRs lists the PolicyException API versions to query, most preferred first.
+var policyExceptionGVRs = []schema.GroupVersionResource{
+	{Group: "kyverno.io", Version: "v2", Resource: "policyexceptions"},
+	{Group: "kyverno.io", Version: "v2beta1", Resource: "policyexceptions"},
+}
+
+// writeClusterExceptions lists the PolicyExceptions installed in the cluster and writes them to
+// a temporary multi-document YAML file suitable for ApplyCommandConfig.Exception. It returns an
+// empty path when the cluster has no PolicyException CRD or no exceptions. The caller is
+// responsible for removing the file.
+func writeClusterExceptions(ctx context.Context) (string, error) {
+	ctx, span := tracing.Start(ctx, "load cluster exceptions")
+	defer span.End()
+
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return "", fmt.Errorf("build kube-config: %w", err)
+	}
+	dyn := clients.Dynamic
+
+	for _, gvr := range policyExceptionGVRs {
+		items, err := listPaged(ctx, dyn.Resource(gvr), "")
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("list PolicyExceptions: %w", err)
+		}
+		if len(items) == 0 {
+			return "", nil
+		}
+
+		var data []byte
+		for _, item := range items {
+			// Drop server-populated metadata that the offline exception loader does not need.
+			item.SetManagedFields(nil)
+			item.SetResourceVersion("")
+			item.SetUID("")
+			raw, err := json.Marshal(item.Object)
+			if err != nil {
+				return "", fmt.Errorf("marshal PolicyException %s/%s: %w", item.GetNamespace(), item.GetName(), err)
+			}
+			data = append(data, raw...)
+			data = append(data, []byte("\n---\n")...)
+		}
+
+		path, err := writeTempFile("kyverno-exceptions-*.yaml", data)
+		if err != nil {
+			return "", fmt.Errorf("failed to write PolicyExceptions to temp file: %w", err)
+		}
+		klog.V(2).InfoS("loaded PolicyExceptions from cluster", "count", len(items), "version", gvr.Version)
+		return path, nil
+	}
+	return "", nil
+}
+
+// validateExceptionPaths ensures every user-supplied exception path exists on the server.
+func validateExceptionPaths(paths []string) error {
+	for _, p := range paths {
+		if isGlobPattern(p) {
+			continue
+		}
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("invalid exceptionPaths entry: %w", err)
+		}
+	}
+	return nil
+}
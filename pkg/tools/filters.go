@@ -0,0 +1,177 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// severityRank orders policy report severities from least to most important.
+var severityRank = map[policyreportv1alpha2.PolicySeverity]int{
+	policyreportv1alpha2.SeverityInfo:     1,
+	policyreportv1alpha2.SeverityLow:      2,
+	policyreportv1alpha2.SeverityMedium:   3,
+	policyreportv1alpha2.SeverityHigh:     4,
+	policyreportv1alpha2.SeverityCritical: 5,
+}
+
+// resultFilter narrows policy report results down by severity and category.
+// The zero value matches every result.
+type resultFilter struct {
+	minSeverity int
+	severities  map[policyreportv1alpha2.PolicySeverity]struct{}
+	categories  map[string]struct{}
+}
+
+// newResultFilter builds a resultFilter from the minSeverity and comma-separated categories
+// tool arguments. An empty minSeverity or categories disables the respective filter.
+func newResultFilter(minSeverity, categories string) (resultFilter, error) {
+	var f resultFilter
+	if minSeverity = strings.ToLower(strings.TrimSpace(minSeverity)); minSeverity != "" {
+		rank, ok := severityRank[policyreportv1alpha2.PolicySeverity(minSeverity)]
+		if !ok {
+			return f, fmt.Errorf("invalid minSeverity %q: must be one of info, low, medium, high, critical", minSeverity)
+		}
+		f.minSeverity = rank
+	}
+	if set := common.ParseCommaSeparated(strings.ToLower(categories)); len(set) > 0 {
+		f.categories = set
+	}
+	return f, nil
+}
+
+// withSeverities restricts the filter to the exact severities in the comma-separated list.
+// An empty list leaves the filter unchanged.
+func (f resultFilter) withSeverities(severities string) (resultFilter, error) {
+	set := common.ParseCommaSeparated(strings.ToLower(severities))
+	if len(set) == 0 {
+		return f, nil
+	}
+	f.severities = map[policyreportv1alpha2.PolicySeverity]struct{}{}
+	for s := range set {
+		severity := policyreportv1alpha2.PolicySeverity(s)
+		if _, ok := severityRank[severity]; !ok {
+			return f, fmt.Errorf("invalid severity %q: must be one of info, low, medium, high, critical", s)
+		}
+		f.severities[severity] = struct{}{}
+	}
+	return f, nil
+}
+
+// String describes the filter in a canonical form, e.g. "minSeverity=high; categories=pod security".
+// The zero value is described by the empty string.
+func (f resultFilter) String() string {
+	var parts []string
+	for severity, rank := range severityRank {
+		if rank == f.minSeverity {
+			parts = append(parts, "minSeverity="+string(severity))
+		}
+	}
+	if f.severities != nil {
+		severities := make([]string, 0, len(f.severities))
+		for s := range f.severities {
+			severities = append(severities, string(s))
+		}
+		slices.Sort(severities)
+		parts = append(parts, "severities="+strings.Join(severities, ","))
+	}
+	if f.categories != nil {
+		parts = append(parts, "categories="+strings.Join(slices.Sorted(maps.Keys(f.categories)), ","))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// matches reports whether a result with the given severity and category passes the filter.
+// Results without a severity never satisfy a minSeverity filter.
+func (f resultFilter) matches(severity policyreportv1alpha2.PolicySeverity, category string) bool {
+	severity = policyreportv1alpha2.PolicySeverity(strings.ToLower(string(severity)))
+	if f.minSeverity > 0 && severityRank[severity] < f.minSeverity {
+		return false
+	}
+	if f.severities != nil {
+		if _, ok := f.severities[severity]; !ok {
+			return false
+		}
+	}
+	if f.categories != nil {
+		if _, ok := f.categories[strings.ToLower(strings.TrimSpace(category))]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceFieldSelectors lists the fields that the fieldSelector argument can match on. They are
+// the fields every Kubernetes resource supports as field selectors.
+var resourceFieldSelectors = map[string]struct{}{"metadata.name": {}, "metadata.namespace": {}}
+
+// resourceSelector narrows scanned resources down by label and field selectors.
+// The zero value matches every resource.
+type resourceSelector struct {
+	labels labels.Selector
+	fields fields.Selector
+}
+
+// newResourceSelector parses the labelSelector and fieldSelector tool arguments, failing with
+// a descriptive error on invalid syntax or unsupported fields. Empty arguments match everything.
+func newResourceSelector(labelSelector, fieldSelector string) (resourceSelector, error) {
+	var s resourceSelector
+	if labelSelector = strings.TrimSpace(labelSelector); labelSelector != "" {
+		sel, err := labels.Parse(labelSelector)
+		if err != nil {
+			return s, fmt.Errorf("invalid labelSelector %q: %w", labelSelector, err)
+		}
+		s.labels = sel
+	}
+	if fieldSelector = strings.TrimSpace(fieldSelector); fieldSelector != "" {
+		sel, err := fields.ParseSelector(fieldSelector)
+		if err != nil {
+			return s, fmt.Errorf("invalid fieldSelector %q: %w", fieldSelector, err)
+		}
+		for _, req := range sel.Requirements() {
+			if _, ok := resourceFieldSelectors[req.Field]; !ok {
+				return s, fmt.Errorf("invalid fieldSelector %q: field %q is not supported, use metadata.name or metadata.namespace", fieldSelector, req.Field)
+			}
+		}
+		s.fields = sel
+	}
+	return s, nil
+}
+
+// empty reports whether the selectors match every resource.
+func (s resourceSelector) empty() bool {
+	return s.labels == nil && s.fields == nil
+}
+
+// listOptions returns the list options selecting the resources that pass the selectors on the
+// API server.
+func (s resourceSelector) listOptions() metav1.ListOptions {
+	var opts metav1.ListOptions
+	if s.labels != nil {
+		opts.LabelSelector = s.labels.String()
+	}
+	if s.fields != nil {
+		opts.FieldSelector = s.fields.String()
+	}
+	return opts
+}
+
+// matches reports whether a resource passes the selectors.
+func (s resourceSelector) matches(resource unstructured.Unstructured) bool {
+	if s.labels != nil && !s.labels.Matches(labels.Set(resource.GetLabels())) {
+		return false
+	}
+	if s.fields != nil && !s.fields.Matches(fields.Set{"metadata.name": resource.GetName(), "metadata.namespace": resource.GetNamespace()}) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestResourceSelectorListOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		labelSelector string
+		fieldSelector string
+		wantLabels    string
+		wantFields    string
+	}{
+		{name: "no selector"},
+		{name: "label selector", labelSelector: "app=web,tier!=cache", wantLabels: "app=web,tier!=cache"},
+		{name: "set-based label selector", labelSelector: "app in (web,api)", wantLabels: "app in (api,web)"},
+		{name: "field selector", fieldSelector: "metadata.name!=legacy", wantFields: "metadata.name!=legacy"},
+		{name: "both", labelSelector: "app=web", fieldSelector: "metadata.namespace=team-a", wantLabels: "app=web", wantFields: "metadata.namespace=team-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := newResourceSelector(tt.labelSelector, tt.fieldSelector)
+			if err != nil {
+				t.Fatalf("newResourceSelector() error = %v", err)
+			}
+			opts := selector.listOptions()
+			if opts.LabelSelector != tt.wantLabels {
+				t.Errorf("LabelSelector = %q, want %q", opts.LabelSelector, tt.wantLabels)
+			}
+			if opts.FieldSelector != tt.wantFields {
+				t.Errorf("FieldSelector = %q, want %q", opts.FieldSelector, tt.wantFields)
+			}
+			if empty := tt.labelSelector == "" && tt.fieldSelector == ""; selector.empty() != empty {
+				t.Errorf("empty() = %v, want %v", selector.empty(), empty)
+			}
+		})
+	}
+}
+
+func TestListPagedWithSendsSelectors(t *testing.T) {
+	tests := []struct {
+		name          string
+		labelSelector string
+		fieldSelector string
+	}{
+		{name: "no selector"},
+		{name: "label selector", labelSelector: "app=web"},
+		{name: "field selector", fieldSelector: "metadata.name!=legacy"},
+		{name: "both", labelSelector: "app=web", fieldSelector: "metadata.namespace=team-a"},
+	}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := newResourceSelector(tt.labelSelector, tt.fieldSelector)
+			if err != nil {
+				t.Fatalf("newResourceSelector() error = %v", err)
+			}
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ConfigMapList"})
+			var calls []clienttesting.ListRestrictions
+			client.PrependReactor("list", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				calls = append(calls, action.(clienttesting.ListAction).GetListRestrictions())
+				list := &unstructured.UnstructuredList{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMapList"}}
+				list.Items = append(list.Items, unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]any{
+						"name":      fmt.Sprintf("config-%d", len(calls)),
+						"namespace": "team-a",
+						"labels":    map[string]any{"app": "web"},
+					},
+				}})
+				// The first page points to a second one
+				if len(calls) == 1 {
+					list.SetContinue("page-2")
+				}
+				return true, list, nil
+			})
+
+			items, err := listPagedWith(context.Background(), client.Resource(gvr).Namespace("team-a"), selector.listOptions())
+			if err != nil {
+				t.Fatalf("listPagedWith() error = %v", err)
+			}
+			if len(items) != 2 {
+				t.Errorf("listPagedWith() returned %d items, want 2", len(items))
+			}
+			if len(calls) != 2 {
+				t.Fatalf("listPagedWith() made %d list calls, want 2", len(calls))
+			}
+			for i, restrictions := range calls {
+				if got := restrictions.Labels.String(); got != tt.labelSelector {
+					t.Errorf("call %d: label selector = %q, want %q", i, got, tt.labelSelector)
+				}
+				if got := restrictions.Fields.String(); got != tt.fieldSelector {
+					t.Errorf("call %d: field selector = %q, want %q", i, got, tt.fieldSelector)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// groupByValues lists the supported values of the groupBy tool argument.
+var groupByValues = []string{"policy", "resource", "namespace", "kind", "severity"}
+
+// sortByValues lists the supported values of the sortBy tool argument.
+var sortByValues = []string{"count", "severity", "timestamp"}
+
+// groupFields holds the attributes of a single result that it can be grouped by.
+type groupFields struct {
+	policy    string
+	resource  string
+	namespace string
+	kind      string
+	severity  string
+}
+
+// validateGroupBy returns an error if groupBy is neither empty nor one of groupByValues.
+func validateGroupBy(groupBy string) error {
+	if groupBy == "" {
+		return nil
+	}
+	for _, v := range groupByValues {
+		if groupBy == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid groupBy %q: must be one of %s", groupBy, strings.Join(groupByValues, ", "))
+}
+
+// validateSortBy returns an error if sortBy is neither empty nor one of sortByValues.
+func validateSortBy(sortBy string) error {
+	if sortBy == "" {
+		return nil
+	}
+	for _, v := range sortByValues {
+		if sortBy == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sortBy %q: must be one of %s", sortBy, strings.Join(sortByValues, ", "))
+}
+
+// groupKey returns the key of the group a result with the given fields belongs to.
+func groupKey(groupBy string, f groupFields) string {
+	var key string
+	switch groupBy {
+	case "policy":
+		key = f.policy
+	case "resource":
+		key = f.resource
+	case "namespace":
+		if f.namespace == "" {
+			return "cluster-scoped"
+		}
+		key = f.namespace
+	case "kind":
+		key = f.kind
+	case "severity":
+		key = f.severity
+	}
+	if key == "" {
+		return "none"
+	}
+	return key
+}
+
+// groupResults buckets items by the key returned from keyFn, preserving the relative order
+// of items within each group. The resulting map marshals to JSON with sorted keys.
+func groupResults[T any](items []T, keyFn func(T) string) map[string][]T {
+	groups := map[string][]T{}
+	for _, item := range items {
+		key := keyFn(item)
+		groups[key] = append(groups[key], item)
+	}
+	return groups
+}
+
+// resultGroup is a named group of results, used when groups must be returned in a specific order.
+type resultGroup[T any] struct {
+	Group   string `json:"group"`
+	Count   int    `json:"count"`
+	Results []T    `json:"results"`
+}
+
+// sortAccessors extracts the attributes results are sorted by.
+type sortAccessors[T any] struct {
+	// countKey returns the key whose number of occurrences ranks results when sorting by count.
+	countKey  func(T) string
+	severity  func(T) string
+	timestamp func(T) int64
+}
+
+// sortResults reorders items by sortBy, most significant first: results whose countKey occurs
+// most often, the most severe results, or the most recent results. The sort is stable, so ties
+// keep their existing order.
+func sortResults[T any](items []T, sortBy string, acc sortAccessors[T]) {
+	switch sortBy {
+	case "count":
+		counts := map[string]int{}
+		for _, item := range items {
+			counts[acc.countKey(item)]++
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return counts[acc.countKey(items[i])] > counts[acc.countKey(items[j])]
+		})
+	case "severity":
+		sort.SliceStable(items, func(i, j int) bool {
+			return severityOrder(acc.severity(items[i])) > severityOrder(acc.severity(items[j]))
+		})
+	case "timestamp":
+		sort.SliceStable(items, func(i, j int) bool {
+			return acc.timestamp(items[i]) > acc.timestamp(items[j])
+		})
+	}
+}
+
+// sortedGroups flattens groups into a slice ordered by sortBy, most significant first: the
+// largest groups, the groups containing the most severe result, or the groups containing the
+// most recent result. Ties are broken by group key.
+func sortedGroups[T any](groups map[string][]T, sortBy string, acc sortAccessors[T]) []resultGroup[T] {
+	type ranked struct {
+		group    resultGroup[T]
+		severity int
+		latest   int64
+	}
+	list := make([]ranked, 0, len(groups))
+	for key, items := range groups {
+		r := ranked{group: resultGroup[T]{Group: key, Count: len(items), Results: items}}
+		for _, item := range items {
+			if sev := severityOrder(acc.severity(item)); sev > r.severity {
+				r.severity = sev
+			}
+			if ts := acc.timestamp(item); ts > r.latest {
+				r.latest = ts
+			}
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch sortBy {
+		case "count":
+			if a.group.Count != b.group.Count {
+				return a.group.Count > b.group.Count
+			}
+		case "severity":
+			if a.severity != b.severity {
+				return a.severity > b.severity
+			}
+		case "timestamp":
+			if a.latest != b.latest {
+				return a.latest > b.latest
+			}
+		}
+		return a.group.Group < b.group.Group
+	})
+	out := make([]resultGroup[T], 0, len(list))
+	for _, r := range list {
+		out = append(out, r.group)
+	}
+	return out
+}
+
+// severityOrder ranks a severity string, returning 0 for empty or unknown severities.
+func severityOrder(severity string) int {
+	return severityRank[policyreportv1alpha2.PolicySeverity(strings.ToLower(severity))]
+}
+
+// resourceIdentifier formats an object reference as Kind/namespace/name, or Kind/name for
+// cluster-scoped resources.
+func resourceIdentifier(r corev1.ObjectReference) string {
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+}
+
+// policyReportResultGroupFields extracts the groupable attributes of a policy report result.
+func policyReportResultGroupFields(r policyreportv1alpha2.PolicyReportResult) groupFields {
+	f := groupFields{policy: r.Policy, severity: string(r.Severity)}
+	if len(r.Resources) > 0 {
+		f.resource = resourceIdentifier(r.Resources[0])
+		f.namespace = r.Resources[0].Namespace
+		f.kind = r.Resources[0].Kind
+	}
+	return f
+}
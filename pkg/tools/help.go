@@ -20,18 +20,25 @@ func Help(s *server.MCPServer) {
 	docTool := mcp.NewTool(
 		"help",
 		mcp.WithDescription(`Get Kyverno documentation for installation and troubleshooting`),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Kyverno documentation",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
 		mcp.WithString("topic", mcp.Description(`Topic of documentation to get between installation and troubleshooting Kyverno environment`), mcp.Required()),
 	)
 
 	s.AddTool(docTool, func(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args, ok := request.Params.Arguments.(map[string]any)
 		if !ok {
-			return mcp.NewToolResultError("Error: invalid arguments format"), nil
+			return invalidArgument("arguments must be a JSON object"), nil
 		}
 
 		topic, ok := args["topic"].(string)
 		if !ok {
-			return mcp.NewToolResultError("Error: invalid documentation type"), nil
+			return invalidArgument("topic is required"), nil
 		}
 
 		switch topic {
@@ -40,7 +47,7 @@ func Help(s *server.MCPServer) {
 		case "troubleshooting":
 			return mcp.NewToolResultText(troubleshootingHelp), nil
 		default:
-			return mcp.NewToolResultError("Error: invalid documentation type"), nil
+			return invalidArgument("invalid topic %q", topic), nil
 		}
 	})
 }
@@ -0,0 +1,46 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// asArgument and asGroupsArgument declare the impersonation arguments of the tools making
+// cluster requests, which ImpersonationMiddleware applies.
+var (
+	asArgument       = mcp.WithString("as", mcp.Description(`User to impersonate for the cluster requests of this call, like kubectl --as, so that only what the user may read is scanned, e.g. "alice" or "system:serviceaccount:team-a:ci". Not allowed when the server impersonates a user itself or the call is authenticated by an API token (default: the server's identity)`))
+	asGroupsArgument = mcp.WithArray("asGroups", mcp.Description(`Groups to impersonate together with as, like kubectl --as-group (default: none)`), mcp.Items(map[string]any{"type": "string"}))
+)
+
+// ImpersonationMiddleware makes the cluster requests of a tool call as the user given in its as
+// and asGroups arguments. Calls cannot override an impersonation configured with the --as and
+// --as-group flags, nor impersonate anyone when authenticated by an API token confined to its own
+// credentials, which would let clients escape the identity the server restricted them to.
+func ImpersonationMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		impersonation := common.Impersonation{
+			User:   strings.TrimSpace(request.GetString("as", "")),
+			Groups: request.GetStringSlice("asGroups", nil),
+		}
+		if impersonation.IsZero() {
+			return next(ctx, request)
+		}
+		if creds, ok := common.CredentialsOf(ctx); ok {
+			return toolError{Code: codePermissionDenied, Message: fmt.Sprintf("the API token %q is confined to its own credentials; as and asGroups cannot select another user", creds.Name)}.result(), nil
+		}
+		if !common.DefaultImpersonation.IsZero() && impersonation.String() != common.DefaultImpersonation.String() {
+			return invalidArgument("the server impersonates %q for every call; as and asGroups cannot select another user", common.DefaultImpersonation.User), nil
+		}
+		if err := impersonation.Validate(); err != nil {
+			return invalidArgument("%v", err), nil
+		}
+		return next(common.WithImpersonation(ctx, impersonation), request)
+	}
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resultErrorCode returns the code of a failed tool result, or "" for a successful one.
+func resultErrorCode(t *testing.T, result *mcp.CallToolResult) errorCode {
+	t.Helper()
+	if result == nil || !result.IsError {
+		return ""
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("error result content = %T, want text", result.Content[0])
+	}
+	var body map[string]toolError
+	if err := json.Unmarshal([]byte(text.Text), &body); err != nil {
+		t.Fatalf("error result %q is not a JSON tool error: %v", text.Text, err)
+	}
+	return body["error"].Code
+}
+
+// callRequest returns a request calling a tool with the given arguments.
+func callRequest(name string, args map[string]any) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	return request
+}
+
+func TestImpersonationMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultAs   common.Impersonation
+		creds       *common.Credentials
+		args        map[string]any
+		want        string
+		wantErrCode errorCode
+	}{
+		{name: "no impersonation", args: map[string]any{}},
+		{name: "user", args: map[string]any{"as": " alice "}, want: "alice:"},
+		{name: "user and groups", args: map[string]any{"as": "alice", "asGroups": []any{"team-b", "team-a"}}, want: "alice:team-a,team-b"},
+		{name: "groups without a user", args: map[string]any{"asGroups": []any{"team-a"}}, wantErrCode: codeInvalidArgument},
+		{name: "server impersonation kept", defaultAs: common.Impersonation{User: "bob"}, args: map[string]any{}, want: "bob:"},
+		{name: "server impersonation repeated", defaultAs: common.Impersonation{User: "bob"}, args: map[string]any{"as": "bob"}, want: "bob:"},
+		{name: "server impersonation overridden", defaultAs: common.Impersonation{User: "bob"}, args: map[string]any{"as": "alice"}, wantErrCode: codeInvalidArgument},
+		{name: "API token without impersonation", creds: &common.Credentials{Name: "team-a"}, args: map[string]any{}},
+		{name: "API token impersonating", creds: &common.Credentials{Name: "team-a"}, args: map[string]any{"as": "alice"}, wantErrCode: codePermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := common.DefaultImpersonation
+			t.Cleanup(func() { common.DefaultImpersonation = previous })
+			common.DefaultImpersonation = tt.defaultAs
+
+			ctx := context.Background()
+			if tt.creds != nil {
+				ctx = common.WithCredentials(ctx, *tt.creds)
+			}
+			called := false
+			var got string
+			handler := ImpersonationMiddleware(func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				called = true
+				got = common.ImpersonationOf(ctx).String()
+				return mcp.NewToolResultText("ok"), nil
+			})
+			result, err := handler(ctx, callRequest("apply_policies", tt.args))
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if code := resultErrorCode(t, result); code != tt.wantErrCode {
+				t.Fatalf("result error code = %q, want %q", code, tt.wantErrCode)
+			}
+			if called != (tt.wantErrCode == "") {
+				t.Fatalf("next handler called = %v, want %v", called, tt.wantErrCode == "")
+			}
+			if got != tt.want {
+				t.Errorf("impersonation = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
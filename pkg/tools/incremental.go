@@ -0,0 +1,235 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/processor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// maxScanBaselines bounds the number of scans kept as baselines of incremental scans.
+const maxScanBaselines = 8
+
+// incrementalSummary describes how an incremental scan reused the result of the previous one.
+type incrementalSummary struct {
+	// Baseline is the time of the full scan the result builds on.
+	Baseline string `json:"baseline"`
+	// Changed counts the resources created or modified since the previous scan, which were
+	// evaluated again.
+	Changed int `json:"changed"`
+	// Removed counts the resources deleted since the previous scan.
+	Removed int `json:"removed"`
+	// Unchanged counts the resources whose results were reused.
+	Unchanged int `json:"unchanged"`
+}
+
+// scanBaseline is the result of the previous scan of an incremental scan, with the
+// resourceVersions of the resources it evaluated.
+type scanBaseline struct {
+	result   *kyverno.ApplyResult
+	warnings []string
+	versions map[string]string
+	// at is the time of the full scan the result builds on.
+	at time.Time
+}
+
+// scanBaselines keeps the baselines of incremental scans, keyed like the scan cache.
+type scanBaselines struct {
+	mu        sync.Mutex
+	baselines map[string]scanBaseline
+}
+
+var incrementalBaselines = &scanBaselines{baselines: map[string]scanBaseline{}}
+
+func (b *scanBaselines) get(key string) (scanBaseline, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	base, ok := b.baselines[key]
+	return base, ok
+}
+
+// put records a baseline, dropping the one of the oldest full scan once the store is full.
+func (b *scanBaselines) put(key string, base scanBaseline) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.baselines[key]; !ok && len(b.baselines) >= maxScanBaselines {
+		var oldestKey string
+		for k, s := range b.baselines {
+			if oldestKey == "" || s.at.Before(b.baselines[oldestKey].at) {
+				oldestKey = k
+			}
+		}
+		delete(b.baselines, oldestKey)
+	}
+	b.baselines[key] = base
+}
+
+// clear drops every baseline, e.g. when the current kubeconfig context changes.
+func (b *scanBaselines) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.baselines)
+}
+
+func newScanBaseline(result *kyverno.ApplyResult, warnings []string, at time.Time) scanBaseline {
+	versions := make(map[string]string, len(result.Unstructured))
+	for _, u := range result.Unstructured {
+		if u != nil {
+			versions[resourceKey(*u)] = u.GetResourceVersion()
+		}
+	}
+	return scanBaseline{result: result, warnings: warnings, versions: versions, at: at}
+}
+
+// resourceKey identifies a resource across scans.
+func resourceKey(u unstructured.Unstructured) string {
+	return u.GetAPIVersion() + "/" + u.GetKind() + "/" + u.GetNamespace() + "/" + u.GetName()
+}
+
+// incrementalScan scans the cluster like runScan on its first call for a key, and afterwards
+// only evaluates the resources whose resourceVersion changed since the previous call, reusing
+// the results of the others. Only the kinds evaluated by the first scan are tracked, and changed
+// resources are evaluated offline, so policies looking up other cluster objects need a full scan.
+func incrementalScan(ctx context.Context, opts applyOptions, key string, policyPaths []string, userInfoPath, contextPath string) (*kyverno.ApplyResult, []string, *incrementalSummary, error) {
+	base, ok := incrementalBaselines.get(key)
+	if !ok {
+		result, warnings, err := runScan(ctx, opts, policyPaths, nil, userInfoPath, contextPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		incrementalBaselines.put(key, newScanBaseline(result, warnings, time.Now()))
+		return result, warnings, nil, nil
+	}
+
+	kinds := map[schema.GroupVersionKind]struct{}{}
+	for _, u := range base.result.Unstructured {
+		if u != nil {
+			kinds[u.GroupVersionKind()] = struct{}{}
+		}
+	}
+	current, err := listResourcesOfKinds(ctx, opts.namespaces, kinds, opts.selector)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list resources changed since the previous scan: %w", err)
+	}
+	unchanged := func(u unstructured.Unstructured) bool {
+		cur, ok := current[resourceKey(u)]
+		return ok && cur.GetResourceVersion() == u.GetResourceVersion()
+	}
+
+	// The baseline may be in use by concurrent calls, so nothing of it is modified
+	merged := &kyverno.ApplyResult{
+		ResultCounts:    &processor.ResultCounts{},
+		SkippedPolicies: slices.Clone(base.result.SkippedPolicies),
+		Warnings:        slices.Clone(base.result.Warnings),
+	}
+	for _, u := range base.result.Unstructured {
+		if u != nil && unchanged(*u) {
+			merged.Unstructured = append(merged.Unstructured, u)
+		}
+	}
+	for _, er := range base.result.EngineResponses {
+		if unchanged(er.Resource) {
+			merged.EngineResponses = append(merged.EngineResponses, er)
+		}
+	}
+
+	summary := &incrementalSummary{Baseline: base.at.UTC().Format(time.RFC3339), Unchanged: len(merged.Unstructured)}
+	var changed []*unstructured.Unstructured
+	for k, u := range current {
+		if version, ok := base.versions[k]; !ok || version != u.GetResourceVersion() {
+			changed = append(changed, u)
+		}
+	}
+	for k := range base.versions {
+		if _, ok := current[k]; !ok {
+			summary.Removed++
+		}
+	}
+	// The resources beyond the limit of resources per scan are left out like in a full scan,
+	// in the same order
+	warnings := base.warnings
+	if room := max(MaxScanResources-len(merged.Unstructured), 0); MaxScanResources > 0 && len(changed) > room {
+		sortResources(changed)
+		changed = changed[:room]
+		if !slices.Contains(warnings, resourceLimitWarning()) {
+			warnings = append(slices.Clone(warnings), resourceLimitWarning())
+		}
+	}
+	summary.Changed = len(changed)
+	clientLog(ctx, mcp.LoggingLevelInfo, "incremental scan", "changed", summary.Changed, "removed", summary.Removed, "unchanged", summary.Unchanged)
+
+	if len(changed) > 0 {
+		result, err := scanResources(ctx, opts, policyPaths, userInfoPath, contextPath, changed)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mergeApplyResult(merged, result)
+	}
+	merged.ResultCounts = countResults(opts.auditAsWarn, merged.EngineResponses)
+
+	incrementalBaselines.put(key, newScanBaseline(merged, base.warnings, base.at))
+	return merged, warnings, summary, nil
+}
+
+// listResourcesOfKinds lists the current resources of kinds matching selector in the namespaces
+// of scope, keyed by resourceKey.
+func listResourcesOfKinds(ctx context.Context, scope common.NamespaceScope, kinds map[schema.GroupVersionKind]struct{}, selector resourceSelector) (map[string]*unstructured.Unstructured, error) {
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]*unstructured.Unstructured{}
+	for gvk := range kinds {
+		mapping, err := clients.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, err
+		}
+		namespaces := []string{""}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace && !scope.All {
+			namespaces = scope.Namespaces
+		} else if mapping.Scope.Name() != meta.RESTScopeNameNamespace && !scope.Includes("") {
+			continue
+		}
+		for _, ns := range namespaces {
+			items, err := listCached(ctx, clients, mapping.Resource, ns, selector)
+			if err != nil {
+				return nil, err
+			}
+			for i := range items {
+				if mapping.Scope.Name() == meta.RESTScopeNameNamespace && !scope.Includes(items[i].GetNamespace()) {
+					continue
+				}
+				current[resourceKey(items[i])] = &items[i]
+			}
+		}
+	}
+	return current, nil
+}
+
+// scanResources evaluates the given cluster resources offline, with the policies, exceptions and
+// values of the scan described by opts.
+func scanResources(ctx context.Context, opts applyOptions, policyPaths []string, userInfoPath, contextPath string, resources []*unstructured.Unstructured) (*kyverno.ApplyResult, error) {
+	config, cleanup, err := scanConfig(ctx, opts, policyPaths, nil, userInfoPath, contextPath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	result, err := applyOffline(ctx, "changed resources", config, resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply policy to changed resources: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewScanBaseline(t *testing.T) {
+	resource := func(apiVersion, kind, namespace, name, version string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		u.SetResourceVersion(version)
+		return u
+	}
+	tests := []struct {
+		name      string
+		resources []*unstructured.Unstructured
+		want      map[string]string
+	}{
+		{name: "no resources", want: map[string]string{}},
+		{
+			name: "namespaced and cluster-scoped resources",
+			resources: []*unstructured.Unstructured{
+				resource("v1", "Pod", "team-a", "web", "10"),
+				resource("v1", "Namespace", "", "team-a", "3"),
+			},
+			want: map[string]string{"v1/Pod/team-a/web": "10", "v1/Namespace//team-a": "3"},
+		},
+		{
+			name: "same name in other namespaces and kinds",
+			resources: []*unstructured.Unstructured{
+				resource("v1", "Pod", "team-a", "web", "10"),
+				resource("v1", "Pod", "team-b", "web", "11"),
+				resource("apps/v1", "Deployment", "team-a", "web", "12"),
+			},
+			want: map[string]string{"v1/Pod/team-a/web": "10", "v1/Pod/team-b/web": "11", "apps/v1/Deployment/team-a/web": "12"},
+		},
+		{
+			name:      "missing resources",
+			resources: []*unstructured.Unstructured{nil, resource("v1", "Pod", "team-a", "web", "10")},
+			want:      map[string]string{"v1/Pod/team-a/web": "10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := newScanBaseline(&kyverno.ApplyResult{Unstructured: tt.resources}, nil, time.Now())
+			if !maps.Equal(base.versions, tt.want) {
+				t.Errorf("newScanBaseline() versions = %v, want %v", base.versions, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanBaselinesPut(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		key         string
+		wantDropped string
+	}{
+		{name: "new scan of a full store", key: "new", wantDropped: "scan-0"},
+		{name: "repeated scan of a full store", key: "scan-0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &scanBaselines{baselines: map[string]scanBaseline{}}
+			for i := range maxScanBaselines {
+				b.put(fmt.Sprintf("scan-%d", i), scanBaseline{at: now.Add(time.Duration(i) * time.Second)})
+			}
+			b.put(tt.key, scanBaseline{at: now.Add(time.Hour)})
+			if len(b.baselines) != maxScanBaselines {
+				t.Errorf("store holds %d baselines, want %d", len(b.baselines), maxScanBaselines)
+			}
+			if base, ok := b.get(tt.key); !ok || !base.at.Equal(now.Add(time.Hour)) {
+				t.Errorf("get(%q) did not return the latest baseline", tt.key)
+			}
+			if _, ok := b.get(tt.wantDropped); tt.wantDropped != "" && ok {
+				t.Errorf("put() kept the baseline of the oldest scan %q", tt.wantDropped)
+			}
+		})
+	}
+}
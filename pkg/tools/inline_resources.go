@@ -0,0 +1,54 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// normalizeInlineResources converts inline manifests into the multi-document stream loaded by the
+// Kyverno CLI. Besides YAML documents separated by ---, it accepts JSON arrays of objects and
+// List objects such as the output of "kubectl get -o json", which are flattened into their
+// items. It fails on documents that are not Kubernetes objects, naming the offending document.
+func normalizeInlineResources(manifests string) ([]byte, error) {
+	docs, err := splitDocuments([]byte(manifests))
+	if err != nil {
+		return nil, err
+	}
+
+	var out [][]byte
+	for i, doc := range docs {
+		var obj map[string]any
+		if err := yaml.Unmarshal(doc, &obj); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		u := unstructured.Unstructured{Object: obj}
+		if strings.HasSuffix(u.GetKind(), "List") {
+			if err := u.EachListItem(func(item runtime.Object) error {
+				raw, err := json.Marshal(item)
+				if err != nil {
+					return err
+				}
+				out = append(out, raw)
+				return nil
+			}); err != nil {
+				return nil, fmt.Errorf("document %d: %w", i+1, err)
+			}
+			continue
+		}
+		if u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("document %d: kind and metadata.name are required", i+1)
+		}
+		out = append(out, doc)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no resources found")
+	}
+	return bytes.Join(out, []byte("\n---\n")), nil
+}
@@ -0,0 +1,81 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	"github.com/nirmata/kyverno-mcp/pkg/tracing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// installedPolicySet is the policySets value that selects the Kyverno policies installed in the
+// cluster instead of an embedded policy set.
+const installedPolicySet = "installed"
+
+// installedPolicies lists the ClusterPolicies and Policies installed in the cluster and returns
+// them as a multi-document YAML stream, so they can be evaluated like the embedded policy sets.
+func installedPolicies(ctx context.Context) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "load installed policies")
+	defer span.End()
+
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("build kube-config: %w", err)
+	}
+	dyn := clients.Dynamic
+
+	// Policies are only listed in the namespaces the server is allowed to access
+	namespaces, err := common.ResolveNamespaces(common.AllNamespaces, "")
+	if err != nil {
+		return nil, err
+	}
+	var lists []dynamic.ResourceInterface
+	lists = append(lists, dyn.Resource(clusterPolicyGVR))
+	if namespaces.All {
+		lists = append(lists, dyn.Resource(policyGVR))
+	} else {
+		for _, ns := range namespaces.Namespaces {
+			lists = append(lists, dyn.Resource(policyGVR).Namespace(ns))
+		}
+	}
+
+	var data []byte
+	count := 0
+	for _, ri := range lists {
+		items, err := listPaged(ctx, ri, "")
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("list Kyverno policies: %w", err)
+		}
+		for _, item := range items {
+			// Drop server-populated fields that the CLI policy loader does not need.
+			item.SetManagedFields(nil)
+			item.SetResourceVersion("")
+			item.SetUID("")
+			item.SetGeneration(0)
+			item.SetCreationTimestamp(metav1.Time{})
+			unstructured.RemoveNestedField(item.Object, "status")
+			raw, err := json.Marshal(item.Object)
+			if err != nil {
+				return nil, fmt.Errorf("marshal policy %s: %w", item.GetName(), err)
+			}
+			if len(data) > 0 {
+				data = append(data, []byte("\n---\n")...)
+			}
+			data = append(data, raw...)
+			count++
+		}
+	}
+	klog.V(2).InfoS("loaded Kyverno policies from cluster", "count", count)
+	return data, nil
+}
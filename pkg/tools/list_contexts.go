@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
 
 	"k8s.io/klog/v2"
 
@@ -28,8 +31,23 @@ func ListContexts(s *server.MCPServer) {
 	klog.InfoS("Registering tool: list_contexts")
 	s.AddTool(mcp.NewTool("list_contexts",
 		mcp.WithDescription("List all available Kubernetes contexts"),
-	), func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "List Kubernetes contexts",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithNumber("limit", mcp.Description(`Maximum number of contexts to return. When set, the response is wrapped as {results, total, nextCursor} (default: no limit)`)),
+		mcp.WithString("cursor", mcp.Description(`Opaque cursor returned as nextCursor by a previous call, used to fetch the next page of contexts`)),
+		mcp.WithString("continue", mcp.Description(`Alias of cursor, for clients used to Kubernetes list pagination`)),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		klog.InfoS("Tool 'list_contexts' invoked.")
+		page, err := common.NewPageRequest(request.GetInt("limit", 0), request.GetString("cursor", ""), request.GetString("continue", ""))
+		if err != nil {
+			return invalidArgument("%v", err), nil
+		}
+
 		// Load the Kubernetes configuration from the specified kubeconfig or default location
 		loadingRules := newLoadingRules()
 		configOverrides := &clientcmd.ConfigOverrides{}
@@ -38,24 +56,39 @@ func ListContexts(s *server.MCPServer) {
 		rawConfig, err := config.RawConfig()
 		if err != nil {
 			klog.ErrorS(err, "Error in 'list_contexts': failed to load kubeconfig")
-			return mcp.NewToolResultError(fmt.Sprintf("Error loading kubeconfig: %v", err)), nil
+			return errorResult(fmt.Errorf("error loading kubeconfig: %w", err)), nil
 		}
 
-		// Extract context names
+		// Extract context names, sorted so that pages are stable
 		var contexts []string
 		for name := range rawConfig.Contexts {
 			contexts = append(contexts, name)
 		}
+		sort.Strings(contexts)
+		// API tokens confined to their own credentials only see the context they use
+		if creds, ok := common.CredentialsOf(ctx); ok {
+			name := creds.Context
+			if name == "" {
+				name = rawConfig.CurrentContext
+			}
+			contexts = nil
+			if _, ok := rawConfig.Contexts[name]; ok {
+				contexts = []string{name}
+			}
+		}
 
 		// Return the list of contexts as a JSON array
-		result := map[string]interface{}{
+		var result any = map[string]interface{}{
 			"available_contexts": contexts,
 		}
+		if page.Enabled() {
+			result = common.NewPage(contexts, page)
+		}
 
 		resultJSON, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
 			klog.ErrorS(err, "Error in 'list_contexts': failed to format result")
-			return mcp.NewToolResultError(fmt.Sprintf("Error formatting result: %v", err)), nil
+			return errorResult(fmt.Errorf("error formatting result: %w", err)), nil
 		}
 
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -0,0 +1,64 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+// loggerName identifies the server in MCP log notifications.
+const loggerName = "kyverno-mcp"
+
+// logLevelRank orders MCP logging levels from least to most severe.
+var logLevelRank = map[mcp.LoggingLevel]int{
+	mcp.LoggingLevelDebug:     0,
+	mcp.LoggingLevelInfo:      1,
+	mcp.LoggingLevelNotice:    2,
+	mcp.LoggingLevelWarning:   3,
+	mcp.LoggingLevelError:     4,
+	mcp.LoggingLevelCritical:  5,
+	mcp.LoggingLevelAlert:     6,
+	mcp.LoggingLevelEmergency: 7,
+}
+
+// clientLog sends a log message to the client of the current session as an MCP logging
+// notification, if the client asked for messages of that level with logging/setLevel. The
+// data holds the message and the given key/value pairs.
+func clientLog(ctx context.Context, level mcp.LoggingLevel, message string, keysAndValues ...any) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || !logEnabled(server.ClientSessionFromContext(ctx), level) {
+		return
+	}
+	if err := srv.SendNotificationToClient(ctx, "notifications/message", logParams(level, message, keysAndValues)); err != nil {
+		klog.V(2).InfoS("failed to send log notification", "error", err)
+	}
+}
+
+// sessionLog is like clientLog but sends the message to the client of a session outside of any
+// request, e.g. from a background watch. It fails if the session is gone.
+func sessionLog(srv *server.MCPServer, session server.ClientSession, level mcp.LoggingLevel, message string, keysAndValues ...any) error {
+	if !logEnabled(session, level) {
+		return nil
+	}
+	return srv.SendNotificationToSpecificClient(session.SessionID(), "notifications/message", logParams(level, message, keysAndValues))
+}
+
+// logEnabled reports whether the client of a session accepts log messages of the given level.
+func logEnabled(session server.ClientSession, level mcp.LoggingLevel) bool {
+	logging, ok := session.(server.SessionWithLogging)
+	return ok && logLevelRank[level] >= logLevelRank[logging.GetLogLevel()]
+}
+
+// logParams builds the parameters of a notifications/message notification.
+func logParams(level mcp.LoggingLevel, message string, keysAndValues []any) map[string]any {
+	data := map[string]any{"message": message}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok {
+			data[key] = keysAndValues[i+1]
+		}
+	}
+	return map[string]any{"level": level, "logger": loggerName, "data": data}
+}
@@ -0,0 +1,76 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	// ociScheme prefixes policy paths that refer to an OCI image pushed with "kyverno oci push".
+	ociScheme = "oci://"
+	// policyLayerMediaType is the media type of the image layers holding the policies.
+	policyLayerMediaType = "application/vnd.cncf.kyverno.policy.layer.v1+yaml"
+)
+
+// isOCIPath reports whether a path argument refers to an OCI image.
+func isOCIPath(p string) bool {
+	return strings.HasPrefix(p, ociScheme)
+}
+
+// pullPolicyImage downloads an OCI image pushed with "kyverno oci push" and returns the documents
+// of its policy layers. Registry credentials are taken from the Docker config in DOCKER_CONFIG,
+// set by the --registry-config flag, or ~/.docker.
+func pullPolicyImage(ctx context.Context, imageRef string) ([][]byte, error) {
+	ref, err := name.ParseReference(strings.TrimPrefix(imageRef, ociScheme))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %s: %w", imageRef, err)
+	}
+	desc, err := remote.Get(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", imageRef, err)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", imageRef, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layers of %s: %w", imageRef, err)
+	}
+
+	var docs [][]byte
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer of %s: %w", imageRef, err)
+		}
+		if mediaType != policyLayerMediaType {
+			continue
+		}
+		blob, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer of %s: %w", imageRef, err)
+		}
+		content, err := io.ReadAll(blob)
+		_ = blob.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer of %s: %w", imageRef, err)
+		}
+		layerDocs, err := splitDocuments(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse layer of %s: %w", imageRef, err)
+		}
+		docs = append(docs, layerDocs...)
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("image %s has no Kyverno policy layers", imageRef)
+	}
+	return docs, nil
+}
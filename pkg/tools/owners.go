@@ -0,0 +1,181 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"errors"
+
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	"github.com/kyverno/kyverno/pkg/autogen"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	kubeutils "github.com/kyverno/kyverno/pkg/utils/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// maxOwnerDepth bounds the number of controller owner references followed for a single resource,
+// e.g. Pod → ReplicaSet → Deployment.
+const maxOwnerDepth = 5
+
+// maxOwnerLookups bounds the number of resources a single ownerResolver fetches, so that
+// resolving the owners of many violations does not flood the API server.
+const maxOwnerLookups = 100
+
+// errOwnerLookupLimit is returned once an ownerResolver has made maxLookups lookups.
+var errOwnerLookupLimit = errors.New("owner lookup limit reached")
+
+// ownerResolver resolves resources to the top-level workload controlling them by following
+// controller owner references. Lookups are cached for the lifetime of the resolver.
+type ownerResolver struct {
+	dyn    dynamic.Interface
+	mapper meta.RESTMapper
+	cache  map[string]*corev1.ObjectReference
+	// maxLookups bounds lookups, and limited counts the resources not resolved because of it.
+	lookups, maxLookups, limited int
+}
+
+// newOwnerResolver returns a resolver that maps kinds to resources through mapper and makes at
+// most maxOwnerLookups lookups.
+func newOwnerResolver(mapper meta.RESTMapper, dyn dynamic.Interface) *ownerResolver {
+	return &ownerResolver{
+		dyn:        dyn,
+		mapper:     mapper,
+		cache:      map[string]*corev1.ObjectReference{},
+		maxLookups: maxOwnerLookups,
+	}
+}
+
+// resolve returns the top-level workload owning ref, or false if ref is cluster-scoped, has no
+// controller, or its owners cannot be read. Lookup failures are logged and otherwise ignored;
+// the resources not resolved because the lookup limit was reached are counted in r.limited.
+func (r *ownerResolver) resolve(ctx context.Context, ref corev1.ObjectReference) (corev1.ObjectReference, bool) {
+	if ref.Namespace == "" || ref.Name == "" {
+		return corev1.ObjectReference{}, false
+	}
+	key := resourceIdentifier(ref)
+	if owner, ok := r.cache[key]; ok {
+		if owner == nil {
+			return corev1.ObjectReference{}, false
+		}
+		return *owner, true
+	}
+
+	current, depth := ref, 0
+	for ; depth < maxOwnerDepth; depth++ {
+		controller, err := r.controllerOf(ctx, current)
+		if errors.Is(err, errOwnerLookupLimit) {
+			// Not cached, the resource is merely left unresolved by this resolver
+			r.limited++
+			return corev1.ObjectReference{}, false
+		}
+		if err != nil {
+			klog.V(2).InfoS("cannot resolve owner", "resource", resourceIdentifier(current), "error", err)
+			break
+		}
+		if controller == nil {
+			break
+		}
+		current = corev1.ObjectReference{
+			APIVersion: controller.APIVersion,
+			Kind:       controller.Kind,
+			Namespace:  ref.Namespace,
+			Name:       controller.Name,
+			UID:        controller.UID,
+		}
+	}
+
+	if depth == 0 {
+		r.cache[key] = nil
+		return corev1.ObjectReference{}, false
+	}
+	r.cache[key] = &current
+	return current, true
+}
+
+// controllerOf fetches ref and returns its controller owner reference, or nil if it has none.
+func (r *ownerResolver) controllerOf(ctx context.Context, ref corev1.ObjectReference) (*metav1.OwnerReference, error) {
+	if r.lookups >= r.maxLookups {
+		return nil, errOwnerLookupLimit
+	}
+	r.lookups++
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := r.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := r.dyn.Resource(mapping.Resource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return metav1.GetControllerOf(obj), nil
+}
+
+// identifierOf returns the resourceIdentifier of an unstructured resource.
+func identifierOf(u unstructured.Unstructured) string {
+	return resourceIdentifier(corev1.ObjectReference{Kind: u.GetKind(), Namespace: u.GetNamespace(), Name: u.GetName()})
+}
+
+// controllerOwnership tells which results for Pods and ReplicaSets duplicate the findings
+// reported for their controller, i.e. the controller is part of the same scan and the autogen
+// rules of the policy match its kind.
+type controllerOwnership struct {
+	scanned map[string]struct{}
+	kinds   map[string]map[string]struct{}
+}
+
+// newControllerOwnership returns the ownership of the resources of a scan.
+func newControllerOwnership(resources []*unstructured.Unstructured) *controllerOwnership {
+	scanned := make(map[string]struct{}, len(resources))
+	for _, u := range resources {
+		if u != nil {
+			scanned[identifierOf(*u)] = struct{}{}
+		}
+	}
+	return &controllerOwnership{scanned: scanned, kinds: map[string]map[string]struct{}{}}
+}
+
+// duplicates reports whether the findings of er are also reported for the controller of its
+// resource. Only Kyverno policies are autogenerated for controllers.
+func (o *controllerOwnership) duplicates(er engineapi.EngineResponse) bool {
+	switch er.Resource.GetKind() {
+	case "Pod", "ReplicaSet":
+	default:
+		return false
+	}
+	controller := metav1.GetControllerOf(&er.Resource)
+	if controller == nil || er.Policy() == nil || er.Policy().AsKyvernoPolicy() == nil {
+		return false
+	}
+	owner := resourceIdentifier(corev1.ObjectReference{Kind: controller.Kind, Namespace: er.Resource.GetNamespace(), Name: controller.Name})
+	if _, ok := o.scanned[owner]; !ok {
+		return false
+	}
+	_, ok := o.ruleKinds(er.Policy())[controller.Kind]
+	return ok
+}
+
+// ruleKinds returns the kinds matched by the rules of policy, including the autogenerated ones.
+func (o *controllerOwnership) ruleKinds(policy engineapi.GenericPolicy) map[string]struct{} {
+	key := kyverno.PolicyKey(policy)
+	if kinds, ok := o.kinds[key]; ok {
+		return kinds
+	}
+	kinds := map[string]struct{}{}
+	for _, rule := range autogen.Default.ComputeRules(policy.AsKyvernoPolicy(), "") {
+		for _, selector := range ruleKinds(rule) {
+			_, _, kind, _ := kubeutils.ParseKindSelector(selector)
+			kinds[kind] = struct{}{}
+		}
+	}
+	o.kinds[key] = kinds
+	return kinds
+}
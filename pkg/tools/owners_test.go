@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// ownedObject returns a resource of team-a, controlled by the named owner when owner is set.
+func ownedObject(apiVersion, kind, name, ownerAPIVersion, ownerKind, owner string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]any{"name": name, "namespace": "team-a"},
+	}}
+	if owner != "" {
+		u.Object["metadata"].(map[string]any)["ownerReferences"] = []any{map[string]any{
+			"apiVersion": ownerAPIVersion,
+			"kind":       ownerKind,
+			"name":       owner,
+			"uid":        owner + "-uid",
+			"controller": true,
+		}}
+	}
+	return u
+}
+
+func TestOwnerResolver(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	replicaSets := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	pod := ownedObject("v1", "Pod", "web-abc-1", "apps/v1", "ReplicaSet", "web-abc")
+	replicaSet := ownedObject("apps/v1", "ReplicaSet", "web-abc", "apps/v1", "Deployment", "web")
+	deployment := ownedObject("apps/v1", "Deployment", "web", "", "", "")
+	bare := ownedObject("v1", "Pod", "bare", "", "", "")
+	podRef := corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "team-a", Name: "web-abc-1"}
+
+	tests := []struct {
+		name       string
+		objects    []runtime.Object
+		forbidden  string
+		maxLookups int
+		ref        corev1.ObjectReference
+		want       string
+		wantOK     bool
+		wantLimit  int
+	}{
+		{name: "pod to deployment", objects: []runtime.Object{pod, replicaSet, deployment}, ref: podRef, want: "Deployment/team-a/web", wantOK: true},
+		{name: "missing top-level owner", objects: []runtime.Object{pod, replicaSet}, ref: podRef, want: "Deployment/team-a/web", wantOK: true},
+		{name: "missing intermediate owner", objects: []runtime.Object{pod, deployment}, ref: podRef, want: "ReplicaSet/team-a/web-abc", wantOK: true},
+		{name: "forbidden owner", objects: []runtime.Object{pod, replicaSet, deployment}, forbidden: "replicasets", ref: podRef, want: "ReplicaSet/team-a/web-abc", wantOK: true},
+		{name: "forbidden resource", objects: []runtime.Object{pod, replicaSet, deployment}, forbidden: "pods", ref: podRef},
+		{name: "missing resource", objects: []runtime.Object{replicaSet, deployment}, ref: podRef},
+		{name: "resource without controller", objects: []runtime.Object{bare}, ref: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "team-a", Name: "bare"}},
+		{name: "cluster-scoped resource", objects: []runtime.Object{pod}, ref: corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: "team-a"}},
+		{name: "lookup limit", objects: []runtime.Object{pod, replicaSet, deployment}, maxLookups: 2, ref: podRef, wantLimit: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				pods:        "PodList",
+				replicaSets: "ReplicaSetList",
+				deployments: "DeploymentList",
+			}, tt.objects...)
+			if tt.forbidden != "" {
+				client.PrependReactor("get", tt.forbidden, func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(action.GetResource().GroupResource(), "", nil)
+				})
+			}
+			resolver := newOwnerResolver(mapper, client)
+			if tt.maxLookups > 0 {
+				resolver.maxLookups = tt.maxLookups
+			}
+
+			got, ok := resolver.resolve(context.Background(), tt.ref)
+			if ok != tt.wantOK {
+				t.Fatalf("resolve() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && resourceIdentifier(got) != tt.want {
+				t.Errorf("resolve() = %s, want %s", resourceIdentifier(got), tt.want)
+			}
+			if resolver.limited != tt.wantLimit {
+				t.Errorf("limited = %d, want %d", resolver.limited, tt.wantLimit)
+			}
+
+			// The result is cached, so resolving again makes no further requests
+			requests := len(client.Actions())
+			if again, againOK := resolver.resolve(context.Background(), tt.ref); againOK != ok || again != got {
+				t.Errorf("second resolve() = %v, %v, want %v, %v", again, againOK, got, ok)
+			}
+			if tt.wantLimit == 0 && len(client.Actions()) != requests {
+				t.Errorf("second resolve() made %d requests, want none", len(client.Actions())-requests)
+			}
+		})
+	}
+}
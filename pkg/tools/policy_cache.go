@@ -0,0 +1,180 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// maxCachedPolicyFiles bounds the number of prepared policy files kept between scans. Selections
+// of embedded sets and the policies installed in clusters each get their own file.
+const maxCachedPolicyFiles = 32
+
+// policyDocument is a document of a multi-document policy YAML.
+type policyDocument struct {
+	name string
+	// key identifies the policy the way kyverno.PolicyKey does, i.e. as namespace/name when the
+	// policy is namespaced.
+	key string
+	// spec is the normalized kind and spec of the policy, see normalizedPolicySpec.
+	spec string
+	data []byte
+}
+
+// cachedPolicyFile is a policy file prepared for a previous scan.
+type cachedPolicyFile struct {
+	path     string
+	refs     int
+	lastUsed time.Time
+	// evicted is set once the entry left the cache; the file is removed by its last user.
+	evicted bool
+}
+
+// policyCache keeps the policy files written for previous scans, keyed by policy set and content
+// hash, together with the documents of the policy sets they were selected from, so that repeated
+// scans with the same policies do not split, select and write them again.
+type policyCache struct {
+	mu        sync.Mutex
+	files     map[string]*cachedPolicyFile
+	documents map[string][]policyDocument
+}
+
+var cachedPolicies = &policyCache{files: map[string]*cachedPolicyFile{}, documents: map[string][]policyDocument{}}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// documentsOf returns the named documents of a multi-document policy YAML, splitting it only the
+// first time a given content is seen.
+func (c *policyCache) documentsOf(data []byte) ([]policyDocument, error) {
+	hash := contentHash(data)
+	c.mu.Lock()
+	docs, ok := c.documents[hash]
+	c.mu.Unlock()
+	if ok {
+		return docs, nil
+	}
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy set: %w", err)
+		}
+		var meta metav1.PartialObjectMetadata
+		if err := yaml.Unmarshal(doc, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse policy set: %w", err)
+		}
+		if meta.Name == "" {
+			continue
+		}
+		spec, err := normalizedPolicySpec(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse policy %s: %w", meta.Name, err)
+		}
+		key := meta.Name
+		if meta.Namespace != "" {
+			key = meta.Namespace + "/" + meta.Name
+		}
+		docs = append(docs, policyDocument{name: meta.Name, key: key, spec: spec, data: bytes.TrimSpace(doc)})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.documents) >= maxCachedPolicyFiles {
+		clear(c.documents)
+	}
+	c.documents[hash] = docs
+	return docs, nil
+}
+
+// file returns the path of a file holding data, the policies of set, writing it only if no
+// previous scan did. The returned function must be called once the file is no longer used; the
+// file stays cached for later scans until it is evicted.
+func (c *policyCache) file(set string, data []byte) (string, func(), error) {
+	key := set + "/" + contentHash(data)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.files[key]
+	if ok {
+		// Temporary directories may be cleaned up behind the server's back
+		if _, err := os.Stat(entry.path); err != nil {
+			delete(c.files, key)
+			entry.evicted, ok = true, false
+		}
+	}
+	if !ok {
+		path, err := writeTempFile("kyverno-policy-*.yaml", data)
+		if err != nil {
+			return "", nil, err
+		}
+		entry = &cachedPolicyFile{path: path}
+		c.evictLocked()
+		c.files[key] = entry
+		klog.V(2).InfoS("Cached policy file", "policySet", set, "path", path)
+	}
+	entry.refs++
+	entry.lastUsed = time.Now()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			entry.refs--
+			if entry.evicted && entry.refs == 0 {
+				_ = os.Remove(entry.path)
+			}
+		})
+	}
+	return entry.path, release, nil
+}
+
+// evictLocked drops the least recently used file once the cache is full. Files still used by a
+// scan are removed when it releases them.
+func (c *policyCache) evictLocked() {
+	if len(c.files) < maxCachedPolicyFiles {
+		return
+	}
+	var oldestKey string
+	var oldest *cachedPolicyFile
+	for key, entry := range c.files {
+		if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	delete(c.files, oldestKey)
+	oldest.evicted = true
+	if oldest.refs == 0 {
+		_ = os.Remove(oldest.path)
+	}
+}
+
+// RemoveCachedPolicies removes the policy files cached between scans. It is called when the
+// server exits.
+func RemoveCachedPolicies() {
+	cachedPolicies.mu.Lock()
+	defer cachedPolicies.mu.Unlock()
+	for key, entry := range cachedPolicies.files {
+		_ = os.Remove(entry.path)
+		delete(cachedPolicies.files, key)
+	}
+}
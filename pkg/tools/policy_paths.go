@@ -0,0 +1,347 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	"github.com/nirmata/kyverno-mcp/pkg/tracing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.opentelemetry.io/otel/attribute"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// policyKinds lists the kinds the Kyverno CLI loads from policy files.
+var policyKinds = map[string]struct{}{
+	"ClusterPolicy":                    {},
+	"Policy":                           {},
+	"ValidatingAdmissionPolicy":        {},
+	"ValidatingAdmissionPolicyBinding": {},
+	"ValidatingPolicy":                 {},
+	"ImageValidatingPolicy":            {},
+}
+
+// serverPathsDenied rejects a call authenticated by an API token that sets any of the named
+// arguments, which refer to files on the server or to URLs and images the server fetches. The
+// users of a hosted server are confined to their own cluster credentials, so they can neither
+// read the files of the server nor make it reach other hosts. It returns nil when the call may
+// proceed.
+func serverPathsDenied(ctx context.Context, request mcp.CallToolRequest, names ...string) *mcp.CallToolResult {
+	creds, ok := common.CredentialsOf(ctx)
+	if !ok {
+		return nil
+	}
+	for _, name := range names {
+		if request.GetString(name, "") != "" || len(request.GetStringSlice(name, nil)) > 0 {
+			return toolError{Code: codePermissionDenied, Message: fmt.Sprintf("the API token %q cannot use %s: files on the server and remote locations are not available to API tokens", creds.Name, name), Hint: "pass policies and resources inline, or use the embedded and installed policy sets"}.result()
+		}
+	}
+	return nil
+}
+
+// isRemotePath reports whether a path argument refers to an HTTP(S) URL.
+func isRemotePath(p string) bool {
+	return strings.HasPrefix(p, "https://") || strings.HasPrefix(p, "http://")
+}
+
+// policyFileExtensions lists the extensions of the files loaded from directories and patterns.
+var policyFileExtensions = map[string]struct{}{".yaml": {}, ".yml": {}, ".json": {}}
+
+// resourceFileExtensions lists the extensions of the resource manifests loaded from directories
+// and patterns.
+var resourceFileExtensions = map[string]struct{}{".yaml": {}, ".yml": {}, ".json": {}}
+
+// loadPolicyFiles expands the local paths among paths into policy files, reads them document by
+// document and merges the policy documents into a single multi-document stream. Documents of
+// other kinds are skipped, because the Kyverno CLI rejects a whole file if any of its documents
+// is not a policy, and files that cannot be read or parsed are skipped as well; both are reported
+// as warnings. Policies of OCI images are pulled and merged in as well, while HTTP(S) URLs are
+// returned unchanged for the CLI to load.
+func loadPolicyFiles(ctx context.Context, paths []string) (data []byte, passthrough []string, warnings []string, err error) {
+	ctx, span := tracing.Start(ctx, "load policies", attribute.StringSlice("policy.paths", paths))
+	defer func() { tracing.End(span, err) }()
+
+	var local []string
+	var docs [][]byte
+	for _, p := range paths {
+		if !isOCIPath(p) {
+			local = append(local, p)
+			continue
+		}
+		imageDocs, err := pullPolicyImage(ctx, p)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		docs = append(docs, imageDocs...)
+	}
+
+	files, passthrough, err := expandPaths(local, policyFileExtensions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, file := range files {
+		fileDocs, fileWarnings, err := readPolicyDocuments(file)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		docs = append(docs, fileDocs...)
+		warnings = append(warnings, fileWarnings...)
+	}
+	return bytes.Join(docs, []byte("\n---\n")), passthrough, warnings, nil
+}
+
+// maxWalkEntries bounds the files and directories visited to expand a single directory or glob
+// pattern, so that a path such as "/**/*.yaml" fails fast instead of walking a whole filesystem.
+var maxWalkEntries = 10000
+
+// expandPaths resolves path arguments into the files they refer to. Directories are walked
+// recursively and glob patterns are expanded, with "**" matching any number of directories; both
+// only yield files with one of the given extensions and skip hidden files and directories.
+// Plain file paths are kept as they are and remote URLs are returned separately.
+func expandPaths(paths []string, extensions map[string]struct{}) (files, remote []string, err error) {
+	for _, p := range paths {
+		if isRemotePath(p) {
+			remote = append(remote, p)
+			continue
+		}
+
+		if isGlobPattern(p) {
+			matches, err := expandGlob(p, extensions)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(matches) == 0 {
+				return nil, nil, fmt.Errorf("no files match %s", p)
+			}
+			files = append(files, matches...)
+			continue
+		}
+
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := walkFiles(p, extensions, nil, -1)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, remote, nil
+}
+
+// isGlobPattern reports whether a path contains glob metacharacters.
+func isGlobPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// expandGlob returns the files matching a glob pattern, in lexical order.
+func expandGlob(pattern string, extensions map[string]struct{}) ([]string, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	// Walk from the deepest directory that precedes the first metacharacter
+	root, rest := ".", pattern
+	if i := strings.LastIndex(pattern[:strings.IndexAny(pattern, "*?[")], "/"); i >= 0 {
+		root, rest = pattern[:i], pattern[i+1:]
+		if root == "" {
+			root = "/"
+		}
+	}
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	// Without "**", matches are exactly as deep below root as the rest of the pattern
+	maxDepth := -1
+	if !strings.Contains(rest, "**") {
+		maxDepth = strings.Count(rest, "/") + 1
+	}
+	return walkFiles(root, extensions, re, maxDepth)
+}
+
+// walkFiles returns the files below root with one of the given extensions, and matching re if it
+// is not nil, in lexical order. Hidden files and directories are skipped, as are files more than
+// maxDepth levels below root unless maxDepth is negative. Entries that cannot be read do not
+// stop the walk, they are reported together once it is done, and the walk stops with an error
+// once it has visited maxWalkEntries entries.
+func walkFiles(root string, extensions map[string]struct{}, re *regexp.Regexp, maxDepth int) ([]string, error) {
+	var files []string
+	var errs []error
+	visited := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			errs = append(errs, err)
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if visited++; visited > maxWalkEntries {
+			errs = append(errs, fmt.Errorf("%s has more than %d files and directories; use a narrower path or pattern", root, maxWalkEntries))
+			return filepath.SkipAll
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// Files in a directory at maxDepth would be too deep to match
+			if maxDepth >= 0 && depthBelow(root, path) >= maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := extensions[strings.ToLower(filepath.Ext(path))]; !ok {
+			return nil
+		}
+		if re != nil && !re.MatchString(filepath.ToSlash(path)) {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return files, nil
+}
+
+// depthBelow returns how many levels path is below root, which contains it.
+func depthBelow(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+// globToRegexp translates a slash-separated glob pattern into an anchored regular expression.
+// "*" and "?" do not match "/", while "**" matches across directories.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i : i+end+1]
+			if strings.HasPrefix(class, "[!") {
+				class = "[^" + class[2:]
+			}
+			b.WriteString(class)
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// readPolicyDocuments returns the policy documents of a multi-document YAML file, a JSON document
+// or a JSON array of documents, together with a warning for every document that is not a policy.
+func readPolicyDocuments(path string) ([][]byte, []string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rawDocs, err := splitDocuments(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var docs [][]byte
+	var warnings []string
+	for i, doc := range rawDocs {
+		var meta metav1.PartialObjectMetadata
+		if err := yaml.Unmarshal(doc, &meta); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse document %d of %s: %w", i+1, path, err)
+		}
+		if _, ok := policyKinds[meta.Kind]; !ok {
+			warnings = append(warnings, fmt.Sprintf("skipped document %d of %s: %q is not a policy kind", i+1, path, meta.Kind))
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, warnings, nil
+}
+
+// splitDocuments splits file content into its non-empty documents. A top-level JSON array yields
+// one document per element; anything else is read as a multi-document YAML stream, which also
+// covers single JSON documents.
+func splitDocuments(content []byte) ([][]byte, error) {
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		docs := make([][]byte, 0, len(items))
+		for _, item := range items {
+			docs = append(docs, item)
+		}
+		return docs, nil
+	}
+
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if doc = bytes.TrimSpace(doc); len(doc) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+}
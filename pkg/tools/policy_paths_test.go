@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		match   []string
+		noMatch []string
+		wantErr bool
+	}{
+		{name: "star stays within a directory", pattern: "policies/*.yaml", match: []string{"policies/a.yaml", "policies/.yaml"}, noMatch: []string{"policies/sub/a.yaml", "other/a.yaml"}},
+		{name: "double star spans directories", pattern: "policies/**/*.yaml", match: []string{"policies/a.yaml", "policies/x/y/a.yaml"}, noMatch: []string{"policies/a.yml", "other/a.yaml"}},
+		{name: "trailing double star", pattern: "policies/**", match: []string{"policies/a.yaml", "policies/x/a.json"}, noMatch: []string{"other/a.yaml"}},
+		{name: "question mark matches one character", pattern: "file?.yaml", match: []string{"file1.yaml"}, noMatch: []string{"file10.yaml", "file/.yaml"}},
+		{name: "character class", pattern: "[ab].yaml", match: []string{"a.yaml", "b.yaml"}, noMatch: []string{"c.yaml"}},
+		{name: "negated character class", pattern: "[!a]*.yaml", match: []string{"b.yaml", "cd.yaml"}, noMatch: []string{"a.yaml", "ab.yaml"}},
+		{name: "metacharacters are literal", pattern: "a.b+(c).yaml", match: []string{"a.b+(c).yaml"}, noMatch: []string{"axb+(c).yaml", "a.bb(c).yaml"}},
+		{name: "unterminated character class", pattern: "[ab.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := globToRegexp(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("globToRegexp(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, p := range tt.match {
+				if !re.MatchString(p) {
+					t.Errorf("globToRegexp(%q) does not match %q", tt.pattern, p)
+				}
+			}
+			for _, p := range tt.noMatch {
+				if re.MatchString(p) {
+					t.Errorf("globToRegexp(%q) matches %q", tt.pattern, p)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.json", "c.txt", ".hidden.yaml", "sub/d.yml", "sub/deep/e.YAML", ".git/f.yaml"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("kind: ClusterPolicy\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		paths      []string
+		wantFiles  []string
+		wantRemote []string
+		wantErr    bool
+	}{
+		{name: "directory is walked recursively", paths: []string{"."}, wantFiles: []string{"a.yaml", "b.json", "sub/d.yml", "sub/deep/e.YAML"}},
+		{name: "star pattern", paths: []string{"*.yaml"}, wantFiles: []string{"a.yaml"}},
+		{name: "double star pattern", paths: []string{"**/*.yml"}, wantFiles: []string{"sub/d.yml"}},
+		{name: "pattern below a directory", paths: []string{"sub/**"}, wantFiles: []string{"sub/d.yml", "sub/deep/e.YAML"}},
+		{name: "star directory segment", paths: []string{"*/*.yml"}, wantFiles: []string{"sub/d.yml"}},
+		{name: "pattern at a fixed depth", paths: []string{"sub/*/*.YAML"}, wantFiles: []string{"sub/deep/e.YAML"}},
+		{name: "plain file is kept whatever its extension", paths: []string{"c.txt"}, wantFiles: []string{"c.txt"}},
+		{name: "remote paths are returned separately", paths: []string{"a.yaml", "https://example.com/policy.yaml"}, wantFiles: []string{"a.yaml"}, wantRemote: []string{"https://example.com/policy.yaml"}},
+		{name: "missing file", paths: []string{"missing.yaml"}, wantErr: true},
+		{name: "pattern without matches", paths: []string{"*.yml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			for _, p := range tt.paths {
+				if !isRemotePath(p) {
+					p = filepath.Join(root, p)
+				}
+				paths = append(paths, p)
+			}
+			files, remote, err := expandPaths(paths, policyFileExtensions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var wantFiles []string
+			for _, f := range tt.wantFiles {
+				wantFiles = append(wantFiles, filepath.Join(root, f))
+			}
+			if !slices.Equal(files, wantFiles) {
+				t.Errorf("expandPaths() files = %v, want %v", files, wantFiles)
+			}
+			if !slices.Equal(remote, tt.wantRemote) {
+				t.Errorf("expandPaths() remote = %v, want %v", remote, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", content: ""},
+		{name: "single YAML document", content: "kind: ClusterPolicy\n", want: []string{"kind: ClusterPolicy"}},
+		{name: "YAML stream skips empty documents", content: "---\nkind: Policy\n---\n\n---\nkind: ClusterPolicy\n", want: []string{"kind: Policy", "kind: ClusterPolicy"}},
+		{name: "single JSON document", content: `{"kind":"Policy"}`, want: []string{`{"kind":"Policy"}`}},
+		{name: "JSON array", content: "\n  [{\"kind\":\"Policy\"},{\"kind\":\"ClusterPolicy\"}]\n", want: []string{`{"kind":"Policy"}`, `{"kind":"ClusterPolicy"}`}},
+		{name: "empty JSON array", content: "[]"},
+		{name: "invalid JSON array", content: `[{"kind":"Policy"},`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := splitDocuments([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitDocuments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]string, 0, len(docs))
+			for _, doc := range docs {
+				got = append(got, string(doc))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitDocuments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkFilesBounds(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.yaml", "sub/b.yaml", "sub/deep/c.yaml", "sub/deep/deeper/d.yaml"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("kind: ClusterPolicy\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		maxDepth   int
+		maxEntries int
+		want       []string
+		wantErr    bool
+	}{
+		{name: "unbounded", maxDepth: -1, maxEntries: 100, want: []string{"a.yaml", "sub/b.yaml", "sub/deep/c.yaml", "sub/deep/deeper/d.yaml"}},
+		{name: "top level only", maxDepth: 1, maxEntries: 100, want: []string{"a.yaml"}},
+		{name: "two levels", maxDepth: 2, maxEntries: 100, want: []string{"a.yaml", "sub/b.yaml"}},
+		{name: "depth bound keeps the walk under the entry limit", maxDepth: 1, maxEntries: 2, want: []string{"a.yaml"}},
+		{name: "too many entries", maxDepth: -1, maxEntries: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := maxWalkEntries
+			t.Cleanup(func() { maxWalkEntries = previous })
+			maxWalkEntries = tt.maxEntries
+
+			files, err := walkFiles(root, policyFileExtensions, nil, tt.maxDepth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("walkFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var want []string
+			for _, f := range tt.want {
+				want = append(want, filepath.Join(root, f))
+			}
+			if !slices.Equal(files, want) {
+				t.Errorf("walkFiles() = %v, want %v", files, want)
+			}
+		})
+	}
+}
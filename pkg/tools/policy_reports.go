@@ -0,0 +1,83 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// policyReportVersions lists the wgpolicyk8s.io versions the tool understands, most preferred
+// first. Kyverno writes v1alpha2 reports, so it is preferred whenever it is served; reports of
+// other versions are converted to v1alpha2 by decodePolicyReport.
+var policyReportVersions = []string{"v1alpha2", "v1beta1"}
+
+// v1alpha2ResultFields lists the fields of v1alpha2 report results.
+var v1alpha2ResultFields = map[string]struct{}{
+	"source": {}, "policy": {}, "rule": {}, "resources": {}, "resourceSelector": {}, "message": {},
+	"result": {}, "scored": {}, "properties": {}, "timestamp": {}, "category": {}, "severity": {},
+}
+
+// v1beta1RenamedResultFields maps the fields of v1beta1 report results that v1alpha2 names
+// differently to their v1alpha2 names.
+var v1beta1RenamedResultFields = map[string]string{
+	"subjects":        "resources",
+	"subjectSelector": "resourceSelector",
+	"description":     "message",
+}
+
+// decodePolicyReport decodes a PolicyReport or ClusterPolicyReport of any wgpolicyk8s.io version
+// into the v1alpha2 type, which both kinds share the fields of. Results of other versions are
+// converted first, renaming the fields v1beta1 names differently. It also returns the sorted
+// names of the result fields that have no v1alpha2 counterpart, which are dropped.
+func decodePolicyReport(u unstructured.Unstructured) (policyreportv1alpha2.PolicyReport, []string, error) {
+	var pr policyreportv1alpha2.PolicyReport
+	object := u.Object
+	dropped := map[string]struct{}{}
+	if results, ok := object["results"].([]any); ok {
+		converted := make([]any, 0, len(results))
+		for _, r := range results {
+			result, ok := r.(map[string]any)
+			if !ok {
+				converted = append(converted, r)
+				continue
+			}
+			converted = append(converted, convertReportResult(result, !strings.HasSuffix(u.GetAPIVersion(), "/v1alpha2"), dropped))
+		}
+		object = maps.Clone(object)
+		object["results"] = converted
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, &pr); err != nil {
+		return pr, nil, err
+	}
+	return pr, slices.Sorted(maps.Keys(dropped)), nil
+}
+
+// convertReportResult returns a copy of a report result holding only v1alpha2 fields. When
+// rename is set, the fields v1beta1 names differently are renamed, unless the result also sets
+// their v1alpha2 name. Any other field is recorded in dropped.
+func convertReportResult(result map[string]any, rename bool, dropped map[string]struct{}) map[string]any {
+	converted := make(map[string]any, len(result))
+	for field, value := range result {
+		if _, ok := v1alpha2ResultFields[field]; ok {
+			converted[field] = value
+		}
+	}
+	for field, value := range result {
+		if _, ok := v1alpha2ResultFields[field]; ok {
+			continue
+		}
+		if target, ok := v1beta1RenamedResultFields[field]; ok && rename {
+			if _, set := converted[target]; !set {
+				converted[target] = value
+				continue
+			}
+		}
+		dropped[field] = struct{}{}
+	}
+	return converted
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDecodePolicyReport(t *testing.T) {
+	pod := map[string]any{"apiVersion": "v1", "kind": "Pod", "namespace": "team-a", "name": "web"}
+	tests := []struct {
+		name        string
+		apiVersion  string
+		result      map[string]any
+		wantMessage string
+		wantSubject bool
+		wantLabels  map[string]string
+		wantDropped []string
+	}{
+		{
+			name:        "v1alpha2",
+			apiVersion:  "wgpolicyk8s.io/v1alpha2",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "severity": "medium", "message": "label app is required", "resources": []any{pod}},
+			wantMessage: "label app is required",
+			wantSubject: true,
+		},
+		{
+			name:        "v1alpha2 with a selector",
+			apiVersion:  "wgpolicyk8s.io/v1alpha2",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "message": "label app is required", "resourceSelector": map[string]any{"matchLabels": map[string]any{"app": "web"}}},
+			wantMessage: "label app is required",
+			wantLabels:  map[string]string{"app": "web"},
+		},
+		{
+			name:        "v1alpha2 fields are not renamed",
+			apiVersion:  "wgpolicyk8s.io/v1alpha2",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "description": "label app is required", "subjects": []any{pod}},
+			wantDropped: []string{"description", "subjects"},
+		},
+		{
+			name:        "v1beta1",
+			apiVersion:  "wgpolicyk8s.io/v1beta1",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "severity": "medium", "description": "label app is required", "subjects": []any{pod}},
+			wantMessage: "label app is required",
+			wantSubject: true,
+		},
+		{
+			name:        "v1beta1 with a selector",
+			apiVersion:  "wgpolicyk8s.io/v1beta1",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "description": "label app is required", "subjectSelector": map[string]any{"matchLabels": map[string]any{"app": "web"}}},
+			wantMessage: "label app is required",
+			wantLabels:  map[string]string{"app": "web"},
+		},
+		{
+			name:        "v1beta1 with v1alpha2 field names",
+			apiVersion:  "wgpolicyk8s.io/v1beta1",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "message": "label app is required", "resources": []any{pod}},
+			wantMessage: "label app is required",
+			wantSubject: true,
+		},
+		{
+			name:        "v1beta1 with unknown fields",
+			apiVersion:  "wgpolicyk8s.io/v1beta1",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "description": "label app is required", "subjects": []any{pod}, "remediation": "add the label", "evidence": map[string]any{}},
+			wantMessage: "label app is required",
+			wantSubject: true,
+			wantDropped: []string{"evidence", "remediation"},
+		},
+		{
+			name:        "unknown version",
+			apiVersion:  "wgpolicyk8s.io/v1beta2",
+			result:      map[string]any{"policy": "require-labels", "result": "fail", "description": "label app is required", "subjects": []any{pod}, "remediation": "add the label"},
+			wantMessage: "label app is required",
+			wantSubject: true,
+			wantDropped: []string{"remediation"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": tt.apiVersion,
+				"kind":       "PolicyReport",
+				"metadata":   map[string]any{"name": "report", "namespace": "team-a"},
+				"summary":    map[string]any{"fail": int64(1)},
+				"results":    []any{tt.result},
+			}}
+			pr, dropped, err := decodePolicyReport(u)
+			if err != nil {
+				t.Fatalf("decodePolicyReport() error = %v", err)
+			}
+			if !slices.Equal(dropped, tt.wantDropped) {
+				t.Errorf("decodePolicyReport() dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+			if pr.Summary.Fail != 1 {
+				t.Errorf("Summary.Fail = %d, want 1", pr.Summary.Fail)
+			}
+			if len(pr.Results) != 1 {
+				t.Fatalf("decodePolicyReport() returned %d results, want 1", len(pr.Results))
+			}
+			result := pr.Results[0]
+			if result.Policy != "require-labels" || result.Result != policyreportv1alpha2.StatusFail {
+				t.Errorf("result = %s/%s, want require-labels/fail", result.Policy, result.Result)
+			}
+			if result.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", result.Message, tt.wantMessage)
+			}
+			wantResources := 0
+			if tt.wantSubject {
+				wantResources = 1
+			}
+			if len(result.Resources) != wantResources {
+				t.Fatalf("Resources = %v, want %d subjects", result.Resources, wantResources)
+			}
+			if tt.wantSubject && result.Resources[0] != (corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "team-a", Name: "web"}) {
+				t.Errorf("Resources[0] = %+v, want Pod team-a/web", result.Resources[0])
+			}
+			if tt.wantLabels != nil && (result.ResourceSelector == nil || result.ResourceSelector.MatchLabels["app"] != tt.wantLabels["app"]) {
+				t.Errorf("ResourceSelector = %v, want labels %v", result.ResourceSelector, tt.wantLabels)
+			}
+			// The listed object must not be modified
+			if _, ok := tt.result["resources"]; !ok && tt.wantSubject {
+				if _, renamed := u.Object["results"].([]any)[0].(map[string]any)["resources"]; renamed {
+					t.Errorf("decodePolicyReport() modified the listed report")
+				}
+			}
+		})
+	}
+}
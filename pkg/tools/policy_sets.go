@@ -0,0 +1,231 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	"sigs.k8s.io/yaml"
+)
+
+// embeddedPolicySets lists the policy sets embedded in the server, which "all" stands for.
+var embeddedPolicySets = []string{"pod-security", "rbac-best-practices", "kubernetes-best-practices"}
+
+// parsePolicySets splits a comma-separated policySets argument into set names, in argument
+// order and without duplicates. "all" stands for every embedded set. Unknown names are rejected
+// rather than widened to every set, so that a typo does not run far more policies than asked.
+func parsePolicySets(arg string) ([]string, error) {
+	var sets []string
+	for _, name := range strings.Split(arg, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		expanded := []string{name}
+		switch {
+		case name == "all":
+			expanded = embeddedPolicySets
+		case name != installedPolicySet && !slices.Contains(embeddedPolicySets, name):
+			valid := append(slices.Clone(embeddedPolicySets), "all", installedPolicySet)
+			return nil, fmt.Errorf("unknown policy set %q: must be one of %s, or a comma-separated list of them", name, strings.Join(valid, ", "))
+		}
+		for _, set := range expanded {
+			if !slices.Contains(sets, set) {
+				sets = append(sets, set)
+			}
+		}
+	}
+	if len(sets) == 0 {
+		return embeddedPolicySets, nil
+	}
+	return sets, nil
+}
+
+// policySetData returns the policies of a single policy set.
+func policySetData(ctx context.Context, set string) ([]byte, error) {
+	switch set {
+	case "pod-security":
+		return podSecurityPolicy, nil
+	case "rbac-best-practices":
+		return rbacBestPracticesPolicy, nil
+	case "kubernetes-best-practices":
+		return kubernetesBestPracticesPolicy, nil
+	case installedPolicySet:
+		data, err := installedPolicies(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load installed policies: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("no Kyverno policies are installed in the cluster")
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown policy set %q", set)
+}
+
+// loadPolicySets combines the policies of the given sets into a single multi-document YAML. A
+// policy contained in several sets, such as the pod security policies that are both embedded and
+// installed, is only evaluated once when the copies have the same normalized spec; copies that
+// differ are all evaluated, and dedupeResults merges their results. The sets containing each
+// policy are returned by policy key, namespace/name for namespaced policies, like the results.
+func loadPolicySets(ctx context.Context, sets []string) ([]byte, map[string][]string, error) {
+	var docs [][]byte
+	specs := map[string][]string{}
+	setsByPolicy := map[string][]string{}
+	for _, set := range sets {
+		data, err := policySetData(ctx, set)
+		if err != nil {
+			return nil, nil, err
+		}
+		setDocs, err := cachedPolicies.documentsOf(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, doc := range setDocs {
+			if !slices.Contains(setsByPolicy[doc.key], set) {
+				setsByPolicy[doc.key] = append(setsByPolicy[doc.key], set)
+			}
+			if slices.Contains(specs[doc.key], doc.spec) {
+				continue
+			}
+			specs[doc.key] = append(specs[doc.key], doc.spec)
+			docs = append(docs, doc.data)
+		}
+	}
+	return bytes.Join(docs, []byte("\n---\n")), setsByPolicy, nil
+}
+
+// Defaults the Kyverno CRDs set on the spec of a policy, on each of its rules and on their
+// validate section. Installed policies carry them even where their manifest did not.
+var (
+	policySpecDefaults     = map[string]any{"validationFailureAction": "Audit", "emitWarning": false, "admission": true, "background": true}
+	policyRuleDefaults     = map[string]any{"skipBackgroundRequests": true}
+	policyValidateDefaults = map[string]any{"allowExistingViolations": true}
+)
+
+// normalizedPolicySpec returns the kind and spec of a policy document as canonical JSON, so that
+// the YAML of an embedded policy and the JSON of the same policy installed in a cluster compare
+// equal. Fields set to their CRD default and empty fields are left out.
+func normalizedPolicySpec(doc []byte) (string, error) {
+	var policy map[string]any
+	if err := yaml.Unmarshal(doc, &policy); err != nil {
+		return "", err
+	}
+	spec, _ := policy["spec"].(map[string]any)
+	if spec != nil {
+		spec = maps.Clone(spec)
+		dropDefaults(spec, policySpecDefaults)
+		if rules, ok := spec["rules"].([]any); ok {
+			normalized := make([]any, 0, len(rules))
+			for _, r := range rules {
+				if rule, ok := r.(map[string]any); ok {
+					rule = maps.Clone(rule)
+					dropDefaults(rule, policyRuleDefaults)
+					if validate, ok := rule["validate"].(map[string]any); ok {
+						validate = maps.Clone(validate)
+						dropDefaults(validate, policyValidateDefaults)
+						rule["validate"] = validate
+					}
+					r = rule
+				}
+				normalized = append(normalized, r)
+			}
+			spec["rules"] = normalized
+		}
+	}
+	// Maps are marshalled with sorted keys, which makes the JSON canonical
+	out, err := json.Marshal(map[string]any{"kind": policy["kind"], "spec": pruneEmpty(spec)})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// dropDefaults deletes the fields of m that are set to their value in defaults.
+func dropDefaults(m map[string]any, defaults map[string]any) {
+	for field, value := range defaults {
+		if v, ok := m[field]; ok && v == value {
+			delete(m, field)
+		}
+	}
+}
+
+// pruneEmpty returns a copy of v without the map entries that are null, empty strings, or empty
+// maps or lists once pruned themselves.
+func pruneEmpty(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		pruned := make(map[string]any, len(v))
+		for key, value := range v {
+			value = pruneEmpty(value)
+			switch value := value.(type) {
+			case nil:
+				continue
+			case string:
+				if value == "" {
+					continue
+				}
+			case map[string]any:
+				if len(value) == 0 {
+					continue
+				}
+			case []any:
+				if len(value) == 0 {
+					continue
+				}
+			}
+			pruned[key] = value
+		}
+		return pruned
+	case []any:
+		pruned := make([]any, 0, len(v))
+		for _, value := range v {
+			pruned = append(pruned, pruneEmpty(value))
+		}
+		return pruned
+	}
+	return v
+}
+
+// resultStatusRank orders policy report result statuses from least to most severe.
+var resultStatusRank = map[policyreportv1alpha2.PolicyResult]int{
+	policyreportv1alpha2.StatusPass:  1,
+	policyreportv1alpha2.StatusSkip:  2,
+	policyreportv1alpha2.StatusWarn:  3,
+	policyreportv1alpha2.StatusError: 4,
+	policyreportv1alpha2.StatusFail:  5,
+}
+
+// dedupeResults keeps one result per policy, rule and resource, which policies contained in
+// several policy sets or policy files produce once per copy. When the copies disagree, the
+// most severe status wins, so a copy that fails is never hidden by one that passes.
+func dedupeResults(results []policyreportv1alpha2.PolicyReportResult) []policyreportv1alpha2.PolicyReportResult {
+	kept := make(map[string]int, len(results))
+	deduped := results[:0]
+	for _, r := range results {
+		f := policyReportResultGroupFields(r)
+		key := strings.Join([]string{r.Policy, r.Rule, f.resource}, "\x00")
+		if i, ok := kept[key]; ok {
+			if resultStatusRank[r.Result] > resultStatusRank[deduped[i].Result] {
+				deduped[i] = r
+			}
+			continue
+		}
+		kept[key] = len(deduped)
+		deduped = append(deduped, r)
+	}
+	return deduped
+}
+
+// withFlaggedBy records on each result the policy sets containing its policy.
+func withFlaggedBy(results []scanResult, setsByPolicy map[string][]string) {
+	for i, r := range results {
+		results[i].FlaggedBy = setsByPolicy[r.Policy]
+	}
+}
@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNormalizedPolicySpec(t *testing.T) {
+	const embedded = `apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: disallow-latest-tag
+  annotations:
+    policies.kyverno.io/category: Best Practices
+spec:
+  rules:
+  - name: validate-image-tag
+    match:
+      any:
+      - resources:
+          kinds:
+          - Pod
+    validate:
+      message: "Using a mutable image tag e.g. 'latest' is not allowed."
+      pattern:
+        spec:
+          containers:
+          - image: "!*:latest"
+`
+	tests := []struct {
+		name      string
+		doc       string
+		wantEqual bool
+	}{
+		{name: "same document", doc: embedded, wantEqual: true},
+		{
+			name:      "installed copy with CRD defaults and server fields",
+			doc:       `{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"disallow-latest-tag","labels":{"app":"kyverno"}},"spec":{"admission":true,"background":true,"emitWarning":false,"validationFailureAction":"Audit","rules":[{"name":"validate-image-tag","skipBackgroundRequests":true,"exclude":{},"match":{"any":[{"resources":{"kinds":["Pod"]}}]},"validate":{"allowExistingViolations":true,"message":"Using a mutable image tag e.g. 'latest' is not allowed.","pattern":{"spec":{"containers":[{"image":"!*:latest"}]}}}}]}}`,
+			wantEqual: true,
+		},
+		{
+			name:      "installed copy enforcing instead of auditing",
+			doc:       `{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"disallow-latest-tag"},"spec":{"validationFailureAction":"Enforce","rules":[{"name":"validate-image-tag","match":{"any":[{"resources":{"kinds":["Pod"]}}]},"validate":{"message":"Using a mutable image tag e.g. 'latest' is not allowed.","pattern":{"spec":{"containers":[{"image":"!*:latest"}]}}}}]}}`,
+			wantEqual: false,
+		},
+		{
+			name:      "background scans disabled",
+			doc:       `{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"disallow-latest-tag"},"spec":{"background":false,"rules":[{"name":"validate-image-tag","match":{"any":[{"resources":{"kinds":["Pod"]}}]},"validate":{"message":"Using a mutable image tag e.g. 'latest' is not allowed.","pattern":{"spec":{"containers":[{"image":"!*:latest"}]}}}}]}}`,
+			wantEqual: false,
+		},
+		{
+			name:      "different pattern",
+			doc:       `{"apiVersion":"kyverno.io/v1","kind":"ClusterPolicy","metadata":{"name":"disallow-latest-tag"},"spec":{"rules":[{"name":"validate-image-tag","match":{"any":[{"resources":{"kinds":["Pod"]}}]},"validate":{"message":"Using a mutable image tag e.g. 'latest' is not allowed.","pattern":{"spec":{"containers":[{"image":"*:*"}]}}}}]}}`,
+			wantEqual: false,
+		},
+		{
+			name: "namespaced policy of the same name",
+			doc: `apiVersion: kyverno.io/v1
+kind: Policy
+metadata:
+  name: disallow-latest-tag
+  namespace: team-a
+spec:
+  rules:
+  - name: validate-image-tag
+    match:
+      any:
+      - resources:
+          kinds:
+          - Pod
+    validate:
+      message: "Using a mutable image tag e.g. 'latest' is not allowed."
+      pattern:
+        spec:
+          containers:
+          - image: "!*:latest"
+`,
+			wantEqual: false,
+		},
+	}
+	want, err := normalizedPolicySpec([]byte(embedded))
+	if err != nil {
+		t.Fatalf("normalizedPolicySpec() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizedPolicySpec([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("normalizedPolicySpec() error = %v", err)
+			}
+			if (got == want) != tt.wantEqual {
+				t.Errorf("normalizedPolicySpec() = %s, equal to the embedded policy = %v, want %v", got, got == want, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestPolicyDocumentKeys(t *testing.T) {
+	data := []byte(`apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: require-labels
+spec:
+  rules: []
+---
+apiVersion: kyverno.io/v1
+kind: Policy
+metadata:
+  name: require-labels
+  namespace: team-a
+spec:
+  rules: []
+`)
+	docs, err := (&policyCache{files: map[string]*cachedPolicyFile{}, documents: map[string][]policyDocument{}}).documentsOf(data)
+	if err != nil {
+		t.Fatalf("documentsOf() error = %v", err)
+	}
+	want := []struct{ name, key string }{{"require-labels", "require-labels"}, {"require-labels", "team-a/require-labels"}}
+	if len(docs) != len(want) {
+		t.Fatalf("documentsOf() returned %d documents, want %d", len(docs), len(want))
+	}
+	for i, w := range want {
+		if docs[i].name != w.name || docs[i].key != w.key {
+			t.Errorf("document %d = %s (%s), want %s (%s)", i, docs[i].name, docs[i].key, w.name, w.key)
+		}
+	}
+}
+
+func TestDedupeResults(t *testing.T) {
+	web := corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "team-a", Name: "web"}
+	api := corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "team-a", Name: "api"}
+	result := func(policy, rule string, resource corev1.ObjectReference, status policyreportv1alpha2.PolicyResult) policyreportv1alpha2.PolicyReportResult {
+		return policyreportv1alpha2.PolicyReportResult{Policy: policy, Rule: rule, Result: status, Resources: []corev1.ObjectReference{resource}}
+	}
+	tests := []struct {
+		name    string
+		results []policyreportv1alpha2.PolicyReportResult
+		want    []policyreportv1alpha2.PolicyResult
+	}{
+		{
+			name:    "distinct results",
+			results: []policyreportv1alpha2.PolicyReportResult{result("p", "r", web, "fail"), result("p", "r", api, "pass"), result("p", "other", web, "pass"), result("team-a/p", "r", web, "warn")},
+			want:    []policyreportv1alpha2.PolicyResult{"fail", "pass", "pass", "warn"},
+		},
+		{
+			name:    "identical copies",
+			results: []policyreportv1alpha2.PolicyReportResult{result("p", "r", web, "fail"), result("p", "r", web, "fail")},
+			want:    []policyreportv1alpha2.PolicyResult{"fail"},
+		},
+		{
+			name:    "failing copy after a passing one",
+			results: []policyreportv1alpha2.PolicyReportResult{result("p", "r", web, "pass"), result("p", "r", api, "pass"), result("p", "r", web, "fail")},
+			want:    []policyreportv1alpha2.PolicyResult{"fail", "pass"},
+		},
+		{
+			name:    "most severe of several copies",
+			results: []policyreportv1alpha2.PolicyReportResult{result("p", "r", web, "skip"), result("p", "r", web, "error"), result("p", "r", web, "warn"), result("p", "r", web, "pass")},
+			want:    []policyreportv1alpha2.PolicyResult{"error"},
+		},
+		{
+			name:    "fail outranks error",
+			results: []policyreportv1alpha2.PolicyReportResult{result("p", "r", web, "error"), result("p", "r", web, "fail")},
+			want:    []policyreportv1alpha2.PolicyResult{"fail"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dedupeResults(tt.results)
+			statuses := make([]policyreportv1alpha2.PolicyResult, 0, len(got))
+			for _, r := range got {
+				statuses = append(statuses, r.Result)
+			}
+			if !slices.Equal(statuses, tt.want) {
+				t.Errorf("dedupeResults() statuses = %v, want %v", statuses, tt.want)
+			}
+		})
+	}
+}
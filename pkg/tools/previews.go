@@ -0,0 +1,151 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"fmt"
+	"sort"
+
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pageResponses returns the engine responses of the resources with results on the current page,
+// and on the last page those of the resources without any result, so that paging through the
+// results returns the previews of every resource together with its results. withResults holds
+// the resourceIdentifier of every resource with results.
+func pageResponses(responses []engineapi.EngineResponse, page []scanResult, withResults map[string]struct{}, last bool) []engineapi.EngineResponse {
+	onPage := make(map[string]struct{}, len(page))
+	for _, r := range page {
+		if len(r.Resources) > 0 {
+			onPage[resourceIdentifier(r.Resources[0])] = struct{}{}
+		}
+	}
+	var selected []engineapi.EngineResponse
+	for _, er := range responses {
+		id := identifierOf(er.Resource)
+		if _, ok := onPage[id]; ok {
+			selected = append(selected, er)
+		} else if _, ok := withResults[id]; !ok && last {
+			selected = append(selected, er)
+		}
+	}
+	return selected
+}
+
+// previewsTruncated is the warning of previews dropped to keep the response under MaxResultBytes.
+func previewsTruncated(what string, kept, total int) string {
+	return fmt.Sprintf("%s truncated to %d of %d because the response exceeded %d bytes; narrow the call down with limit, so that each page covers fewer resources", what, kept, total, MaxResultBytes)
+}
+
+// mutationPreview describes the changes the mutate rules of a policy would make to a resource.
+// Nothing is written to the cluster.
+type mutationPreview struct {
+	Policy          string                         `json:"policy"`
+	Rules           []string                       `json:"rules"`
+	Resource        string                         `json:"resource"`
+	Patches         []jsonpatch.JsonPatchOperation `json:"patches"`
+	PatchedResource map[string]any                 `json:"patchedResource,omitempty"`
+}
+
+// mutationPreviews collects the mutations applied by the engine responses, ordered by resource
+// and policy. Responses whose mutate rules did not change the resource are left out.
+func mutationPreviews(engineResponses []engineapi.EngineResponse) []mutationPreview {
+	var previews []mutationPreview
+	for _, er := range engineResponses {
+		if er.Policy() == nil {
+			continue
+		}
+		var rules []string
+		for _, rule := range er.PolicyResponse.Rules {
+			if rule.RuleType() == engineapi.Mutation && rule.Status() == engineapi.RuleStatusPass {
+				rules = append(rules, rule.Name())
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		patches := er.GetPatches()
+		if len(patches) == 0 {
+			continue
+		}
+		previews = append(previews, mutationPreview{
+			Policy: kyverno.PolicyKey(er.Policy()),
+			Rules:  rules,
+			Resource: resourceIdentifier(corev1.ObjectReference{
+				Kind:      er.Resource.GetKind(),
+				Namespace: er.Resource.GetNamespace(),
+				Name:      er.Resource.GetName(),
+			}),
+			Patches:         patches,
+			PatchedResource: er.PatchedResource.Object,
+		})
+	}
+	sort.SliceStable(previews, func(i, j int) bool {
+		if previews[i].Resource != previews[j].Resource {
+			return previews[i].Resource < previews[j].Resource
+		}
+		return previews[i].Policy < previews[j].Policy
+	})
+	return previews
+}
+
+// generatePreview describes a resource the generate rule of a policy would create for a trigger
+// resource. Nothing is written to the cluster.
+type generatePreview struct {
+	Policy    string         `json:"policy"`
+	Rule      string         `json:"rule"`
+	Trigger   string         `json:"trigger"`
+	Kind      string         `json:"kind"`
+	Namespace string         `json:"namespace,omitempty"`
+	Name      string         `json:"name"`
+	Resource  map[string]any `json:"resource"`
+}
+
+// generatePreviews collects the downstream resources generated by the engine responses, ordered
+// by trigger, policy and rule.
+func generatePreviews(engineResponses []engineapi.EngineResponse) []generatePreview {
+	var previews []generatePreview
+	for _, er := range engineResponses {
+		if er.Policy() == nil {
+			continue
+		}
+		trigger := resourceIdentifier(corev1.ObjectReference{
+			Kind:      er.Resource.GetKind(),
+			Namespace: er.Resource.GetNamespace(),
+			Name:      er.Resource.GetName(),
+		})
+		for _, rule := range er.PolicyResponse.Rules {
+			if rule.RuleType() != engineapi.Generation || rule.Status() != engineapi.RuleStatusPass {
+				continue
+			}
+			for _, generated := range rule.GeneratedResources() {
+				if generated == nil {
+					continue
+				}
+				previews = append(previews, generatePreview{
+					Policy:    kyverno.PolicyKey(er.Policy()),
+					Rule:      rule.Name(),
+					Trigger:   trigger,
+					Kind:      generated.GetKind(),
+					Namespace: generated.GetNamespace(),
+					Name:      generated.GetName(),
+					Resource:  generated.Object,
+				})
+			}
+		}
+	}
+	sort.SliceStable(previews, func(i, j int) bool {
+		a, b := previews[i], previews[j]
+		if a.Trigger != b.Trigger {
+			return a.Trigger < b.Trigger
+		}
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		return a.Rule < b.Rule
+	})
+	return previews
+}
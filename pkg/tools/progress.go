@@ -0,0 +1,95 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+// progressHeartbeatInterval is how often a heartbeat is emitted while a single long-running
+// step, such as a whole-cluster scan, gives no other progress signal.
+const progressHeartbeatInterval = 10 * time.Second
+
+// progressReporter emits MCP progress notifications for a tool call. A nil reporter, returned
+// when the client did not supply a progress token, silently discards all updates.
+type progressReporter struct {
+	srv   *server.MCPServer
+	token mcp.ProgressToken
+
+	mu       sync.Mutex
+	progress float64
+}
+
+// newProgressReporter returns a reporter for the request, or nil if the client did not ask
+// for progress notifications.
+func newProgressReporter(ctx context.Context, request mcp.CallToolRequest) *progressReporter {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	return &progressReporter{srv: srv, token: request.Params.Meta.ProgressToken}
+}
+
+// step advances the progress counter by one and notifies the client. A zero total marks the
+// total amount of work as unknown.
+func (p *progressReporter) step(ctx context.Context, total int, message string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.progress++
+	progress := p.progress
+	p.mu.Unlock()
+	p.send(ctx, progress, total, message)
+}
+
+// heartbeat sends a notification with the given message every progressHeartbeatInterval,
+// without advancing the progress counter, until the returned stop function is called.
+func (p *progressReporter) heartbeat(ctx context.Context, message string) (stop func()) {
+	if p == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressHeartbeatInterval)
+		defer ticker.Stop()
+		start := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+				p.mu.Lock()
+				progress := p.progress
+				p.mu.Unlock()
+				p.send(ctx, progress, 0, message+" ("+time.Since(start).Round(time.Second).String()+" elapsed)")
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (p *progressReporter) send(ctx context.Context, progress float64, total int, message string) {
+	params := map[string]any{
+		"progressToken": p.token,
+		"progress":      progress,
+		"message":       message,
+	}
+	if total > 0 {
+		params["total"] = float64(total)
+	}
+	if err := p.srv.SendNotificationToClient(ctx, "notifications/progress", params); err != nil {
+		klog.V(2).InfoS("failed to send progress notification", "error", err)
+	}
+}
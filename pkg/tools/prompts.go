@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+// Prompts registers prompt templates that walk clients through common workflows built on the
+// tools of this server.
+func Prompts(s *server.MCPServer) {
+	klog.InfoS("Registering prompt: cluster_compliance_audit")
+	s.AddPrompt(mcp.NewPrompt("cluster_compliance_audit",
+		mcp.WithPromptDescription("Audit the cluster against the Kyverno policy sets and summarize the findings"),
+		mcp.WithArgument("policySets", mcp.ArgumentDescription("Policy set to audit against: pod-security, rbac-best-practices, kubernetes-best-practices or all (default: all)")),
+	), func(_ context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		policySets := promptArgument(request, "policySets", "all")
+		text := fmt.Sprintf(`Audit the compliance of the current Kubernetes cluster with the %q Kyverno policy sets.
+
+1. Call apply_policies with policySets=%q, namespace="all" and summaryOnly=true to get the result counts per policy and namespace.
+2. For the policies and namespaces with the most failures, call apply_policies again with the same policySets, groupBy="policy" and minSeverity="medium" to see the individual findings.
+3. Call show_violations with namespace="all" and summary=true to compare with the policy reports of the policies installed in the cluster.
+
+Summarize the overall compliance, the most violated policies, the most affected namespaces, and the remediations with the highest impact. Mention any warnings returned by the tools.`, policySets, policySets)
+		return mcp.NewGetPromptResult("Cluster compliance audit", []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+		}), nil
+	})
+
+	klog.InfoS("Registering prompt: triage_violations")
+	s.AddPrompt(mcp.NewPrompt("triage_violations",
+		mcp.WithPromptDescription("Triage the policy violations of a namespace, most severe first"),
+		mcp.WithArgument("namespace", mcp.ArgumentDescription("Namespace whose violations to triage"), mcp.RequiredArgument()),
+	), func(_ context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		namespace := promptArgument(request, "namespace", "")
+		if namespace == "" {
+			return nil, fmt.Errorf("namespace is required")
+		}
+		text := fmt.Sprintf(`Triage the Kyverno policy violations in the %q namespace.
+
+1. Call show_violations with namespace=%q, groupBy="resource" and sortBy="severity" to list the violations per workload.
+2. If there are no policy reports, call apply_policies with namespace=%q and groupBy="resource" to scan the namespace directly.
+
+For each affected workload, explain which policies it violates and why, ordered by severity, and propose the concrete manifest changes that would fix each violation. Call out violations that are likely to be intentional and better handled with a PolicyException.`, namespace, namespace, namespace)
+		return mcp.NewGetPromptResult("Triage violations in "+namespace, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+		}), nil
+	})
+
+	klog.InfoS("Registering prompt: author_policy")
+	s.AddPrompt(mcp.NewPrompt("author_policy",
+		mcp.WithPromptDescription("Write a Kyverno policy for a requirement and test it against sample resources"),
+		mcp.WithArgument("requirement", mcp.ArgumentDescription("What the policy should enforce, e.g. \"all Deployments must set resource limits\""), mcp.RequiredArgument()),
+	), func(_ context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		requirement := promptArgument(request, "requirement", "")
+		if requirement == "" {
+			return nil, fmt.Errorf("requirement is required")
+		}
+		text := fmt.Sprintf(`Write a Kyverno ClusterPolicy that enforces the following requirement: %s
+
+1. Draft the policy in Audit mode, with the policies.kyverno.io/title, category, severity and description annotations, and save it to a file on the server.
+2. Write one resource manifest that satisfies the requirement and one that violates it.
+3. Call apply_policies with cluster=false, policyPaths set to the policy file, resources set to the sample manifests and includePassing=true, and check that only the violating manifest fails.
+4. Call apply_policies with policyPaths set to the policy file and namespace="all" to preview the impact on the current cluster before the policy is installed.
+
+Iterate on the policy until the results match the intent, then show the final policy and summarize its impact.`, requirement)
+		return mcp.NewGetPromptResult("Author a policy", []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+		}), nil
+	})
+}
+
+// promptArgument returns the trimmed value of a prompt argument, or def if it is not set.
+func promptArgument(request mcp.GetPromptRequest, name, def string) string {
+	if v := strings.TrimSpace(request.Params.Arguments[name]); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,99 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// forbiddenPattern matches the message of a request denied by RBAC, e.g. `User "alice" cannot
+// list resource "pods" in API group "" in the namespace "default"`. The Kyverno CLI wraps API
+// errors as text, so the message is parsed rather than the status details.
+var forbiddenPattern = regexp.MustCompile(`User "([^"]*)" cannot ([a-z]+) resource "([^"]+)"(?: in API group "([^"]*)")?(?: in the namespace "([^"]*)")?`)
+
+// missingPermission describes the permission a request denied by RBAC lacked.
+type missingPermission struct {
+	User     string `json:"user"`
+	Verb     string `json:"verb"`
+	Resource string `json:"resource"`
+	Group    string `json:"group"`
+	// Namespace is empty for cluster-scoped requests, which need a ClusterRole.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// parseForbidden returns the permission lacked by a request denied by RBAC, when the message of
+// err reports one.
+func parseForbidden(err error) (missingPermission, bool) {
+	m := forbiddenPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return missingPermission{}, false
+	}
+	return missingPermission{User: m[1], Verb: m[2], Resource: m[3], Group: m[4], Namespace: m[5]}, true
+}
+
+// message explains the denial in a single sentence.
+func (p missingPermission) message() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	scope := "cluster-wide"
+	if p.Namespace != "" {
+		scope = fmt.Sprintf("in namespace %q", p.Namespace)
+	}
+	return fmt.Sprintf("%s is not allowed to %s %s %s", p.User, p.Verb, resource, scope)
+}
+
+// manifest returns a Role and RoleBinding, or a ClusterRole and ClusterRoleBinding for
+// cluster-scoped requests, granting the user the missing permission and nothing else.
+func (p missingPermission) manifest() (string, error) {
+	name := "kyverno-mcp-" + strings.NewReplacer("/", "-", ".", "-", ":", "-").Replace(p.Verb+"-"+p.Resource)
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{p.Group}, Resources: []string{p.Resource}, Verbs: []string{p.Verb}}}
+
+	subject := rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: p.User}
+	if parts := strings.Split(p.User, ":"); len(parts) == 4 && parts[0] == "system" && parts[1] == "serviceaccount" {
+		subject = rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: parts[2], Name: parts[3]}
+	}
+
+	var role, binding any
+	if p.Namespace == "" {
+		role = rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules:      rules,
+		}
+		binding = rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects:   []rbacv1.Subject{subject},
+		}
+	} else {
+		role = rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: p.Namespace},
+			Rules:      rules,
+		}
+		binding = rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: p.Namespace},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+			Subjects:   []rbacv1.Subject{subject},
+		}
+	}
+
+	docs := make([]string, 0, 2)
+	for _, obj := range []any{role, binding} {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
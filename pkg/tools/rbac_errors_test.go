@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseForbidden(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		want        missingPermission
+		wantOK      bool
+		wantMessage string
+	}{
+		{
+			name:        "namespaced core resource",
+			err:         errors.New(`pods is forbidden: User "alice" cannot list resource "pods" in API group "" in the namespace "default"`),
+			want:        missingPermission{User: "alice", Verb: "list", Resource: "pods", Namespace: "default"},
+			wantOK:      true,
+			wantMessage: `alice is not allowed to list pods in namespace "default"`,
+		},
+		{
+			name:        "cluster-scoped resource of a group",
+			err:         errors.New(`clusterpolicies.kyverno.io is forbidden: User "bob" cannot get resource "clusterpolicies" in API group "kyverno.io" at the cluster scope`),
+			want:        missingPermission{User: "bob", Verb: "get", Resource: "clusterpolicies", Group: "kyverno.io"},
+			wantOK:      true,
+			wantMessage: `bob is not allowed to get clusterpolicies.kyverno.io cluster-wide`,
+		},
+		{
+			name:        "service account in a wrapped error",
+			err:         fmt.Errorf("failed to load resources: %w", errors.New(`policyreports.wgpolicyk8s.io is forbidden: User "system:serviceaccount:tools:scanner" cannot watch resource "policyreports" in API group "wgpolicyk8s.io" in the namespace "team-a"`)),
+			want:        missingPermission{User: "system:serviceaccount:tools:scanner", Verb: "watch", Resource: "policyreports", Group: "wgpolicyk8s.io", Namespace: "team-a"},
+			wantOK:      true,
+			wantMessage: `system:serviceaccount:tools:scanner is not allowed to watch policyreports.wgpolicyk8s.io in namespace "team-a"`,
+		},
+		{
+			name:        "subresource without API group",
+			err:         errors.New(`User "carol" cannot create resource "pods/exec"`),
+			want:        missingPermission{User: "carol", Verb: "create", Resource: "pods/exec"},
+			wantOK:      true,
+			wantMessage: `carol is not allowed to create pods/exec cluster-wide`,
+		},
+		{
+			name: "other error",
+			err:  errors.New(`the server could not find the requested resource`),
+		},
+		{
+			name: "unauthenticated",
+			err:  errors.New(`Unauthorized`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseForbidden(tt.err)
+			if ok != tt.wantOK {
+				t.Fatalf("parseForbidden() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("parseForbidden() = %+v, want %+v", got, tt.want)
+			}
+			if ok && got.message() != tt.wantMessage {
+				t.Errorf("message() = %q, want %q", got.message(), tt.wantMessage)
+			}
+		})
+	}
+}
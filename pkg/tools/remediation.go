@@ -0,0 +1,62 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"strings"
+
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+)
+
+const (
+	// annotationPolicyRemediation optionally carries explicit remediation guidance for a policy.
+	annotationPolicyRemediation = "policies.kyverno.io/remediation"
+	// annotationPolicyDescription describes what a policy enforces and why.
+	annotationPolicyDescription = "policies.kyverno.io/description"
+)
+
+// scanResult is a policy report result enriched with guidance on how to fix it.
+type scanResult struct {
+	policyreportv1alpha2.PolicyReportResult `json:",inline"`
+	Remediation                             string `json:"remediation,omitempty"`
+	// FlaggedBy lists the requested policy sets containing the policy, when several were requested.
+	FlaggedBy []string `json:"flaggedBy,omitempty"`
+}
+
+// policyRemediations maps each policy evaluated in the engine responses to its remediation
+// hint, taken from the remediation annotation or, failing that, the policy description.
+func policyRemediations(engineResponses []engineapi.EngineResponse) map[string]string {
+	remediations := map[string]string{}
+	for _, er := range engineResponses {
+		if er.Policy() == nil {
+			continue
+		}
+		name := kyverno.PolicyKey(er.Policy())
+		if _, ok := remediations[name]; ok {
+			continue
+		}
+		annotations := er.Policy().GetAnnotations()
+		hint := annotations[annotationPolicyRemediation]
+		if hint == "" {
+			hint = annotations[annotationPolicyDescription]
+		}
+		remediations[name] = strings.Join(strings.Fields(hint), " ")
+	}
+	return remediations
+}
+
+// withRemediations attaches the remediation hint of each result's policy. Passing results
+// need no fixing and are returned without a hint.
+func withRemediations(results []policyreportv1alpha2.PolicyReportResult, remediations map[string]string) []scanResult {
+	out := make([]scanResult, 0, len(results))
+	for _, r := range results {
+		sr := scanResult{PolicyReportResult: r}
+		if r.Result != policyreportv1alpha2.StatusPass && r.Result != policyreportv1alpha2.StatusSkip {
+			sr.Remediation = remediations[r.Policy]
+		}
+		out = append(out, sr)
+	}
+	return out
+}
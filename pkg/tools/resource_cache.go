@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	"github.com/mark3labs/mcp-go/server"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// ResourceCache makes the cluster scans of a session read the resources matched by the policies
+// from informers kept by the session, instead of listing the cluster on every apply_policies
+// call. It is set from the --resource-cache flag.
+var ResourceCache bool
+
+const (
+	// maxCachedInformersPerSession bounds the number of resources and namespaces a session keeps
+	// informers for; further ones are listed on every call.
+	maxCachedInformersPerSession = 100
+	// resourceCacheIdle is how long an informer is kept without being read, so that the
+	// resources of scans that are not repeated are not held for the lifetime of the session.
+	resourceCacheIdle = 30 * time.Minute
+	// resourceCacheSyncTimeout bounds how long a call waits for a new informer to list the
+	// resources before listing them itself.
+	resourceCacheSyncTimeout = 2 * time.Minute
+)
+
+// informerKey identifies the informer of a resource of a session. Clients are compared by
+// identity, so that switching contexts or impersonated users never serves the objects seen by
+// other credentials.
+type informerKey struct {
+	session   string
+	clients   *common.ClusterClients
+	resource  schema.GroupVersionResource
+	namespace string
+	selector  string
+}
+
+// cachedInformer is an informer kept by a session, with the last error of its list and watch
+// requests.
+type cachedInformer struct {
+	informer cache.SharedIndexInformer
+	stop     context.CancelFunc
+	used     time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *cachedInformer) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// waitForSync waits until the informer has listed the resources. It fails as soon as the
+// informer fails to list them, e.g. when the resources cannot be listed with the credentials of
+// the session.
+func (c *cachedInformer) waitForSync(ctx context.Context) error {
+	return wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, resourceCacheSyncTimeout, true, func(context.Context) (bool, error) {
+		if c.informer.HasSynced() {
+			return true, nil
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return false, c.err
+	})
+}
+
+// resourceCache holds the informers of every session.
+type resourceCache struct {
+	mu        sync.Mutex
+	informers map[informerKey]*cachedInformer
+}
+
+var resourceCaches = &resourceCache{informers: map[informerKey]*cachedInformer{}}
+
+// informer returns the informer of key, started by start when the session has none yet. Idle
+// informers are stopped first. It returns nil when the session already keeps
+// maxCachedInformersPerSession informers.
+func (c *resourceCache) informer(key informerKey, start func() *cachedInformer) *cachedInformer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for k, inf := range c.informers {
+		if now.Sub(inf.used) > resourceCacheIdle {
+			inf.stop()
+			delete(c.informers, k)
+			continue
+		}
+		if k.session == key.session {
+			count++
+		}
+	}
+	if inf, ok := c.informers[key]; ok {
+		inf.used = now
+		return inf
+	}
+	if count >= maxCachedInformersPerSession {
+		return nil
+	}
+	inf := start()
+	inf.used = now
+	c.informers[key] = inf
+	return inf
+}
+
+// drop stops inf and forgets it, unless key has been given another informer meanwhile.
+func (c *resourceCache) drop(key informerKey, inf *cachedInformer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	inf.stop()
+	if c.informers[key] == inf {
+		delete(c.informers, key)
+	}
+}
+
+// stop stops the informers of a session.
+func (c *resourceCache) stop(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, inf := range c.informers {
+		if k.session == sessionID {
+			inf.stop()
+			delete(c.informers, k)
+		}
+	}
+}
+
+// StopResourceCaches stops the informers of a session. It is registered as an
+// OnUnregisterSession hook.
+func StopResourceCaches(_ context.Context, session server.ClientSession) {
+	resourceCaches.stop(session.SessionID())
+}
+
+// resourceCacheActive reports whether the cluster resources listed by the call of ctx are read
+// from the informers of its session.
+func resourceCacheActive(ctx context.Context) bool {
+	return ResourceCache && server.ClientSessionFromContext(ctx) != nil
+}
+
+// listCached lists the objects of resource matching selector in namespace, or in the whole
+// cluster when namespace is empty. When the resource cache is active, they are read from an
+// informer of the session, started by the first call and kept up to date by a watch, so that
+// repeated scans do not list the cluster again. The objects are copies, sorted by resourceKey.
+func listCached(ctx context.Context, clients *common.ClusterClients, resource schema.GroupVersionResource, namespace string, selector resourceSelector) ([]unstructured.Unstructured, error) {
+	opts := selector.listOptions()
+	if !resourceCacheActive(ctx) {
+		return listPagedWith(ctx, clients.Dynamic.Resource(resource).Namespace(namespace), opts)
+	}
+
+	key := informerKey{
+		session:   server.ClientSessionFromContext(ctx).SessionID(),
+		clients:   clients,
+		resource:  resource,
+		namespace: namespace,
+		selector:  opts.LabelSelector + "\x00" + opts.FieldSelector,
+	}
+	inf := resourceCaches.informer(key, func() *cachedInformer {
+		return startInformer(clients, resource, namespace, opts)
+	})
+	if inf == nil {
+		return listPagedWith(ctx, clients.Dynamic.Resource(resource).Namespace(namespace), opts)
+	}
+	if err := inf.waitForSync(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		// The informer is started again by the next call; this one reports the error of
+		// listing the resources, if any
+		klog.V(2).InfoS("cannot cache resources", "resource", resource, "namespace", namespace, "error", err)
+		resourceCaches.drop(key, inf)
+		return listPagedWith(ctx, clients.Dynamic.Resource(resource).Namespace(namespace), opts)
+	}
+
+	objs := inf.informer.GetStore().List()
+	items := make([]unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			items = append(items, *u.DeepCopy())
+		}
+	}
+	slices.SortFunc(items, func(a, b unstructured.Unstructured) int {
+		return strings.Compare(resourceKey(a), resourceKey(b))
+	})
+	return items, nil
+}
+
+// startInformer starts an informer of the objects of resource matching the selectors of opts in
+// namespace. It runs until it is stopped, independently of the call that started it.
+func startInformer(clients *common.ClusterClients, resource schema.GroupVersionResource, namespace string, opts metav1.ListOptions) *cachedInformer {
+	informer := dynamicinformer.NewFilteredDynamicInformer(clients.Dynamic, resource, namespace, 0, cache.Indexers{}, func(o *metav1.ListOptions) {
+		o.LabelSelector = opts.LabelSelector
+		o.FieldSelector = opts.FieldSelector
+	}).Informer()
+	ctx, cancel := context.WithCancel(context.Background())
+	inf := &cachedInformer{informer: informer, stop: cancel}
+	_ = informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		klog.V(2).InfoS("resource cache watch failed", "resource", resource, "namespace", namespace, "error", err)
+		inf.setErr(err)
+	})
+	go informer.Run(ctx.Done())
+	return inf
+}
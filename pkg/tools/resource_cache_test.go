@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// testSession is a client session of tool calls made by tests.
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func newTestSession(id string) *testSession {
+	return &testSession{id: id, notifications: make(chan mcp.JSONRPCNotification, 100)}
+}
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) SessionID() string { return s.id }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func TestListCached(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	configMap := func(name string) runtime.Object {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": name, "namespace": "team-a"},
+		}}
+	}
+	tests := []struct {
+		name      string
+		enabled   bool
+		session   bool
+		forbidden bool
+		wantLists int
+		wantErr   bool
+	}{
+		{name: "cache disabled", session: true, wantLists: 2},
+		{name: "no session", enabled: true, wantLists: 2},
+		{name: "cache enabled", enabled: true, session: true, wantLists: 1},
+		{name: "forbidden", enabled: true, session: true, forbidden: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled := ResourceCache
+			ResourceCache = tt.enabled
+			t.Cleanup(func() { ResourceCache = enabled })
+
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ConfigMapList"}, configMap("web"), configMap("api"))
+			var lists atomic.Int32
+			client.PrependReactor("list", "configmaps", func(clienttesting.Action) (bool, runtime.Object, error) {
+				lists.Add(1)
+				if tt.forbidden {
+					return true, nil, apierrors.NewForbidden(gvr.GroupResource(), "", nil)
+				}
+				return false, nil, nil
+			})
+			clients := &common.ClusterClients{Dynamic: client}
+
+			ctx := context.Background()
+			if tt.session {
+				session := newTestSession(tt.name)
+				ctx = server.NewMCPServer("test", "1.0.0").WithContext(ctx, session)
+				t.Cleanup(func() { StopResourceCaches(ctx, session) })
+			}
+			for i := range 2 {
+				items, err := listCached(ctx, clients, gvr, "team-a", resourceSelector{})
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("call %d: listCached() error = %v, wantErr %v", i, err, tt.wantErr)
+				}
+				if tt.wantErr {
+					continue
+				}
+				if len(items) != 2 || items[0].GetName() != "api" || items[1].GetName() != "web" {
+					t.Errorf("call %d: listCached() returned %d items, want api and web", i, len(items))
+				}
+			}
+			// Informers failing to list are dropped, with the calls listing the resources themselves
+			if tt.forbidden {
+				if n := len(resourceCaches.informers); n != 0 {
+					t.Errorf("resource cache kept %d informers, want none", n)
+				}
+				return
+			}
+			if n := int(lists.Load()); n != tt.wantLists {
+				t.Errorf("listCached() made %d list calls, want %d", n, tt.wantLists)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/policy"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/processor"
+	"github.com/kyverno/kyverno/pkg/autogen"
+	kubeutils "github.com/kyverno/kyverno/pkg/utils/kube"
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// samplingSummary describes a sampled scan and extrapolates its result counts to every resource
+// of the sampled kinds and namespaces.
+type samplingSummary struct {
+	// PerGroup is the maximum number of resources evaluated per kind and namespace.
+	PerGroup int `json:"perGroup"`
+	// Seed selects the sampled resources; the same seed samples the same resources of an
+	// unchanged cluster.
+	Seed             int `json:"seed"`
+	ResourcesSampled int `json:"resourcesSampled"`
+	ResourcesTotal   int `json:"resourcesTotal"`
+	// Estimated extrapolates the result counts of each kind and namespace from its sample to all
+	// of its resources.
+	Estimated resultSummary `json:"estimated"`
+
+	// groups holds the number of resources and sampled resources per kind and namespace.
+	groups map[string]sampleGroup
+}
+
+// sampleGroup counts the resources of a kind in a namespace and how many of them were sampled.
+type sampleGroup struct {
+	total, sampled int
+}
+
+func sampleGroupKey(kind, namespace string) string {
+	return kind + "/" + namespace
+}
+
+// estimate returns a copy of the summary whose Estimated counts extrapolate results, the results
+// of the sampled resources, to all resources.
+func (s samplingSummary) estimate(results []policyreportv1alpha2.PolicyReportResult) *samplingSummary {
+	var pass, fail, warn, errs, skip float64
+	for _, r := range results {
+		f := policyReportResultGroupFields(r)
+		weight := 1.0
+		if g, ok := s.groups[sampleGroupKey(f.kind, f.namespace)]; ok && g.sampled > 0 {
+			weight = float64(g.total) / float64(g.sampled)
+		}
+		switch r.Result {
+		case policyreportv1alpha2.StatusPass:
+			pass += weight
+		case policyreportv1alpha2.StatusFail:
+			fail += weight
+		case policyreportv1alpha2.StatusWarn:
+			warn += weight
+		case policyreportv1alpha2.StatusError:
+			errs += weight
+		case policyreportv1alpha2.StatusSkip:
+			skip += weight
+		}
+	}
+	s.Estimated = resultSummary{
+		Pass:  int(math.Round(pass)),
+		Fail:  int(math.Round(fail)),
+		Warn:  int(math.Round(warn)),
+		Error: int(math.Round(errs)),
+		Skip:  int(math.Round(skip)),
+	}
+	return &s
+}
+
+// sampleScan evaluates at most opts.sample resources of every kind matched by the policies in
+// every namespace of the scan. Resources are picked by a shuffle seeded with opts.sampleSeed and
+// the kind and namespace, so repeated calls sample the same resources. Sampled resources are
+// evaluated offline, like the changed resources of incremental scans.
+func sampleScan(ctx context.Context, opts applyOptions, policyPaths []string, userInfoPath, contextPath string) (*kyverno.ApplyResult, []string, *samplingSummary, error) {
+	sampled, warnings, summary, err := sampleResources(ctx, opts, policyPaths)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	clientLog(ctx, mcp.LoggingLevelInfo, "sampled scan", "sampled", summary.ResourcesSampled, "total", summary.ResourcesTotal)
+
+	if len(sampled) == 0 {
+		return &kyverno.ApplyResult{ResultCounts: &processor.ResultCounts{}}, warnings, summary, nil
+	}
+	result, err := scanResources(ctx, opts, policyPaths, userInfoPath, contextPath, sampled)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return result, warnings, summary, nil
+}
+
+// sampleResources picks at most opts.sample cluster resources of every kind matched by the
+// policies in every namespace of opts, with warnings about kinds the cluster does not serve.
+func sampleResources(ctx context.Context, opts applyOptions, policyPaths []string) ([]*unstructured.Unstructured, []string, *samplingSummary, error) {
+	kinds, warnings, err := matchedKinds(ctx, policyPaths)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	current, err := listResourcesOfKinds(ctx, opts.namespaces, kinds, opts.selector)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list resources to sample: %w", err)
+	}
+	sampled, summary := pickSample(current, opts.sample, opts.sampleSeed)
+	return sampled, warnings, summary, nil
+}
+
+// pickSample picks at most perGroup of the resources of every kind and namespace of current,
+// keyed by resourceKey, by a shuffle seeded with seed and the kind and namespace.
+func pickSample(current map[string]*unstructured.Unstructured, perGroup, seed int) ([]*unstructured.Unstructured, *samplingSummary) {
+	byGroup := map[string][]*unstructured.Unstructured{}
+	for _, u := range current {
+		key := sampleGroupKey(u.GetKind(), u.GetNamespace())
+		byGroup[key] = append(byGroup[key], u)
+	}
+	summary := &samplingSummary{PerGroup: perGroup, Seed: seed, groups: map[string]sampleGroup{}}
+	var sampled []*unstructured.Unstructured
+	for key, resources := range byGroup {
+		// Listing order is not stable, so resources are ordered before the seeded shuffle
+		sort.Slice(resources, func(i, j int) bool { return resourceKey(*resources[i]) < resourceKey(*resources[j]) })
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		rng := rand.New(rand.NewPCG(uint64(seed), h.Sum64()))
+		rng.Shuffle(len(resources), func(i, j int) { resources[i], resources[j] = resources[j], resources[i] })
+
+		n := min(perGroup, len(resources))
+		sampled = append(sampled, resources[:n]...)
+		summary.groups[key] = sampleGroup{total: len(resources), sampled: n}
+		summary.ResourcesTotal += len(resources)
+		summary.ResourcesSampled += n
+	}
+	return sampled, summary
+}
+
+// matchedKinds resolves the kinds matched by the rules of the Kyverno policies in policyPaths to
+// the kinds served by the cluster. Kinds matched by other policy types, such as
+// ValidatingAdmissionPolicies, are not covered.
+func matchedKinds(ctx context.Context, policyPaths []string) (map[schema.GroupVersionKind]struct{}, []string, error) {
+	loaded, err := policy.Load(nil, "", policyPaths...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	var selectors []string
+	for _, p := range loaded.Policies {
+		for _, rule := range autogen.Default.ComputeRules(p, "") {
+			selectors = append(selectors, ruleKinds(rule)...)
+		}
+	}
+	slices.Sort(selectors)
+	selectors = slices.Compact(selectors)
+
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	lists, err := clients.Discovery.ServerPreferredResources()
+	// Groups that failed discovery, such as unavailable aggregated APIs, are left out
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, nil, err
+	}
+
+	kinds := map[schema.GroupVersionKind]struct{}{}
+	var warnings []string
+	for _, selector := range selectors {
+		group, version, kind, subresource := kubeutils.ParseKindSelector(selector)
+		if subresource != "" {
+			continue
+		}
+		found := false
+		for _, list := range lists {
+			gv, err := schema.ParseGroupVersion(list.GroupVersion)
+			if err != nil || (group != "*" && group != gv.Group) || (version != "*" && version != gv.Version) {
+				continue
+			}
+			for _, r := range list.APIResources {
+				if strings.Contains(r.Name, "/") || (kind != "*" && kind != r.Kind) || !slices.Contains(r.Verbs, "list") {
+					continue
+				}
+				kinds[gv.WithKind(r.Kind)] = struct{}{}
+				found = true
+			}
+		}
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("kind %s matched by the policies is not served by the cluster", selector))
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, warnings, errors.New("the policies do not match any kind served by the cluster")
+	}
+	return kinds, warnings, nil
+}
+
+// ruleKinds returns the kind selectors a rule matches.
+func ruleKinds(rule kyvernov1.Rule) []string {
+	kinds := append([]string{}, rule.MatchResources.Kinds...)
+	for _, filter := range rule.MatchResources.Any {
+		kinds = append(kinds, filter.Kinds...)
+	}
+	for _, filter := range rule.MatchResources.All {
+		kinds = append(kinds, filter.Kinds...)
+	}
+	return kinds
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPickSample(t *testing.T) {
+	resources := func(counts map[string]int) map[string]*unstructured.Unstructured {
+		current := map[string]*unstructured.Unstructured{}
+		for namespace, n := range counts {
+			for i := range n {
+				u := &unstructured.Unstructured{}
+				u.SetAPIVersion("v1")
+				u.SetKind("Pod")
+				u.SetNamespace(namespace)
+				u.SetName(fmt.Sprintf("pod-%d", i))
+				current[resourceKey(*u)] = u
+			}
+		}
+		return current
+	}
+	tests := []struct {
+		name        string
+		counts      map[string]int
+		perGroup    int
+		wantSampled int
+		wantTotal   int
+	}{
+		{name: "no resources", perGroup: 5},
+		{name: "groups smaller than the sample", counts: map[string]int{"team-a": 3, "team-b": 2}, perGroup: 5, wantSampled: 5, wantTotal: 5},
+		{name: "groups larger than the sample", counts: map[string]int{"team-a": 30, "team-b": 20}, perGroup: 5, wantSampled: 10, wantTotal: 50},
+		{name: "mixed groups", counts: map[string]int{"team-a": 30, "team-b": 2}, perGroup: 5, wantSampled: 7, wantTotal: 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampled, summary := pickSample(resources(tt.counts), tt.perGroup, 7)
+			if len(sampled) != tt.wantSampled || summary.ResourcesSampled != tt.wantSampled || summary.ResourcesTotal != tt.wantTotal {
+				t.Errorf("pickSample() sampled %d (summary %d of %d), want %d of %d", len(sampled), summary.ResourcesSampled, summary.ResourcesTotal, tt.wantSampled, tt.wantTotal)
+			}
+			for namespace, n := range tt.counts {
+				g := summary.groups[sampleGroupKey("Pod", namespace)]
+				if g.total != n || g.sampled != min(n, tt.perGroup) {
+					t.Errorf("group %s = %+v, want %d of %d", namespace, g, min(n, tt.perGroup), n)
+				}
+			}
+
+			// The same seed samples the same resources of an unchanged cluster, whatever the
+			// listing order
+			again, _ := pickSample(resources(tt.counts), tt.perGroup, 7)
+			if !slices.Equal(sampledKeys(sampled), sampledKeys(again)) {
+				t.Errorf("pickSample() sampled %v, then %v with the same seed", sampledKeys(sampled), sampledKeys(again))
+			}
+		})
+	}
+}
+
+func sampledKeys(resources []*unstructured.Unstructured) []string {
+	keys := make([]string, 0, len(resources))
+	for _, u := range resources {
+		keys = append(keys, resourceKey(*u))
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestSamplingEstimate(t *testing.T) {
+	result := func(namespace string, status policyreportv1alpha2.PolicyResult) policyreportv1alpha2.PolicyReportResult {
+		return policyreportv1alpha2.PolicyReportResult{Policy: "p", Result: status, Resources: []corev1.ObjectReference{{Kind: "Pod", Namespace: namespace, Name: "web"}}}
+	}
+	summary := samplingSummary{groups: map[string]sampleGroup{
+		sampleGroupKey("Pod", "team-a"): {total: 30, sampled: 3},
+		sampleGroupKey("Pod", "team-b"): {total: 2, sampled: 2},
+	}}
+	tests := []struct {
+		name    string
+		results []policyreportv1alpha2.PolicyReportResult
+		want    resultSummary
+	}{
+		{name: "no results"},
+		{name: "fully sampled group", results: []policyreportv1alpha2.PolicyReportResult{result("team-b", "pass"), result("team-b", "fail")}, want: resultSummary{Pass: 1, Fail: 1}},
+		{name: "partly sampled group", results: []policyreportv1alpha2.PolicyReportResult{result("team-a", "pass"), result("team-a", "pass"), result("team-a", "fail")}, want: resultSummary{Pass: 20, Fail: 10}},
+		{name: "mixed groups", results: []policyreportv1alpha2.PolicyReportResult{result("team-a", "warn"), result("team-b", "warn"), result("team-a", "skip")}, want: resultSummary{Warn: 11, Skip: 10}},
+		{name: "unsampled group", results: []policyreportv1alpha2.PolicyReportResult{result("team-c", "error")}, want: resultSummary{Error: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summary.estimate(tt.results)
+			if got.Estimated != tt.want {
+				t.Errorf("estimate() = %+v, want %+v", got.Estimated, tt.want)
+			}
+		})
+	}
+}
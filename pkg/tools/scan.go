@@ -0,0 +1,557 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+	"github.com/nirmata/kyverno-mcp/pkg/tracing"
+
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/commands/apply"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/policy/annotations"
+	"github.com/kyverno/kyverno/cmd/cli/kubectl-kyverno/processor"
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// defaultScanConcurrency bounds the number of namespaces or resource paths scanned in parallel
+// when the caller does not specify a concurrency.
+const defaultScanConcurrency = 4
+
+// MaxScanResources caps the number of resources a single scan evaluates, so that a scan of a
+// very large cluster cannot exhaust the memory of the server. Cluster scans count the resources
+// of each namespace before evaluating it and leave out the namespaces beyond the cap; local
+// manifests are not scanned once it is reached. It is set from the --max-scan-resources flag; 0
+// disables the cap.
+var MaxScanResources = 50000
+
+// errResourceLimit marks the slices of a scan that were not scanned because the scan reached
+// MaxScanResources.
+var errResourceLimit = errors.New("the scan reached the limit of resources per scan")
+
+// scanJob is a slice of a scan run as a separate Kyverno apply invocation.
+type scanJob struct {
+	// name identifies the job in logs, errors and progress messages, e.g. "namespace team-a".
+	name string
+	// configure narrows the shared apply configuration down to the slice covered by the job.
+	configure func(*apply.ApplyCommandConfig)
+	// list optionally lists the resources of the job from the cluster, which are then evaluated
+	// offline rather than fetched by the Kyverno CLI.
+	list func(context.Context) ([]*unstructured.Unstructured, error)
+}
+
+// scanNamespaces runs the Kyverno apply command once per namespace on a bounded worker pool and
+// merges the per-namespace results, together with the failures of individual namespaces.
+// Listing namespaced kinds fails for cluster-scoped ones, and a cluster-wide run of the Kyverno
+// CLI would fetch every namespaced resource too, so when every namespace is scanned the
+// cluster-scoped resources of kinds, such as Namespaces and ClusterRoleBindings, are listed and
+// evaluated offline by a job of their own. When opts has a label or field selector, or the
+// resource cache is active, the resources of every namespace are listed and evaluated offline too.
+//
+// The resources of kinds are counted before each slice is scanned, so that MaxScanResources is
+// enforced before evaluation: slices are scanned in order while they fit, the slice crossing the
+// limit is listed and evaluated offline with the resources that fit, in resourceKey order, and
+// the remaining slices are skipped with errResourceLimit.
+func scanNamespaces(ctx context.Context, config apply.ApplyCommandConfig, opts applyOptions, namespaces []string, kinds map[schema.GroupVersionKind]struct{}) (*kyverno.ApplyResult, []error, error) {
+	namespaced, clusterScoped, err := kindMappings(ctx, kinds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve the kinds matched by the policies: %w", err)
+	}
+
+	jobs := make([]scanJob, 0, len(namespaces)+1)
+	var skipped []error
+	remaining := MaxScanResources
+	add := func(job scanJob, mappings []*meta.RESTMapping, namespace string) {
+		// Kinds only matched by other policy types are not counted, so the limit is enforced
+		// again once the slices are evaluated
+		if MaxScanResources <= 0 || len(mappings) == 0 {
+			jobs = append(jobs, job)
+			return
+		}
+		if remaining == 0 {
+			skipped = append(skipped, fmt.Errorf("%s: %w", job.name, errResourceLimit))
+			return
+		}
+		n, err := countMapped(ctx, mappings, namespace, opts.selector)
+		if err != nil {
+			// The job reports the failure if it cannot list the resources either
+			klog.V(2).InfoS("cannot count resources", "job", job.name, "error", err)
+			jobs = append(jobs, job)
+			return
+		}
+		if n <= remaining {
+			remaining -= n
+			jobs = append(jobs, job)
+			return
+		}
+		limit := remaining
+		remaining = 0
+		jobs = append(jobs, scanJob{
+			name: job.name,
+			list: func(ctx context.Context) ([]*unstructured.Unstructured, error) {
+				resources, err := listMapped(ctx, mappings, namespace, opts.selector)
+				if err != nil {
+					return nil, err
+				}
+				sortResources(resources)
+				return resources[:min(limit, len(resources))], nil
+			},
+		})
+	}
+
+	for _, ns := range namespaces {
+		job := scanJob{
+			name:      "namespace " + ns,
+			configure: func(c *apply.ApplyCommandConfig) { c.Namespace = ns },
+		}
+		// The Kyverno CLI cannot select resources by label or field, nor read them from the
+		// resource cache, so selected or cached resources are listed and evaluated offline
+		if (!opts.selector.empty() || resourceCacheActive(ctx)) && len(namespaced) > 0 {
+			job = scanJob{
+				name: job.name,
+				list: func(ctx context.Context) ([]*unstructured.Unstructured, error) {
+					return listMapped(ctx, namespaced, ns, opts.selector)
+				},
+			}
+		}
+		add(job, namespaced, ns)
+	}
+	if opts.namespaces.All && opts.namespaces.Includes("") && len(clusterScoped) > 0 {
+		add(scanJob{
+			name: "cluster-scoped resources",
+			list: func(ctx context.Context) ([]*unstructured.Unstructured, error) {
+				return listMapped(ctx, clusterScoped, "", opts.selector)
+			},
+		}, clusterScoped, "")
+	}
+
+	result, errs, err := scanParallel(ctx, config, jobs, opts.concurrency, opts.progress)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, append(errs, skipped...), nil
+}
+
+// kindMappings resolves kinds to the resources serving them, split into namespaced and
+// cluster-scoped ones and sorted by resource, so that scans list them in a stable order.
+func kindMappings(ctx context.Context, kinds map[schema.GroupVersionKind]struct{}) ([]*meta.RESTMapping, []*meta.RESTMapping, error) {
+	if len(kinds) == 0 {
+		return nil, nil, nil
+	}
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var namespaced, clusterScoped []*meta.RESTMapping
+	for gvk := range kinds {
+		mapping, err := clients.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			namespaced = append(namespaced, mapping)
+		} else {
+			clusterScoped = append(clusterScoped, mapping)
+		}
+	}
+	byResource := func(a, b *meta.RESTMapping) int {
+		return strings.Compare(a.Resource.String(), b.Resource.String())
+	}
+	slices.SortFunc(namespaced, byResource)
+	slices.SortFunc(clusterScoped, byResource)
+	return namespaced, clusterScoped, nil
+}
+
+// listMapped lists the resources of mappings matching selector in namespace, or in the whole
+// cluster when namespace is empty, which only suits cluster-scoped mappings.
+func listMapped(ctx context.Context, mappings []*meta.RESTMapping, namespace string, selector resourceSelector) ([]*unstructured.Unstructured, error) {
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resources []*unstructured.Unstructured
+	for _, mapping := range mappings {
+		items, err := listCached(ctx, clients, mapping.Resource, namespace, selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+		}
+		for i := range items {
+			resources = append(resources, &items[i])
+		}
+	}
+	return resources, nil
+}
+
+// countMapped counts the resources of mappings matching selector in namespace, or in the whole
+// cluster when namespace is empty. The count is read from the remaining item count of a
+// single-item page when the API server reports it, which it does not for selectors, and
+// otherwise by paging through the resources without keeping them.
+func countMapped(ctx context.Context, mappings []*meta.RESTMapping, namespace string, selector resourceSelector) (int, error) {
+	clients, err := common.Clients(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, mapping := range mappings {
+		var ri dynamic.ResourceInterface = clients.Dynamic.Resource(mapping.Resource)
+		if namespace != "" {
+			ri = clients.Dynamic.Resource(mapping.Resource).Namespace(namespace)
+		}
+		opts := selector.listOptions()
+		opts.Limit = 1
+		list, err := ri.List(ctx, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+		}
+		if remaining := list.GetRemainingItemCount(); remaining != nil || list.GetContinue() == "" {
+			count += len(list.Items)
+			if remaining != nil {
+				count += int(*remaining)
+			}
+			continue
+		}
+		opts.Limit = ListPageSize
+		for {
+			list, err := ri.List(ctx, opts)
+			if err != nil {
+				return 0, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+			}
+			count += len(list.Items)
+			if list.GetContinue() == "" {
+				break
+			}
+			opts.Continue = list.GetContinue()
+		}
+	}
+	return count, nil
+}
+
+// sortResources orders resources by namespace and resourceKey, so that scans cut down to
+// MaxScanResources keep the same resources from one run to the next.
+func sortResources(resources []*unstructured.Unstructured) {
+	slices.SortFunc(resources, func(a, b *unstructured.Unstructured) int {
+		return cmp.Or(strings.Compare(a.GetNamespace(), b.GetNamespace()), strings.Compare(resourceKey(*a), resourceKey(*b)))
+	})
+}
+
+// countResults counts the rule responses of engine responses by status the way the Kyverno CLI
+// does while it evaluates them, so that recounts of trimmed or merged results match the counts
+// of the engine: validate, verifyImages, mutate and generate rules of Kyverno policies, and the
+// responses of ValidatingAdmissionPolicies and CEL-based policies, each with their own rules.
+func countResults(auditWarn bool, responses []engineapi.EngineResponse) *processor.ResultCounts {
+	counts := &processor.ResultCounts{}
+	for _, er := range responses {
+		policy := er.Policy()
+		if policy == nil {
+			continue
+		}
+		switch {
+		case policy.AsKyvernoPolicy() != nil:
+			scored := annotations.Scored(policy.GetAnnotations())
+			for _, rule := range er.PolicyResponse.Rules {
+				status := rule.Status()
+				switch rule.RuleType() {
+				case engineapi.Validation, engineapi.ImageVerify:
+					switch status {
+					case engineapi.RuleStatusPass:
+						counts.Pass++
+					case engineapi.RuleStatusFail:
+						if !scored || (auditWarn && er.GetValidationFailureAction().Audit()) {
+							counts.Warn++
+						} else {
+							counts.Fail++
+						}
+					case engineapi.RuleStatusError:
+						counts.Error++
+					case engineapi.RuleStatusWarn:
+						counts.Warn++
+					case engineapi.RuleStatusSkip:
+						counts.Skip++
+					}
+				case engineapi.Mutation:
+					switch status {
+					case engineapi.RuleStatusPass:
+						counts.Pass++
+					case engineapi.RuleStatusSkip:
+						counts.Skip++
+					case engineapi.RuleStatusError:
+						counts.Error++
+					default:
+						counts.Fail++
+					}
+				case engineapi.Generation:
+					if status == engineapi.RuleStatusPass {
+						counts.Pass++
+					} else {
+						counts.Fail++
+					}
+				}
+			}
+		case policy.AsValidatingAdmissionPolicy() != nil:
+			for _, rule := range er.PolicyResponse.Rules {
+				switch rule.Status() {
+				case engineapi.RuleStatusPass:
+					counts.Pass++
+				case engineapi.RuleStatusFail:
+					counts.Fail++
+				case engineapi.RuleStatusError:
+					counts.Error++
+				}
+			}
+		default:
+			for _, rule := range er.PolicyResponse.Rules {
+				switch rule.Status() {
+				case engineapi.RuleStatusPass:
+					counts.Pass++
+				case engineapi.RuleStatusFail:
+					counts.Fail++
+				case engineapi.RuleStatusError:
+					counts.Error++
+				case engineapi.RuleStatusSkip:
+					counts.Skip++
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// tracedApply applies the policies of config in a span named after the scanned slice. Without
+// resources, the cluster resources are listed and evaluated in one go, so the span covers both;
+// the time the engine spent per policy is attached as events so the two can be told apart.
+func tracedApply(ctx context.Context, name string, config *apply.ApplyCommandConfig, resources []*unstructured.Unstructured) (*kyverno.ApplyResult, error) {
+	ctx, span := tracing.Start(ctx, "kyverno apply",
+		attribute.String("scan.job", name),
+		attribute.Bool("scan.cluster", config.Cluster),
+		attribute.String("scan.namespace", config.Namespace),
+	)
+	result, err := kyverno.Apply(ctx, config, resources)
+	if err == nil {
+		timings := ruleTimings(result.EngineResponses)
+		span.SetAttributes(
+			attribute.Int("scan.resources", len(result.Unstructured)),
+			attribute.Int("scan.engine_responses", len(result.EngineResponses)),
+			attribute.Float64("scan.engine_ms", timings.TotalMs),
+		)
+		for policy, ms := range timings.ByPolicy {
+			span.AddEvent("policy evaluated", trace.WithAttributes(attribute.String("policy", policy), attribute.Float64("engine_ms", ms)))
+		}
+	}
+	tracing.End(span, err)
+	return result, err
+}
+
+// applyOffline evaluates resources listed from the cluster in offline mode, so that policies are
+// applied to exactly the listed objects.
+func applyOffline(ctx context.Context, name string, config *apply.ApplyCommandConfig, resources []*unstructured.Unstructured) (*kyverno.ApplyResult, error) {
+	if len(resources) == 0 {
+		return &kyverno.ApplyResult{ResultCounts: &processor.ResultCounts{}}, nil
+	}
+	offline := *config
+	offline.Cluster = false
+	offline.Namespace = ""
+	offline.ResourcePaths = nil
+	return tracedApply(ctx, name, &offline, resources)
+}
+
+// scanPaths runs the Kyverno apply command once per local resource path on a bounded worker pool
+// and merges the per-path results, together with the failures of individual paths, so that large
+// sets of offline manifests are evaluated in parallel rather than in a single serial run.
+func scanPaths(ctx context.Context, config apply.ApplyCommandConfig, paths []string, concurrency int, progress *progressReporter) (*kyverno.ApplyResult, []error, error) {
+	jobs := make([]scanJob, 0, len(paths))
+	for _, path := range paths {
+		jobs = append(jobs, scanJob{
+			name:      path,
+			configure: func(c *apply.ApplyCommandConfig) { c.ResourcePaths = []string{path} },
+		})
+	}
+	return scanParallel(ctx, config, jobs, concurrency, progress)
+}
+
+// scanParallel runs the Kyverno apply command once per job on a bounded worker pool and merges
+// the results. Failures of individual jobs are logged and returned alongside the merged result, so
+// that one forbidden or broken slice does not abort the whole scan; an error is only returned if
+// every job failed or the context was cancelled. Each completed job is reported to progress
+// together with its result counts.
+func scanParallel(ctx context.Context, config apply.ApplyCommandConfig, jobs []scanJob, concurrency int, progress *progressReporter) (*kyverno.ApplyResult, []error, error) {
+	if concurrency <= 0 {
+		concurrency = defaultScanConcurrency
+	}
+
+	merged := &kyverno.ApplyResult{ResultCounts: &processor.ResultCounts{}}
+	if len(jobs) == 0 {
+		return merged, nil, nil
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		errs      []error
+		succeeded int
+		completed int
+		evaluated int
+	)
+
+	queue := make(chan scanJob)
+	for i := 0; i < concurrency && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				jobConfig := config
+				if job.configure != nil {
+					job.configure(&jobConfig)
+				}
+				var result *kyverno.ApplyResult
+				var err error
+				if job.list != nil {
+					var resources []*unstructured.Unstructured
+					if resources, err = job.list(ctx); err == nil {
+						result, err = applyOffline(ctx, job.name, &jobConfig, resources)
+					}
+				} else {
+					result, err = tracedApply(ctx, job.name, &jobConfig, nil)
+				}
+
+				mu.Lock()
+				completed++
+				var msg string
+				level := mcp.LoggingLevelInfo
+				if err != nil {
+					klog.ErrorS(err, "failed to scan", "job", job.name)
+					level = mcp.LoggingLevelWarning
+					errs = append(errs, fmt.Errorf("%s: %w", job.name, err))
+					msg = fmt.Sprintf("failed to scan %s (%d/%d, %d resources evaluated): %v", job.name, completed, len(jobs), evaluated, err)
+				} else {
+					succeeded++
+					evaluated += len(result.Unstructured)
+					mergeApplyResult(merged, result)
+					// Stream the findings of each completed slice so clients can surface them
+					// before the whole scan finishes.
+					msg = fmt.Sprintf("scanned %s (%d/%d, %d resources evaluated): %s", job.name, completed, len(jobs), evaluated, formatResultCounts(result.ResultCounts))
+				}
+				mu.Unlock()
+
+				clientLog(ctx, level, msg)
+				progress.step(ctx, len(jobs), msg)
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		mu.Lock()
+		full := MaxScanResources > 0 && evaluated >= MaxScanResources
+		if full {
+			errs = append(errs, fmt.Errorf("%s: %w", job.name, errResourceLimit))
+		}
+		mu.Unlock()
+		if full {
+			continue
+		}
+		select {
+		case queue <- job:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if succeeded == 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	capResources(merged, config.AuditWarn)
+	return merged, errs, nil
+}
+
+// capResources drops the resources of result beyond MaxScanResources, in the order of
+// sortResources, with their engine responses, and recounts the remaining results. It reports
+// whether resources were dropped.
+func capResources(result *kyverno.ApplyResult, auditWarn bool) bool {
+	if MaxScanResources <= 0 || len(result.Unstructured) <= MaxScanResources {
+		return false
+	}
+	resources := slices.DeleteFunc(slices.Clone(result.Unstructured), func(u *unstructured.Unstructured) bool { return u == nil })
+	sortResources(resources)
+	resources = resources[:min(MaxScanResources, len(resources))]
+	kept := make(map[string]struct{}, len(resources))
+	for _, u := range resources {
+		kept[resourceKey(*u)] = struct{}{}
+	}
+	var responses []engineapi.EngineResponse
+	for _, er := range result.EngineResponses {
+		if _, ok := kept[resourceKey(er.Resource)]; ok {
+			responses = append(responses, er)
+		}
+	}
+	result.Unstructured = resources
+	result.EngineResponses = responses
+	result.ResultCounts = countResults(auditWarn, responses)
+	return true
+}
+
+// reachedResourceLimit reports whether a scan evaluating the resources of result stopped at
+// MaxScanResources.
+func reachedResourceLimit(result *kyverno.ApplyResult) bool {
+	return MaxScanResources > 0 && len(result.Unstructured) >= MaxScanResources
+}
+
+// resourceLimitWarning is the warning of scans that stopped at MaxScanResources.
+func resourceLimitWarning() string {
+	return fmt.Sprintf("the scan stopped after %d resources, the limit of resources per scan of the server; narrow it down with namespace, labelSelector or resourcePaths, or use sample, to cover the remaining resources", MaxScanResources)
+}
+
+// formatResultCounts renders result counts for progress messages.
+func formatResultCounts(rc *processor.ResultCounts) string {
+	if rc == nil {
+		return "no results"
+	}
+	return fmt.Sprintf("%d fail, %d warn, %d error, %d pass, %d skip", rc.Fail, rc.Warn, rc.Error, rc.Pass, rc.Skip)
+}
+
+// mergeApplyResult appends the engine responses and resources of src to dst and adds up the
+// result counts.
+func mergeApplyResult(dst, src *kyverno.ApplyResult) {
+	if src == nil {
+		return
+	}
+	if src.ResultCounts != nil {
+		dst.ResultCounts.Pass += src.ResultCounts.Pass
+		dst.ResultCounts.Fail += src.ResultCounts.Fail
+		dst.ResultCounts.Warn += src.ResultCounts.Warn
+		dst.ResultCounts.Error += src.ResultCounts.Error
+		dst.ResultCounts.Skip += src.ResultCounts.Skip
+	}
+	dst.Unstructured = append(dst.Unstructured, src.Unstructured...)
+	dst.EngineResponses = append(dst.EngineResponses, src.EngineResponses...)
+	// Every slice validates the same policies, so each skipped policy is recorded once
+	for _, skipped := range src.SkippedPolicies {
+		if !slices.Contains(dst.SkippedPolicies, skipped) {
+			dst.SkippedPolicies = append(dst.SkippedPolicies, skipped)
+		}
+	}
+	for _, warning := range src.Warnings {
+		if !slices.Contains(dst.Warnings, warning) {
+			dst.Warnings = append(dst.Warnings, warning)
+		}
+	}
+}
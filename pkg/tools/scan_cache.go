@@ -0,0 +1,130 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+)
+
+// ScanCacheTTL is how long the result of a cluster scan is reused by apply_policies calls
+// scanning the same namespaces with the same policies; 0 disables the cache. It is set from the
+// --scan-cache-ttl flag.
+var ScanCacheTTL = 2 * time.Minute
+
+// maxCachedScans bounds the number of scan results kept in memory, since the results of large
+// clusters are big.
+const maxCachedScans = 8
+
+// cachedScan is the result of a cluster scan, before any of the filters applied to it.
+type cachedScan struct {
+	result *kyverno.ApplyResult
+	// warnings lists the slices of the scan that failed.
+	warnings []string
+	// sampling describes the sample, for sampled scans.
+	sampling *samplingSummary
+	at       time.Time
+}
+
+// scanCache keeps recent cluster scan results so that follow-up questions about the same scan,
+// such as another page, filter or grouping, do not scan the cluster again.
+type scanCache struct {
+	mu    sync.Mutex
+	scans map[string]cachedScan
+}
+
+var scanResults = &scanCache{scans: map[string]cachedScan{}}
+
+func (c *scanCache) get(key string) (cachedScan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scan, ok := c.scans[key]
+	if ok && time.Since(scan.at) > ScanCacheTTL {
+		delete(c.scans, key)
+		return cachedScan{}, false
+	}
+	return scan, ok
+}
+
+// put records a scan, dropping expired ones and, once the cache is full, the oldest one.
+func (c *scanCache) put(key string, scan cachedScan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var oldestKey string
+	for k, s := range c.scans {
+		if time.Since(s.at) > ScanCacheTTL {
+			delete(c.scans, k)
+			continue
+		}
+		if oldestKey == "" || s.at.Before(c.scans[oldestKey].at) {
+			oldestKey = k
+		}
+	}
+	if len(c.scans) >= maxCachedScans {
+		delete(c.scans, oldestKey)
+	}
+	c.scans[key] = scan
+}
+
+// clear drops every cached scan, e.g. when the current kubeconfig context changes.
+func (c *scanCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.scans)
+}
+
+// scanKey identifies a cluster scan by the kubeconfig context, namespaces and policy set it
+// covers, together with every other argument that changes what the Kyverno CLI evaluates.
+// Policy files are content addressed by the policy cache, so changed policies give a new key.
+func scanKey(ctx context.Context, opts applyOptions, policyPaths []string) string {
+	excludes := make([]string, 0, len(opts.namespaces.Exclude)+len(opts.namespaces.ExcludePatterns))
+	for ns := range opts.namespaces.Exclude {
+		excludes = append(excludes, ns)
+	}
+	for _, re := range opts.namespaces.ExcludePatterns {
+		excludes = append(excludes, re.String())
+	}
+	sort.Strings(excludes)
+	policies := make([]string, 0, len(opts.policies))
+	for name := range opts.policies {
+		policies = append(policies, name)
+	}
+	sort.Strings(policies)
+
+	// json.Marshal sorts map keys, so equal arguments always give the same key
+	creds, _ := common.CredentialsOf(ctx)
+	key, err := json.Marshal(map[string]any{
+		"credentials":       creds.Name,
+		"context":           common.KubeContext(ctx),
+		"impersonate":       common.ImpersonationOf(ctx).String(),
+		"allNamespaces":     opts.namespaces.All,
+		"namespaces":        opts.namespaces.Namespaces,
+		"exclude":           excludes,
+		"policySets":        opts.policySets,
+		"policies":          policies,
+		"policyFiles":       policyPaths,
+		"gitBranch":         opts.gitBranch,
+		"values":            opts.values,
+		"valuesFile":        opts.valuesFile,
+		"userInfo":          opts.userInfo,
+		"auditAsWarn":       opts.auditAsWarn,
+		"exceptionPaths":    opts.exceptionPaths,
+		"clusterExceptions": opts.clusterExceptions,
+		"registryAccess":    opts.registryAccess,
+		"contextPath":       opts.contextPath,
+		"contextResources":  opts.contextResources,
+		"labelSelector":     opts.selector.listOptions().LabelSelector,
+		"fieldSelector":     opts.selector.listOptions().FieldSelector,
+		"sample":            opts.sample,
+		"sampleSeed":        opts.sampleSeed,
+	})
+	if err != nil {
+		return ""
+	}
+	return contentHash(key)
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+)
+
+func TestScanCache(t *testing.T) {
+	ttl := ScanCacheTTL
+	ScanCacheTTL = time.Minute
+	t.Cleanup(func() { ScanCacheTTL = ttl })
+
+	tests := []struct {
+		name    string
+		scans   map[string]time.Duration
+		key     string
+		wantHit bool
+	}{
+		{name: "recent scan", scans: map[string]time.Duration{"a": 10 * time.Second}, key: "a", wantHit: true},
+		{name: "expired scan", scans: map[string]time.Duration{"a": 2 * time.Minute}, key: "a"},
+		{name: "other scan", scans: map[string]time.Duration{"a": 10 * time.Second}, key: "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &scanCache{scans: map[string]cachedScan{}}
+			for key, age := range tt.scans {
+				c.scans[key] = cachedScan{warnings: []string{key}, at: time.Now().Add(-age)}
+			}
+			scan, ok := c.get(tt.key)
+			if ok != tt.wantHit {
+				t.Fatalf("get(%q) hit = %v, want %v", tt.key, ok, tt.wantHit)
+			}
+			if ok && (len(scan.warnings) != 1 || scan.warnings[0] != tt.key) {
+				t.Errorf("get(%q) = %v, want the scan of %q", tt.key, scan.warnings, tt.key)
+			}
+		})
+	}
+}
+
+func TestScanCachePutEvicts(t *testing.T) {
+	ttl := ScanCacheTTL
+	ScanCacheTTL = time.Minute
+	t.Cleanup(func() { ScanCacheTTL = ttl })
+
+	c := &scanCache{scans: map[string]cachedScan{}}
+	c.put("expired", cachedScan{at: time.Now().Add(-2 * time.Minute)})
+	for i := range maxCachedScans {
+		c.put(fmt.Sprintf("scan-%d", i), cachedScan{at: time.Now().Add(time.Duration(i-maxCachedScans) * time.Second)})
+	}
+	if _, ok := c.scans["expired"]; ok {
+		t.Error("put() kept an expired scan")
+	}
+	c.put("latest", cachedScan{at: time.Now()})
+	if len(c.scans) != maxCachedScans {
+		t.Errorf("cache holds %d scans, want %d", len(c.scans), maxCachedScans)
+	}
+	if _, ok := c.scans["scan-0"]; ok {
+		t.Error("put() kept the oldest scan of a full cache")
+	}
+	if _, ok := c.get("latest"); !ok {
+		t.Error("put() did not record the latest scan")
+	}
+}
+
+func TestScanKey(t *testing.T) {
+	scope := func(namespace, exclude string) common.NamespaceScope {
+		s, err := common.ResolveNamespaces(namespace, exclude)
+		if err != nil {
+			t.Fatalf("ResolveNamespaces(%q, %q) error = %v", namespace, exclude, err)
+		}
+		return s
+	}
+	base := applyOptions{namespaces: scope("all", "kube-system,kyverno"), policySets: "pod-security"}
+	policyPaths := []string{"/tmp/pod-security.yaml"}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		opts        applyOptions
+		policyPaths []string
+		wantSame    bool
+	}{
+		{name: "same scan", ctx: ctx, opts: base, policyPaths: policyPaths, wantSame: true},
+		{name: "excludes in another order", ctx: ctx, opts: applyOptions{namespaces: scope("all", "kyverno,kube-system"), policySets: "pod-security"}, policyPaths: policyPaths, wantSame: true},
+		{name: "result filters and pages", ctx: ctx, opts: applyOptions{namespaces: base.namespaces, policySets: "pod-security", groupBy: "policy", noCache: true}, policyPaths: policyPaths, wantSame: true},
+		{name: "other namespace", ctx: ctx, opts: applyOptions{namespaces: scope("team-a", ""), policySets: "pod-security"}, policyPaths: policyPaths},
+		{name: "other policy set", ctx: ctx, opts: applyOptions{namespaces: base.namespaces, policySets: "rbac-best-practices"}, policyPaths: policyPaths},
+		{name: "changed policy files", ctx: ctx, opts: base, policyPaths: []string{"/tmp/pod-security-v2.yaml"}},
+		{name: "other kubeconfig context", ctx: common.WithKubeContext(ctx, "staging"), opts: base, policyPaths: policyPaths},
+		{name: "impersonated user", ctx: common.WithImpersonation(ctx, common.Impersonation{User: "jane"}), opts: base, policyPaths: policyPaths},
+		{name: "sampled scan", ctx: ctx, opts: applyOptions{namespaces: base.namespaces, policySets: "pod-security", sample: 5}, policyPaths: policyPaths},
+	}
+	want := scanKey(ctx, base, policyPaths)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scanKey(tt.ctx, tt.opts, tt.policyPaths)
+			if got == "" {
+				t.Fatal("scanKey() returned no key")
+			}
+			if (got == want) != tt.wantSame {
+				t.Errorf("scanKey() same as the base scan = %v, want %v", got == want, tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+	"github.com/nirmata/kyverno-mcp/pkg/history"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+// defaultHistoryLimit is the number of scans scan_history lists when the call sets no limit.
+const defaultHistoryLimit = 20
+
+// recordScan adds the summary of a completed apply_policies scan to the scan history, when it
+// is enabled. Failures are logged, since the history must not fail the scan.
+func recordScan(ctx context.Context, opts applyOptions, results []policyreportv1alpha2.PolicyReportResult, resourcesScanned, policiesApplied int, duration time.Duration) {
+	if !history.Enabled() {
+		return
+	}
+	breakdown := newCountsBreakdown()
+	for _, r := range results {
+		f := policyReportResultGroupFields(r)
+		breakdown.add(f.policy, f.namespace, r.Result)
+	}
+	policySets := opts.policySets
+	if len(opts.policyPaths) > 0 {
+		policySets = strings.Join(opts.policyPaths, ",")
+	}
+	creds, _ := common.CredentialsOf(ctx)
+	scan := history.Scan{
+		Credentials:      creds.Name,
+		Context:          common.KubeContext(ctx),
+		Namespace:        opts.namespaces.String(),
+		PolicySets:       policySets,
+		Sampled:          opts.sample > 0,
+		Incremental:      opts.incremental,
+		Filters:          scanFilters(opts),
+		ResourcesScanned: resourcesScanned,
+		PoliciesApplied:  policiesApplied,
+		Duration:         duration.Round(time.Millisecond).String(),
+		Totals:           history.Counts(breakdown.Totals),
+		ByPolicy:         map[string]history.Counts{},
+		ByNamespace:      map[string]history.Counts{},
+	}
+	for policy, counts := range breakdown.ByPolicy {
+		scan.ByPolicy[policy] = history.Counts(counts)
+	}
+	for ns, counts := range breakdown.ByNamespace {
+		scan.ByNamespace[ns] = history.Counts(counts)
+	}
+	if _, err := history.Record(scan); err != nil {
+		klog.ErrorS(err, "failed to record scan in history")
+	}
+}
+
+// scanFilters describes the arguments of a scan that narrow its results down, in a canonical
+// form, so that only scans counted the same way are compared.
+func scanFilters(opts applyOptions) string {
+	var parts []string
+	if len(opts.policies) > 0 {
+		parts = append(parts, "policies="+strings.Join(slices.Sorted(maps.Keys(opts.policies)), ","))
+	}
+	if selector := opts.selector.listOptions(); selector.LabelSelector != "" {
+		parts = append(parts, "labelSelector="+selector.LabelSelector)
+	}
+	if selector := opts.selector.listOptions(); selector.FieldSelector != "" {
+		parts = append(parts, "fieldSelector="+selector.FieldSelector)
+	}
+	if filter := opts.filter.String(); filter != "" {
+		parts = append(parts, filter)
+	}
+	if opts.includePassing {
+		parts = append(parts, "includePassing")
+	}
+	if !opts.skipControllerOwned {
+		parts = append(parts, "skipControllerOwned=false")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// scanComparison is the result of comparing two recorded scans.
+type scanComparison struct {
+	From history.Scan `json:"from"`
+	To   history.Scan `json:"to"`
+	// Delta holds the changes from From to To, leaving out policies and namespaces whose counts
+	// did not change.
+	Delta scanDelta `json:"delta"`
+}
+
+type scanDelta struct {
+	Totals      history.Counts            `json:"totals"`
+	ByPolicy    map[string]history.Counts `json:"byPolicy"`
+	ByNamespace map[string]history.Counts `json:"byNamespace"`
+}
+
+// diffCounts returns the non-zero changes between two breakdowns, including keys present in
+// only one of them.
+func diffCounts(from, to map[string]history.Counts) map[string]history.Counts {
+	delta := map[string]history.Counts{}
+	for k, c := range to {
+		if d := c.Sub(from[k]); d != (history.Counts{}) {
+			delta[k] = d
+		}
+	}
+	for k, c := range from {
+		if _, ok := to[k]; !ok {
+			delta[k] = history.Counts{}.Sub(c)
+		}
+	}
+	return delta
+}
+
+// ScanHistory registers the scan_history tool with the MCP server. It is only registered when
+// the history is enabled with --history-db.
+func ScanHistory(s *server.MCPServer) {
+	klog.InfoS("Registering tool: scan_history")
+	s.AddTool(mcp.NewTool("scan_history",
+		mcp.WithDescription(`Query the summaries of previous apply_policies scans, which are kept across server restarts. action="list" returns recorded scans newest first, with their result counts overall, per policy and per namespace. action="get" returns the scan with the given id. action="compare" returns two scans and the changes between them, by default the latest scan matching the filters and the scan before it whose results were filtered the same way, e.g. to check the effect of remediation work. Scans whose results were filtered differently by apply_policies, as listed in their filters, cannot be compared.`),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Scan history",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithString("action", mcp.Description(`list, get or compare (default: list)`), mcp.Enum("list", "get", "compare"), mcp.DefaultString("list")),
+		mcp.WithNumber("id", mcp.Description(`ID of the scan to get, or of the later scan to compare (default for compare: the latest matching scan)`)),
+		mcp.WithNumber("baseId", mcp.Description(`ID of the earlier scan to compare against (default: the matching scan before id)`)),
+		mcp.WithString("namespace", mcp.Description(`Only consider scans of this namespace scope, as passed to apply_policies, e.g. "team-a" or "all"`)),
+		mcp.WithString("policySets", mcp.Description(`Only consider scans of this policy set`)),
+		mcp.WithString("context", mcp.Description(`Only consider scans of this kubeconfig context`)),
+		mcp.WithString("since", mcp.Description(`Only consider scans recorded since this time, as an RFC 3339 timestamp or a duration such as "24h" or "168h"`)),
+		mcp.WithNumber("limit", mcp.Description(`Maximum number of scans to list (default: 20)`)),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		creds, _ := common.CredentialsOf(ctx)
+		filter := history.Filter{
+			Credentials: creds.Name,
+			Namespace:   strings.TrimSpace(request.GetString("namespace", "")),
+			PolicySets:  strings.TrimSpace(request.GetString("policySets", "")),
+			Context:     strings.TrimSpace(request.GetString("context", "")),
+			Limit:       request.GetInt("limit", defaultHistoryLimit),
+		}
+		if since := strings.TrimSpace(request.GetString("since", "")); since != "" {
+			if d, err := time.ParseDuration(since); err == nil {
+				filter.Since = time.Now().Add(-d)
+			} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+				filter.Since = t
+			} else {
+				return invalidArgument("invalid since %q: must be an RFC 3339 timestamp or a duration such as 24h", since), nil
+			}
+		}
+		id := uint64(max(request.GetInt("id", 0), 0))
+		baseID := uint64(max(request.GetInt("baseId", 0), 0))
+
+		var out any
+		switch action := request.GetString("action", "list"); action {
+		case "list":
+			scans, err := history.List(filter)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			out = common.Page[history.Scan]{Results: scans, Total: len(scans)}
+		case "get":
+			if id == 0 {
+				return invalidArgument("id is required to get a scan"), nil
+			}
+			scan, result := getScan(filter, id)
+			if result != nil {
+				return result, nil
+			}
+			out = scan
+		case "compare":
+			comparison, result := compareScans(filter, id, baseID)
+			if result != nil {
+				return result, nil
+			}
+			out = comparison
+		default:
+			return invalidArgument("invalid action %q: must be list, get or compare", action), nil
+		}
+
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
+
+// getScan returns the scan with the given ID when it was made with the credentials of filter.
+// Scans of other credentials are reported as not found, so that their IDs reveal nothing.
+// Failures are returned as a tool result.
+func getScan(filter history.Filter, id uint64) (history.Scan, *mcp.CallToolResult) {
+	scan, found, err := history.Get(id)
+	if err != nil {
+		return history.Scan{}, errorResult(err)
+	}
+	if !found || scan.Credentials != filter.Credentials {
+		return history.Scan{}, notFound("call scan_history with action=list for the recorded scans", "scan %d not found", id)
+	}
+	return scan, nil
+}
+
+// compareScans compares the scans with the given IDs. A zero id selects the latest scan matching
+// filter, and a zero baseID the matching scan recorded before it. Failures are returned as a
+// tool result.
+func compareScans(filter history.Filter, id, baseID uint64) (*scanComparison, *mcp.CallToolResult) {
+	var to, from history.Scan
+	var result *mcp.CallToolResult
+	if id != 0 {
+		if to, result = getScan(filter, id); result != nil {
+			return nil, result
+		}
+	}
+	if baseID != 0 {
+		if from, result = getScan(filter, baseID); result != nil {
+			return nil, result
+		}
+	}
+	if id == 0 || baseID == 0 {
+		filter.Limit = 0
+		scans, err := history.List(filter)
+		if err != nil {
+			return nil, errorResult(err)
+		}
+		// Scans are listed newest first; the base defaults to the latest earlier scan with the
+		// same filters
+		for _, s := range scans {
+			switch {
+			case to.ID == 0 && (from.ID == 0 || s.ID > from.ID):
+				to = s
+			case from.ID == 0 && to.ID != 0 && s.ID < to.ID && s.Filters == to.Filters:
+				from = s
+			}
+		}
+	}
+	if to.ID == 0 || from.ID == 0 {
+		return nil, notFound("run apply_policies again with the same filters, or relax the filters of scan_history, so that there are two scans to compare", "fewer than two comparable scans are recorded")
+	}
+	if from.Filters != to.Filters {
+		return nil, invalidArgument("scans %d and %d cannot be compared: their results were filtered differently (%q and %q)", from.ID, to.ID, from.Filters, to.Filters)
+	}
+
+	return &scanComparison{
+		From: from,
+		To:   to,
+		Delta: scanDelta{
+			Totals:      to.Totals.Sub(from.Totals),
+			ByPolicy:    diffCounts(from.ByPolicy, to.ByPolicy),
+			ByNamespace: diffCounts(from.ByNamespace, to.ByNamespace),
+		},
+	}, nil
+}
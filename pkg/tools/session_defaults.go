@@ -0,0 +1,196 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// outputFormats lists the formats tool results can be returned in.
+var outputFormats = []string{"json", "yaml"}
+
+// sessionDefaults holds the argument defaults a client set for its session.
+type sessionDefaults struct {
+	// Namespace is used by tools taking a namespace argument when the call does not set one.
+	Namespace string `json:"namespace,omitempty"`
+	// Context is the kubeconfig context cluster tools talk to, instead of the current context.
+	Context string `json:"context,omitempty"`
+	// PolicySets is used by apply_policies when the call does not set policySets.
+	PolicySets string `json:"policySets,omitempty"`
+	// Output is the format of JSON tool results: json or yaml.
+	Output string `json:"output,omitempty"`
+}
+
+// sessionStates holds the defaults of every session that set some.
+type sessionStates struct {
+	mu       sync.Mutex
+	defaults map[string]sessionDefaults
+}
+
+var sessionState = &sessionStates{defaults: map[string]sessionDefaults{}}
+
+func (s *sessionStates) get(sessionID string) sessionDefaults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.defaults[sessionID]
+}
+
+// update merges the non-empty fields of d into the defaults of a session and returns the result.
+func (s *sessionStates) update(sessionID string, d sessionDefaults) sessionDefaults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.defaults[sessionID]
+	if d.Namespace != "" {
+		current.Namespace = d.Namespace
+	}
+	if d.Context != "" {
+		current.Context = d.Context
+	}
+	if d.PolicySets != "" {
+		current.PolicySets = d.PolicySets
+	}
+	if d.Output != "" {
+		current.Output = d.Output
+	}
+	s.defaults[sessionID] = current
+	return current
+}
+
+func (s *sessionStates) clear(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.defaults, sessionID)
+}
+
+// ForgetSessionDefaults drops the defaults of a session when it ends. It is registered as an
+// OnUnregisterSession hook.
+func ForgetSessionDefaults(_ context.Context, session server.ClientSession) {
+	sessionState.clear(session.SessionID())
+}
+
+// SessionDefaults registers the session_defaults tool with the MCP server.
+func SessionDefaults(s *server.MCPServer) {
+	klog.InfoS("Registering tool: session_defaults")
+	s.AddTool(mcp.NewTool("session_defaults",
+		mcp.WithDescription(`Get or set defaults for the rest of this MCP session, so they need not be repeated on every call. The namespace and policySets defaults apply to tools taking those arguments when a call leaves them out, context selects the kubeconfig context cluster tools use without switching the kubeconfig, and output selects the format of JSON results. action="set" only changes the given fields, action="clear" removes all defaults, and every action returns the resulting defaults.`),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Session defaults",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithString("action", mcp.Description(`get, set or clear (default: get)`), mcp.Enum("get", "set", "clear"), mcp.DefaultString("get")),
+		mcp.WithString("namespace", mcp.Description(`Default namespace argument, e.g. "team-a", "team-a,team-b" or "all"`)),
+		mcp.WithString("context", mcp.Description(`Kubeconfig context to use for cluster calls of this session, as listed by list_contexts`)),
+		mcp.WithString("policySets", mcp.Description(`Default policySets argument of apply_policies, e.g. "pod-security"`)),
+		mcp.WithString("output", mcp.Description(`Format of JSON tool results: json or yaml`), mcp.Enum(outputFormats...)),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		session := server.ClientSessionFromContext(ctx)
+		if session == nil {
+			return toolError{Code: codeInternal, Message: "session_defaults requires a client session"}.result(), nil
+		}
+
+		var defaults sessionDefaults
+		switch action := request.GetString("action", "get"); action {
+		case "get":
+			defaults = sessionState.get(session.SessionID())
+		case "clear":
+			sessionState.clear(session.SessionID())
+		case "set":
+			d := sessionDefaults{
+				Namespace:  strings.TrimSpace(request.GetString("namespace", "")),
+				Context:    strings.TrimSpace(request.GetString("context", "")),
+				PolicySets: strings.TrimSpace(request.GetString("policySets", "")),
+				Output:     strings.TrimSpace(request.GetString("output", "")),
+			}
+			if d.PolicySets != "" {
+				if _, err := parsePolicySets(d.PolicySets); err != nil {
+					return invalidArgument("%v", err), nil
+				}
+			}
+			if d.Output != "" && !slices.Contains(outputFormats, d.Output) {
+				return invalidArgument("invalid output %q: must be one of %s", d.Output, strings.Join(outputFormats, ", ")), nil
+			}
+			if creds, ok := common.CredentialsOf(ctx); ok && d.Context != "" && d.Context != creds.Context {
+				return toolError{Code: codePermissionDenied, Message: fmt.Sprintf("the API token of this session is confined to its own credentials and cannot select context %q", d.Context)}.result(), nil
+			}
+			if d.Context != "" {
+				cfg, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+				if err != nil {
+					return errorResult(err), nil
+				}
+				if _, ok := cfg.Contexts[d.Context]; !ok {
+					return notFound("call list_contexts for the available contexts", "context %q not found", d.Context), nil
+				}
+			}
+			defaults = sessionState.update(session.SessionID(), d)
+		default:
+			return invalidArgument("invalid action %q: must be get, set or clear", action), nil
+		}
+
+		out, err := json.MarshalIndent(defaults, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	})
+}
+
+// SessionDefaultsMiddleware applies the defaults of the calling session to every tool call:
+// missing namespace and policySets arguments are filled in, cluster clients use the selected
+// kubeconfig context, and successful JSON results are converted to the selected output format.
+func SessionDefaultsMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		session := server.ClientSessionFromContext(ctx)
+		if session == nil || request.Params.Name == "session_defaults" {
+			return next(ctx, request)
+		}
+		defaults := sessionState.get(session.SessionID())
+		if defaults == (sessionDefaults{}) {
+			return next(ctx, request)
+		}
+
+		args := map[string]any{}
+		if original, ok := request.Params.Arguments.(map[string]any); ok && original != nil {
+			args = maps.Clone(original)
+		}
+		if _, ok := args["namespace"]; !ok && defaults.Namespace != "" {
+			args["namespace"] = defaults.Namespace
+		}
+		if _, ok := args["policySets"]; !ok && defaults.PolicySets != "" && request.Params.Name == "apply_policies" {
+			args["policySets"] = defaults.PolicySets
+		}
+		request.Params.Arguments = args
+
+		result, err := next(common.WithKubeContext(ctx, defaults.Context), request)
+		// Errors stay JSON so that clients can always parse the error envelope
+		if err != nil || result == nil || result.IsError || defaults.Output != "yaml" {
+			return result, err
+		}
+		for i, content := range result.Content {
+			text, ok := content.(mcp.TextContent)
+			if !ok || !json.Valid([]byte(text.Text)) {
+				continue
+			}
+			if converted, err := yaml.JSONToYAML([]byte(text.Text)); err == nil {
+				text.Text = string(converted)
+				result.Content[i] = text
+			}
+		}
+		return result, nil
+	}
+}
@@ -6,14 +6,23 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/nirmata/kyverno-mcp/pkg/common"
+	"github.com/nirmata/kyverno-mcp/pkg/metrics"
 
 	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	reportsv1 "github.com/kyverno/kyverno/api/reports/v1"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
@@ -29,41 +38,200 @@ func ShowViolations(s *server.MCPServer) {
 	s.AddTool(
 		mcp.NewTool(
 			"show_violations",
-			mcp.WithDescription(`This tool is used when Kyverno is installed in the cluster. It returns all non-passing Kyverno PolicyReport results for a workload.`),
-			mcp.WithString("namespace", mcp.Description(`Namespace to query (default: default, use "all" for all namespaces)`), mcp.DefaultString("default")),
-			mcp.WithString("namespace_exclude", mcp.Description(`Comma-separated namespaces to exclude when namespace="all" (default: kube-system,kyverno)`), mcp.DefaultString("kube-system,kyverno")),
+			mcp.WithDescription(`This tool is used when Kyverno is installed in the cluster. It returns all non-passing Kyverno PolicyReport results for a workload, optionally including passing results. When the violations would exceed the response size limit of the server, they are returned as {results, total, nextCursor, truncated, warnings} and nextCursor fetches the rest.`),
+			mcp.WithToolAnnotation(mcp.ToolAnnotation{
+				Title:           "Show policy violations",
+				ReadOnlyHint:    mcp.ToBoolPtr(true),
+				DestructiveHint: mcp.ToBoolPtr(false),
+				IdempotentHint:  mcp.ToBoolPtr(true),
+				OpenWorldHint:   mcp.ToBoolPtr(true),
+			}),
+			mcp.WithString("namespace", mcp.Description(`Namespace to query, or a comma-separated list of namespaces to merge results across, e.g. "team-a,team-b" (default: default, use "all" for all namespaces)`), mcp.DefaultString(common.DefaultNamespace)),
+			mcp.WithString("namespace_exclude", mcp.Description(`Comma-separated namespaces or regular expressions such as "kube-.*" to exclude when namespace="all" (default: kube-system,kyverno)`), mcp.DefaultString(common.DefaultNamespaceExcludes)),
+			mcp.WithString("namespace_exclude_selector", mcp.Description(`Label selector of namespaces to exclude when namespace="all", e.g. "environment=system" (default: none)`)),
+			mcp.WithString("minSeverity", mcp.Description(`Only return results at or above this severity: info, low, medium, high, critical (default: all severities)`), mcp.Enum("info", "low", "medium", "high", "critical")),
+			mcp.WithString("categories", mcp.Description(`Comma-separated policy categories to include, e.g. "Pod Security Standards (Baseline)" (default: all categories)`)),
+			mcp.WithString("severity", mcp.Description(`Only return violations with exactly this severity, or a comma-separated list of severities, e.g. "critical" or "critical,low"; cannot be combined with minSeverity (default: all severities)`)),
+			mcp.WithString("category", mcp.Description(`Only return violations of policies in this category, e.g. "Pod Security Standards (Restricted)"; cannot be combined with categories (default: all categories)`)),
+			mcp.WithString("groupBy", mcp.Description(`Return violations as a JSON object grouped by policy, resource, namespace, kind or severity instead of a flat array (default: no grouping)`), mcp.Enum(groupByValues...)),
+			mcp.WithString("sortBy", mcp.Description(`Order violations, or groups when groupBy is set, by count (most frequent first), severity (most severe first) or timestamp (newest first). Sorted groups are returned as an array of {group, count, results} (default: namespace, resource, policy and rule order)`), mcp.Enum(sortByValues...)),
+			mcp.WithNumber("limit", mcp.Description(`Maximum number of violations to return. When set, the response is wrapped as {results, total, nextCursor} (default: no limit)`)),
+			mcp.WithString("cursor", mcp.Description(`Opaque cursor returned as nextCursor by a previous call, used to fetch the next page of violations`)),
+			mcp.WithString("continue", mcp.Description(`Alias of cursor, for clients used to Kubernetes list pagination`)),
+			mcp.WithString("policy", mcp.Description(`Only return violations of this policy, e.g. "disallow-latest-tag" (default: all policies)`)),
+			mcp.WithString("rule", mcp.Description(`Only return violations of this rule (default: all rules)`)),
+			mcp.WithString("kind", mcp.Description(`Only return violations for resources of this kind, e.g. "Deployment" (default: all kinds)`)),
+			mcp.WithString("resourceName", mcp.Description(`Only return violations for resources with this name (default: all resources)`)),
+			mcp.WithString("source", mcp.Description(`Only return results produced by this policy engine, or a comma-separated list of engines, as recorded in the result source field, e.g. "kyverno" or "trivy,falco". Use "all" for every producer (default: kyverno)`), mcp.DefaultString(defaultResultSource)),
+			mcp.WithString("since", mcp.Description(`Only return results recorded after this time, given as an RFC 3339 timestamp such as "2025-06-01T09:00:00Z" or as a duration relative to now such as "24h" (default: no time limit)`)),
+			mcp.WithString("reportSelector", mcp.Description(`Label selector restricting the reports that are read, e.g. "app.kubernetes.io/managed-by=kyverno" (default: all reports)`)),
+			mcp.WithBoolean("resolveOwners", mcp.Description(`Follow owner references of violating resources, e.g. Pod → ReplicaSet → Deployment, and report the top-level workload users actually edit. Costs API requests per violation and is bounded per call; use with limit on large clusters (default: false)`)),
+			mcp.WithBoolean("includePass", mcp.Description(`Also return passing results, e.g. to demonstrate compliance (default: false)`), mcp.DefaultBool(false)),
+			mcp.WithBoolean("summary", mcp.Description(`Return only pass/fail/warn/error/skip counts, in total and per namespace and policy, computed from the report summaries instead of individual violations. Only the namespace, source and reportSelector arguments apply in this mode (default: false)`), mcp.DefaultBool(false)),
+			asArgument,
+			asGroupsArgument,
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			nsExclude, _ := req.RequireString("namespace_exclude")
+			if nsExclude == "" {
+				nsExclude = common.DefaultNamespaceExcludes
+			}
 			ns, _ := req.RequireString("namespace")
-			if ns == "" {
-				ns = "default"
+			namespaces, err := common.ResolveNamespaces(ns, nsExclude)
+			if err != nil {
+				return invalidArgument("%v", err), nil
+			}
+			if err := namespaces.ExcludeMatching(ctx, req.GetString("namespace_exclude_selector", "")); err != nil {
+				return errorResult(err), nil
+			}
+			if err := namespaces.Validate(ctx); err != nil {
+				return notFound("list the namespaces of the cluster and pick existing ones", "%v", err), nil
 			}
 
-			nsExclude, _ := req.RequireString("namespace_exclude")
-			if nsExclude == "" {
-				nsExclude = "kube-system,kyverno"
+			// severity and category overlap with minSeverity and categories, which have no
+			// precedence over them, so only one of each pair may be set
+			minSeverity, severity := req.GetString("minSeverity", ""), req.GetString("severity", "")
+			if strings.TrimSpace(minSeverity) != "" && strings.TrimSpace(severity) != "" {
+				return invalidArgument("set either minSeverity or severity, not both"), nil
+			}
+			categories, category := req.GetString("categories", ""), req.GetString("category", "")
+			if strings.TrimSpace(categories) != "" && strings.TrimSpace(category) != "" {
+				return invalidArgument("set either categories or category, not both"), nil
+			}
+			filter, err := newResultFilter(minSeverity, categories+","+category)
+			if err != nil {
+				return invalidArgument("%v", err), nil
+			}
+			if filter, err = filter.withSeverities(severity); err != nil {
+				return invalidArgument("%v", err), nil
+			}
+
+			groupBy := req.GetString("groupBy", "")
+			if err := validateGroupBy(groupBy); err != nil {
+				return invalidArgument("%v", err), nil
+			}
+
+			sortBy := req.GetString("sortBy", "")
+			if err := validateSortBy(sortBy); err != nil {
+				return invalidArgument("%v", err), nil
 			}
 
-			violationsJSON, err := gatherViolationsJSON(ctx, ns, nsExclude)
+			page, err := common.NewPageRequest(req.GetInt("limit", 0), req.GetString("cursor", ""), req.GetString("continue", ""))
 			if err != nil {
-				// If Kyverno (PolicyReport CRDs) is not installed, provide Helm installation instructions instead
-				if errors.Is(err, errNoPolicyReportCRD) {
-					return mcp.NewToolResultText(kyvernoHelmInstructions()), nil
-				}
-				return mcp.NewToolResultError(err.Error()), nil
+				return invalidArgument("%v", err), nil
+			}
+
+			reportSelector := strings.TrimSpace(req.GetString("reportSelector", ""))
+			if _, err := labels.Parse(reportSelector); err != nil {
+				return invalidArgument("invalid reportSelector: %v", err), nil
+			}
+
+			since, err := parseSince(req.GetString("since", ""), time.Now())
+			if err != nil {
+				return invalidArgument("%v", err), nil
+			}
+
+			violationsJSON, err := gatherViolationsJSON(ctx, violationsOptions{
+				namespaces:    namespaces,
+				filter:        filter,
+				groupBy:       groupBy,
+				page:          page,
+				policy:        strings.TrimSpace(req.GetString("policy", "")),
+				rule:          strings.TrimSpace(req.GetString("rule", "")),
+				kind:          strings.TrimSpace(req.GetString("kind", "")),
+				resourceName:  strings.TrimSpace(req.GetString("resourceName", "")),
+				sortBy:        sortBy,
+				summary:       req.GetBool("summary", false),
+				includePass:   req.GetBool("includePass", false),
+				selector:      reportSelector,
+				since:         since,
+				resolveOwners: req.GetBool("resolveOwners", false),
+				sources:       parseSources(req.GetString("source", defaultResultSource)),
+			})
+			if err != nil {
+				// If Kyverno (PolicyReport CRDs) is not installed, the error hint carries the Helm installation instructions
+				return errorResult(err), nil
 			}
 
 			return mcp.NewToolResultText(string(violationsJSON)), nil
 		})
 }
 
+// violationsOptions holds the arguments of a single show_violations invocation.
+type violationsOptions struct {
+	namespaces    common.NamespaceScope
+	filter        resultFilter
+	groupBy       string
+	page          common.PageRequest
+	policy        string
+	rule          string
+	kind          string
+	resourceName  string
+	sortBy        string
+	summary       bool
+	includePass   bool
+	selector      string
+	since         time.Time
+	resolveOwners bool
+	// sources holds the lower-cased result sources to include; nil includes every source.
+	sources map[string]struct{}
+}
+
+// defaultResultSource is the result source show_violations returns when none is requested.
+const defaultResultSource = "kyverno"
+
+// parseSources parses the source tool argument. An empty value selects the default source and
+// "all" selects every source, which is represented by a nil set.
+func parseSources(source string) map[string]struct{} {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		source = defaultResultSource
+	}
+	if strings.EqualFold(source, common.AllNamespaces) {
+		return nil
+	}
+	sources := map[string]struct{}{}
+	for s := range common.ParseCommaSeparated(source) {
+		sources[strings.ToLower(s)] = struct{}{}
+	}
+	return sources
+}
+
+// parseSince parses the since tool argument, either an RFC 3339 timestamp or a duration
+// subtracted from now. An empty value yields the zero time, which disables the filter.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	since = strings.TrimSpace(since)
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid since %q: must be an RFC 3339 timestamp or a positive duration such as 24h", since)
+	}
+	return now.Add(-d), nil
+}
+
+// matchesSource reports whether a result source is selected by opts.
+func (o violationsOptions) matchesSource(source string) bool {
+	if o.sources == nil {
+		return true
+	}
+	_, ok := o.sources[strings.ToLower(source)]
+	return ok
+}
+
 // gatherViolationsJSON fetches PolicyReport and ClusterPolicyReport resources and returns a JSON
-// array containing only failing and error reports with relevant violation details.
+// array containing only failing and error reports with relevant violation details. In summary
+// mode it returns the result counts of the reports instead.
 // It uses Kyverno's BuildPolicyReportResults helper to convert PolicyReports into a consistent format.
-func gatherViolationsJSON(ctx context.Context, ns, nsExclude string) ([]byte, error) {
+func gatherViolationsJSON(ctx context.Context, opts violationsOptions) ([]byte, error) {
 	// ViolationDetails represents a simplified, serializable policy violation.
 	type ViolationDetails struct {
 		Policy    string           `json:"policy"`
+		Namespace string           `json:"namespace,omitempty"`
 		Rule      string           `json:"rule,omitempty"`
 		Message   string           `json:"message"`
 		Category  string           `json:"category,omitempty"`
@@ -71,17 +239,19 @@ func gatherViolationsJSON(ctx context.Context, ns, nsExclude string) ([]byte, er
 		Timestamp metav1.Timestamp `json:"timestamp,omitempty"`
 		Result    string           `json:"result"`
 		Resources []string         `json:"resources,omitempty"`
-	}
+		// Workload is the top-level controller of the first resource, e.g. the Deployment of a Pod.
+		Workload string `json:"workload,omitempty"`
+		// DocsURL links to documentation of the violated policy.
+		DocsURL string `json:"docsUrl,omitempty"`
 
-	cfg, err := common.KubeConfig()
-	if err != nil {
-		return nil, fmt.Errorf("build kube-config: %w", err)
+		subjects []corev1.ObjectReference
 	}
 
-	disc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	clients, err := common.Clients(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("build kube-config: %w", err)
 	}
+	disc, dyn := clients.Discovery, clients.Dynamic
 
 	// Discover the GVRs for PolicyReport / ClusterPolicyReport
 	polrGVR, cpolrGVR, err := policyReportGVRs(disc)
@@ -89,154 +259,252 @@ func gatherViolationsJSON(ctx context.Context, ns, nsExclude string) ([]byte, er
 		return nil, err
 	}
 
-	dyn, err := dynamic.NewForConfig(cfg)
-	if err != nil {
-		return nil, err
+	var allViolations []ViolationDetails
+	counts := newCountsBreakdown()
+
+	// includeResult reports whether a report result matches the status and filters requested by the caller
+	includeResult := func(result policyreportv1alpha2.PolicyReportResult, subjects []corev1.ObjectReference) bool {
+		// Only include fail, error, and warn results, plus pass results when requested
+		switch result.Result {
+		case policyreportv1alpha2.StatusFail, policyreportv1alpha2.StatusError, policyreportv1alpha2.StatusWarn:
+		case policyreportv1alpha2.StatusPass:
+			if !opts.includePass {
+				return false
+			}
+		default:
+			return false
+		}
+
+		// Only include results produced by the requested policy engines
+		if !opts.matchesSource(result.Source) {
+			return false
+		}
+
+		// Only include results recorded after the requested time
+		if !opts.since.IsZero() && result.Timestamp.Seconds <= opts.since.Unix() {
+			return false
+		}
+
+		// Apply the severity and category filters requested by the caller
+		if !opts.filter.matches(result.Severity, result.Category) {
+			return false
+		}
+
+		// Narrow down to a single policy and/or rule
+		if opts.policy != "" && result.Policy != opts.policy {
+			return false
+		}
+		if opts.rule != "" && result.Rule != opts.rule {
+			return false
+		}
+
+		// Narrow down to a resource kind and/or name, matched against the result subjects
+		if opts.kind != "" || opts.resourceName != "" {
+			found := false
+			for _, r := range subjects {
+				if (opts.kind == "" || strings.EqualFold(r.Kind, opts.kind)) &&
+					(opts.resourceName == "" || r.Name == opts.resourceName) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	// resultSubjects returns the resources a result applies to. Per-resource reports written by
+	// Kyverno 1.10+ record the subject in the report scope rather than in each result.
+	resultSubjects := func(result policyreportv1alpha2.PolicyReportResult, scope *corev1.ObjectReference) []corev1.ObjectReference {
+		if len(result.Resources) == 0 && scope != nil {
+			return []corev1.ObjectReference{*scope}
+		}
+		return result.Resources
+	}
+
+	// Results can appear both in an ephemeral report and in the aggregated report it is merged
+	// into; seen records the violations already collected so each is returned only once.
+	seen := map[string]struct{}{}
+
+	// addResults collects the results of a single report that match the caller's filters
+	addResults := func(namespace string, results []policyreportv1alpha2.PolicyReportResult, scope *corev1.ObjectReference) {
+		for _, result := range results {
+			subjects := resultSubjects(result, scope)
+			if !includeResult(result, subjects) {
+				continue
+			}
+
+			// Format resource identifiers
+			var resources []string
+			for _, r := range subjects {
+				resources = append(resources, resourceIdentifier(r))
+			}
+
+			key := strings.Join([]string{namespace, result.Policy, result.Rule, string(result.Result), strings.Join(resources, ","), result.Message}, "|")
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			allViolations = append(allViolations, ViolationDetails{
+				Policy:    result.Policy,
+				Namespace: namespace,
+				Rule:      result.Rule,
+				Message:   result.Message,
+				Category:  result.Category,
+				Severity:  string(result.Severity),
+				Timestamp: result.Timestamp,
+				Result:    string(result.Result),
+				Resources: resources,
+				subjects:  subjects,
+			})
+		}
 	}
 
-	if ns == "" {
-		ns = "default"
+	// reportMatchesSource reports whether a report was written by one of the requested policy
+	// engines. Report summaries cannot be split by source, so in summary mode whole reports are
+	// counted when any of their results has a requested source.
+	reportMatchesSource := func(results []policyreportv1alpha2.PolicyReportResult) bool {
+		if opts.sources == nil {
+			return true
+		}
+		for _, result := range results {
+			if opts.matchesSource(result.Source) {
+				return true
+			}
+		}
+		return false
 	}
 
-	// Determine if we should apply namespace exclude filtering
-	// Only apply exclude filtering when querying "all" namespaces
-	queryAllNamespaces := ns == "all"
-	var excludeSet map[string]struct{}
-	if queryAllNamespaces {
-		excludeSet = common.ParseNamespaceExcludes(nsExclude)
+	// droppedFields records, per report API version, the result fields decodePolicyReport could
+	// not convert, so that the caller is told about them
+	droppedFields := map[string]map[string]struct{}{}
+	decodeReport := func(u unstructured.Unstructured) (policyreportv1alpha2.PolicyReport, error) {
+		pr, dropped, err := decodePolicyReport(u)
+		for _, field := range dropped {
+			if droppedFields[u.GetAPIVersion()] == nil {
+				droppedFields[u.GetAPIVersion()] = map[string]struct{}{}
+			}
+			droppedFields[u.GetAPIVersion()][field] = struct{}{}
+		}
+		return pr, err
 	}
 
-	var allViolations []ViolationDetails
+	// hasRelevantResults reports whether a report summary may contain results the caller asked for
+	hasRelevantResults := func(summary policyreportv1alpha2.PolicyReportSummary) bool {
+		return opts.includePass || summary.Fail > 0 || summary.Error > 0 || summary.Warn > 0
+	}
 
 	// Helper function to process PolicyReport items
-	addPolicyReportResults := func(items []unstructured.Unstructured) error {
+	addPolicyReportResults := func(items []unstructured.Unstructured) {
 		for _, u := range items {
-			// Skip if namespace is excluded (only when querying all namespaces)
-			if queryAllNamespaces {
-				if _, skip := excludeSet[u.GetNamespace()]; skip {
-					continue
-				}
+			// Skip reports outside the requested namespaces (e.g. excluded when querying all namespaces)
+			if !opts.namespaces.Includes(u.GetNamespace()) {
+				continue
 			}
 
 			// Convert unstructured to typed PolicyReport
-			var pr policyreportv1alpha2.PolicyReport
-			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &pr); err != nil {
+			pr, err := decodeReport(u)
+			if err != nil {
 				klog.ErrorS(err, "failed to convert to PolicyReport", "name", u.GetName(), "namespace", u.GetNamespace())
 				continue
 			}
 
-			// Skip reports with no failures, errors, or warnings
-			if pr.Summary.Fail == 0 && pr.Summary.Error == 0 && pr.Summary.Warn == 0 {
+			// In summary mode only the report counts are needed
+			if opts.summary {
+				if reportMatchesSource(pr.Results) {
+					counts.addReport(u.GetNamespace(), pr.Summary, pr.Results)
+				}
 				continue
 			}
 
-			// Extract relevant results from PolicyReport
-			for _, result := range pr.Results {
-				// Only include fail, error, and warn results
-				if result.Result != policyreportv1alpha2.StatusFail &&
-					result.Result != policyreportv1alpha2.StatusError &&
-					result.Result != policyreportv1alpha2.StatusWarn {
-					continue
-				}
-
-				// Format resource identifiers
-				var resources []string
-				for _, r := range result.Resources {
-					var resourceIdentifier string
-					if r.Namespace != "" {
-						resourceIdentifier = fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
-					} else {
-						resourceIdentifier = fmt.Sprintf("%s/%s", r.Kind, r.Name)
-					}
-					resources = append(resources, resourceIdentifier)
-				}
-
-				allViolations = append(allViolations, ViolationDetails{
-					Policy:    result.Policy,
-					Rule:      result.Rule,
-					Message:   result.Message,
-					Category:  result.Category,
-					Severity:  string(result.Severity),
-					Timestamp: result.Timestamp,
-					Result:    string(result.Result),
-					Resources: resources,
-				})
+			// Skip reports with no failures, errors, or warnings unless passing results were requested
+			if !hasRelevantResults(pr.Summary) {
+				continue
 			}
+			addResults(u.GetNamespace(), pr.Results, pr.Scope)
 		}
-		return nil
 	}
 
 	// Helper function to process ClusterPolicyReport items
-	addClusterPolicyReportResults := func(items []unstructured.Unstructured) error {
+	addClusterPolicyReportResults := func(items []unstructured.Unstructured) {
 		for _, u := range items {
-			// Convert unstructured to typed ClusterPolicyReport
-			var cpr policyreportv1alpha2.ClusterPolicyReport
-			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &cpr); err != nil {
+			// Convert unstructured to typed ClusterPolicyReport, which has the fields of a PolicyReport
+			cpr, err := decodeReport(u)
+			if err != nil {
 				klog.ErrorS(err, "failed to convert to ClusterPolicyReport", "name", u.GetName())
 				continue
 			}
 
-			// Skip reports with no failures, errors, or warnings
-			if cpr.Summary.Fail == 0 && cpr.Summary.Error == 0 && cpr.Summary.Warn == 0 {
+			// In summary mode only the report counts are needed
+			if opts.summary {
+				if reportMatchesSource(cpr.Results) {
+					counts.addReport("", cpr.Summary, cpr.Results)
+				}
 				continue
 			}
 
-			// Extract relevant results from ClusterPolicyReport
-			for _, result := range cpr.Results {
-				// Only include fail, error, and warn results
-				if result.Result != policyreportv1alpha2.StatusFail &&
-					result.Result != policyreportv1alpha2.StatusError &&
-					result.Result != policyreportv1alpha2.StatusWarn {
-					continue
-				}
+			// Skip reports with no failures, errors, or warnings unless passing results were requested
+			if !hasRelevantResults(cpr.Summary) {
+				continue
+			}
+			addResults("", cpr.Results, cpr.Scope)
+		}
+	}
 
-				// Format resource identifiers
-				var resources []string
-				for _, r := range result.Resources {
-					var resourceIdentifier string
-					if r.Namespace != "" {
-						resourceIdentifier = fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
-					} else {
-						resourceIdentifier = fmt.Sprintf("%s/%s", r.Kind, r.Name)
-					}
-					resources = append(resources, resourceIdentifier)
-				}
+	// Helper function to process EphemeralReport and ClusterEphemeralReport items. Both kinds
+	// share the same spec; cluster-scoped ones simply have no namespace.
+	addEphemeralReportResults := func(items []unstructured.Unstructured) {
+		for _, u := range items {
+			if !opts.namespaces.Includes(u.GetNamespace()) {
+				continue
+			}
 
-				allViolations = append(allViolations, ViolationDetails{
-					Policy:    result.Policy,
-					Rule:      result.Rule,
-					Message:   result.Message,
-					Category:  result.Category,
-					Severity:  string(result.Severity),
-					Timestamp: result.Timestamp,
-					Result:    string(result.Result),
-					Resources: resources,
-				})
+			var er reportsv1.EphemeralReport
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &er); err != nil {
+				klog.ErrorS(err, "failed to convert to EphemeralReport", "name", u.GetName(), "namespace", u.GetNamespace())
+				continue
 			}
+			if !hasRelevantResults(er.Spec.Summary) {
+				continue
+			}
+
+			// Ephemeral reports identify their subject through the owner reference
+			owner := er.Spec.Owner
+			scope := &corev1.ObjectReference{
+				APIVersion: owner.APIVersion,
+				Kind:       owner.Kind,
+				Namespace:  u.GetNamespace(),
+				Name:       owner.Name,
+				UID:        owner.UID,
+			}
+			addResults(u.GetNamespace(), er.Spec.Results, scope)
 		}
-		return nil
 	}
 
 	// ---------------------------------------------------------------------
 	// 1. Namespaced PolicyReports
 	// ---------------------------------------------------------------------
 	if polrGVR.Resource != "" {
-		var prList *unstructured.UnstructuredList
-		var err error
-
-		if queryAllNamespaces {
+		if opts.namespaces.All {
 			// Query all namespaces
-			prList, err = dyn.Resource(polrGVR).List(ctx, metav1.ListOptions{})
-		} else {
-			// Query specific namespace
-			prList, err = dyn.Resource(polrGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
-		}
-
-		if err != nil {
-			klog.ErrorS(err, "cannot list namespaced PolicyReports")
+			items, err := listPaged(ctx, dyn.Resource(polrGVR), opts.selector)
+			if err != nil {
+				klog.ErrorS(err, "cannot list namespaced PolicyReports")
+			} else {
+				addPolicyReportResults(items)
+			}
 		} else {
-			if err := addPolicyReportResults(prList.Items); err != nil {
-				return nil, err
+			// Query each requested namespace
+			for _, ns := range opts.namespaces.Namespaces {
+				items, err := listPaged(ctx, dyn.Resource(polrGVR).Namespace(ns), opts.selector)
+				if err != nil {
+					klog.ErrorS(err, "cannot list namespaced PolicyReports", "namespace", ns)
+					continue
+				}
+				addPolicyReportResults(items)
 			}
 		}
 	}
@@ -244,16 +512,166 @@ func gatherViolationsJSON(ctx context.Context, ns, nsExclude string) ([]byte, er
 	// ---------------------------------------------------------------------
 	// 2. Cluster-scoped ClusterPolicyReports
 	// ---------------------------------------------------------------------
-	if cpolrGVR.Resource != "" {
-		cprList, err := dyn.Resource(cpolrGVR).List(ctx, metav1.ListOptions{})
+	if cpolrGVR.Resource != "" && common.NamespaceAllowed("") {
+		items, err := listPaged(ctx, dyn.Resource(cpolrGVR), opts.selector)
 		if err != nil {
 			klog.ErrorS(err, "cannot list ClusterPolicyReports")
 		} else {
-			if err := addClusterPolicyReportResults(cprList.Items); err != nil {
-				return nil, err
+			addClusterPolicyReportResults(items)
+		}
+	}
+
+	// ---------------------------------------------------------------------
+	// 3. Ephemeral reports not yet aggregated into policy reports
+	// ---------------------------------------------------------------------
+	// Summary mode relies on the aggregated report summaries only, as counting ephemeral
+	// reports as well would count most results twice.
+	if !opts.summary {
+		ephrGVR, cephrGVR := ephemeralReportGVRs(disc)
+		if ephrGVR.Resource != "" {
+			if opts.namespaces.All {
+				items, err := listPaged(ctx, dyn.Resource(ephrGVR), opts.selector)
+				if err != nil {
+					klog.ErrorS(err, "cannot list EphemeralReports")
+				} else {
+					addEphemeralReportResults(items)
+				}
+			} else {
+				for _, ns := range opts.namespaces.Namespaces {
+					items, err := listPaged(ctx, dyn.Resource(ephrGVR).Namespace(ns), opts.selector)
+					if err != nil {
+						klog.ErrorS(err, "cannot list EphemeralReports", "namespace", ns)
+						continue
+					}
+					addEphemeralReportResults(items)
+				}
+			}
+		}
+		if cephrGVR.Resource != "" && common.NamespaceAllowed("") {
+			items, err := listPaged(ctx, dyn.Resource(cephrGVR), opts.selector)
+			if err != nil {
+				klog.ErrorS(err, "cannot list ClusterEphemeralReports")
+			} else {
+				addEphemeralReportResults(items)
+			}
+		}
+	}
+
+	var warnings []string
+	for _, version := range slices.Sorted(maps.Keys(droppedFields)) {
+		warnings = append(warnings, fmt.Sprintf("%s report results have fields without a counterpart in %s, which are not returned: %s", version, policyreportv1alpha2.SchemeGroupVersion, strings.Join(slices.Sorted(maps.Keys(droppedFields[version])), ", ")))
+	}
+
+	if opts.summary {
+		counts.Warnings = append(counts.Warnings, warnings...)
+		return json.MarshalIndent(counts, "", "  ")
+	}
+
+	firstResource := func(v ViolationDetails) string {
+		if len(v.Resources) > 0 {
+			return v.Resources[0]
+		}
+		return ""
+	}
+
+	// Order violations by namespace, resource, policy, rule and message so that pagination
+	// cursors remain stable regardless of the order reports are returned by the API server.
+	sort.SliceStable(allViolations, func(i, j int) bool {
+		a, b := allViolations[i], allViolations[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if ra, rb := firstResource(a), firstResource(b); ra != rb {
+			return ra < rb
+		}
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+
+	sortAcc := sortAccessors[ViolationDetails]{
+		countKey:  func(v ViolationDetails) string { return v.Policy },
+		severity:  func(v ViolationDetails) string { return v.Severity },
+		timestamp: func(v ViolationDetails) int64 { return v.Timestamp.Seconds },
+	}
+	if opts.groupBy == "" {
+		sortResults(allViolations, opts.sortBy, sortAcc)
+	}
+
+	total := len(allViolations)
+	metrics.ObserveResults("show_violations", total)
+	var nextCursor string
+	if opts.page.Enabled() {
+		allViolations, nextCursor = common.Paginate(allViolations, opts.page)
+	}
+
+	// Attach documentation links of the violated policies
+	policies := map[string]struct{}{}
+	for _, v := range allViolations {
+		policies[v.Policy] = struct{}{}
+	}
+	docsURLs := policyDocsURLs(ctx, dyn, policies)
+	for i, v := range allViolations {
+		allViolations[i].DocsURL = docsURLs[v.Policy]
+	}
+
+	// Attach the owning workload of each violating resource, after pagination so that only the
+	// returned violations cost API calls
+	if opts.resolveOwners {
+		owners := newOwnerResolver(clients.Mapper, dyn)
+		for i, v := range allViolations {
+			if len(v.subjects) == 0 {
+				continue
 			}
+			if owner, ok := owners.resolve(ctx, v.subjects[0]); ok {
+				allViolations[i].Workload = resourceIdentifier(owner)
+			}
+		}
+		if owners.limited > 0 {
+			warnings = append(warnings, fmt.Sprintf("the workloads of %d violations were not resolved because the call reached the limit of %d owner lookups; narrow the call down with limit or filters", owners.limited, maxOwnerLookups))
+		}
+	}
+
+	// Violations beyond MaxResultBytes are dropped, switching a plain array or groups to an
+	// envelope that records the truncation. Groups are marshalled as a whole, so violations are
+	// cut down to what fits before grouping.
+	fit, err := fitResults(allViolations)
+	if err != nil {
+		return nil, err
+	}
+	if opts.groupBy == "" && (opts.page.Enabled() || fit < len(allViolations) || len(warnings) > 0) {
+		out, err := encodeEnvelope(resultsEnvelope{Total: total, NextCursor: nextCursor, Warnings: warnings}, allViolations, opts.page.Offset)
+		return []byte(out), err
+	}
+	envelope := resultsEnvelope{Total: total, NextCursor: nextCursor, Warnings: warnings}
+	var output any = allViolations
+	if opts.groupBy != "" {
+		if fit < len(allViolations) {
+			truncateResults(&envelope, fit, len(allViolations), opts.page.Offset)
+			allViolations = allViolations[:fit]
+		}
+		groups := groupResults(allViolations, func(v ViolationDetails) string {
+			resource := firstResource(v)
+			kind, _, _ := strings.Cut(resource, "/")
+			return groupKey(opts.groupBy, groupFields{policy: v.Policy, resource: resource, namespace: v.Namespace, kind: kind, severity: v.Severity})
+		})
+		if opts.sortBy != "" {
+			output = sortedGroups(groups, opts.sortBy, sortAcc)
+		} else {
+			output = groups
 		}
 	}
+	if opts.page.Enabled() || envelope.Truncated || len(envelope.Warnings) > 0 {
+		envelope.Results = output
+		return json.MarshalIndent(envelope, "", "  ")
+	}
+	if opts.groupBy != "" {
+		return json.MarshalIndent(output, "", "  ")
+	}
 
 	if len(allViolations) == 0 {
 		return []byte("[]"), nil
@@ -261,14 +679,77 @@ func gatherViolationsJSON(ctx context.Context, ns, nsExclude string) ([]byte, er
 	return json.MarshalIndent(allViolations, "", "  ")
 }
 
-// policyReportGVRs discovers policyreports / clusterpolicyreports
-func policyReportGVRs(disc discovery.DiscoveryInterface) (schema.GroupVersionResource, schema.GroupVersionResource, error) {
+// ListPageSize bounds the number of objects fetched from the API server per list request. It is
+// set from the --list-page-size flag.
+var ListPageSize int64 = 500
+
+// listPaged lists all objects of a resource matching the label selector in chunks of
+// ListPageSize, so that very large collections are not returned by the API server in a single
+// response.
+func listPaged(ctx context.Context, ri dynamic.ResourceInterface, selector string) ([]unstructured.Unstructured, error) {
+	return listPagedWith(ctx, ri, metav1.ListOptions{LabelSelector: selector})
+}
+
+// listPagedWith is like listPaged, with the label and field selectors of opts.
+func listPagedWith(ctx context.Context, ri dynamic.ResourceInterface, opts metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	var items []unstructured.Unstructured
+	opts.Limit = ListPageSize
+	for {
+		list, err := ri.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+		if list.GetContinue() == "" {
+			return items, nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
+
+// ephemeralReportGVRs discovers ephemeralreports / clusterephemeralreports, which Kyverno 1.11+
+// writes before aggregating results into policy reports. Empty GVRs are returned for the kinds
+// the cluster does not serve.
+func ephemeralReportGVRs(disc discovery.DiscoveryInterface) (schema.GroupVersionResource, schema.GroupVersionResource) {
+	const group, version = "reports.kyverno.io", "v1"
+	var ephr, cephr schema.GroupVersionResource
+	resList, err := disc.ServerResourcesForGroupVersion(group + "/" + version)
+	if err != nil {
+		return ephr, cephr
+	}
+	for _, r := range resList.APIResources {
+		switch r.Name {
+		case "ephemeralreports":
+			ephr = schema.GroupVersionResource{Group: group, Version: version, Resource: r.Name}
+		case "clusterephemeralreports":
+			cephr = schema.GroupVersionResource{Group: group, Version: version, Resource: r.Name}
+		}
+	}
+	return ephr, cephr
+}
+
+// policyReportGVRs discovers policyreports / clusterpolicyreports. When the cluster serves several
+// versions, the first one listed in policyReportVersions is used; versions the tool does not know
+// about are only used as a last resort. If the cached discovery information has no policy
+// reports, it is refreshed once in case Kyverno was installed since it was fetched.
+func policyReportGVRs(disc discovery.CachedDiscoveryInterface) (schema.GroupVersionResource, schema.GroupVersionResource, error) {
+	polr, cpolr, err := servedPolicyReportGVRs(disc)
+	if errors.Is(err, errNoPolicyReportCRD) {
+		disc.Invalidate()
+		return servedPolicyReportGVRs(disc)
+	}
+	return polr, cpolr, err
+}
+
+func servedPolicyReportGVRs(disc discovery.DiscoveryInterface) (schema.GroupVersionResource, schema.GroupVersionResource, error) {
 	const group = "wgpolicyk8s.io"
 	grps, err := disc.ServerGroups()
 	if err != nil {
 		return schema.GroupVersionResource{}, schema.GroupVersionResource{}, err
 	}
 
+	// Collect the served versions that provide policy reports
+	var served []string
 	for _, g := range grps.Groups {
 		if g.Name != group {
 			continue
@@ -280,14 +761,28 @@ func policyReportGVRs(disc discovery.DiscoveryInterface) (schema.GroupVersionRes
 			}
 			for _, r := range resList.APIResources {
 				if r.Name == "policyreports" {
-					polr := schema.GroupVersionResource{Group: group, Version: v.Version, Resource: "policyreports"}
-					cpolr := schema.GroupVersionResource{Group: group, Version: v.Version, Resource: "clusterpolicyreports"}
-					return polr, cpolr, nil
+					served = append(served, v.Version)
+					break
 				}
 			}
 		}
 	}
-	return schema.GroupVersionResource{}, schema.GroupVersionResource{}, errNoPolicyReportCRD
+	if len(served) == 0 {
+		return schema.GroupVersionResource{}, schema.GroupVersionResource{}, errNoPolicyReportCRD
+	}
+
+	version := served[0]
+	for _, preferred := range policyReportVersions {
+		if slices.Contains(served, preferred) {
+			version = preferred
+			break
+		}
+	}
+	klog.V(2).InfoS("using PolicyReport API version", "group", group, "version", version, "served", served)
+
+	polr := schema.GroupVersionResource{Group: group, Version: version, Resource: "policyreports"}
+	cpolr := schema.GroupVersionResource{Group: group, Version: version, Resource: "clusterpolicyreports"}
+	return polr, cpolr, nil
 }
 
 // kyvernoHelmInstructions returns user-friendly instructions to install Kyverno via Helm.
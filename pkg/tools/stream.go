@@ -0,0 +1,98 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+)
+
+// MaxResultBytes caps the size of the results returned by a single apply_policies or
+// show_violations call. It is set from the --max-result-bytes flag.
+var MaxResultBytes = 8 << 20
+
+// resultsPlaceholder stands in for the results while the rest of an envelope is marshalled.
+const resultsPlaceholder = "__results__"
+
+// fitResults returns how many of the leading results fit in MaxResultBytes once encoded. Results
+// are encoded one at a time, so only a single one is held in memory.
+func fitResults[T any](results []T) (int, error) {
+	fit, _, err := fitWithin(results, MaxResultBytes)
+	return fit, err
+}
+
+// fitWithin returns how many of the leading items fit in budget bytes once encoded, together
+// with their encoded size.
+func fitWithin[T any](items []T, budget int) (int, int, error) {
+	size := 0
+	for i, r := range items {
+		item, err := json.Marshal(r)
+		if err != nil {
+			return 0, 0, err
+		}
+		if size+len(item)+6 > budget {
+			return i, size, nil
+		}
+		size += len(item) + 6
+	}
+	return len(items), size, nil
+}
+
+// truncateResults records on envelope that only kept of total results starting at offset were
+// returned, pointing NextCursor at the first dropped one.
+func truncateResults(envelope *resultsEnvelope, kept, total, offset int) {
+	envelope.Truncated = true
+	envelope.NextCursor = common.CursorAt(offset + kept)
+	envelope.Warnings = append(envelope.Warnings, fmt.Sprintf("results truncated to %d of %d because the response exceeded %d bytes; fetch the rest with cursor set to nextCursor, or narrow the call down with limit or filters", kept, total, MaxResultBytes))
+}
+
+// encodeEnvelope marshals envelope with results as its results array. The results are encoded
+// one at a time, one per line, rather than marshalling the whole envelope in one go, which keeps
+// memory close to the size of the output on very large scans. Results that would take the output
+// beyond MaxResultBytes are dropped; offset is the position of the first result in the full
+// result set, used to point NextCursor at the first dropped result.
+func encodeEnvelope[T any](envelope resultsEnvelope, results []T, offset int) (string, error) {
+	var body bytes.Buffer
+	body.WriteString("[")
+	kept := 0
+	for _, r := range results {
+		item, err := json.Marshal(r)
+		if err != nil {
+			return "", err
+		}
+		if body.Len()+len(item)+6 > MaxResultBytes {
+			break
+		}
+		if kept > 0 {
+			body.WriteString(",")
+		}
+		body.WriteString("\n    ")
+		body.Write(item)
+		kept++
+	}
+	if kept > 0 {
+		body.WriteString("\n  ")
+	}
+	body.WriteString("]")
+	if kept < len(results) {
+		truncateResults(&envelope, kept, len(results), offset)
+	}
+
+	envelope.Results = resultsPlaceholder
+	head, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	before, after, _ := bytes.Cut(head, []byte(strconv.Quote(resultsPlaceholder)))
+
+	var out strings.Builder
+	out.Grow(len(before) + body.Len() + len(after))
+	out.Write(before)
+	out.Write(body.Bytes())
+	out.Write(after)
+	return out.String(), nil
+}
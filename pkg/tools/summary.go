@@ -0,0 +1,149 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+)
+
+// resultsEnvelope wraps tool results together with pagination and summary metadata.
+type resultsEnvelope struct {
+	Summary    any    `json:"summary,omitempty"`
+	Results    any    `json:"results"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Truncated is set when results were dropped to keep the response under MaxResultBytes.
+	Truncated bool `json:"truncated,omitempty"`
+	// Warnings lists problems that did not prevent the call from completing.
+	Warnings []string `json:"warnings,omitempty"`
+	// Mutations previews the changes mutate rules would make, when requested.
+	Mutations any `json:"mutations,omitempty"`
+	// Generated previews the resources generate rules would create, when requested.
+	Generated any `json:"generated,omitempty"`
+	// Timings breaks down the evaluation time per policy and rule, when requested.
+	Timings any `json:"timings,omitempty"`
+	// SkippedPolicies lists the policies that were not applied because they failed validation.
+	SkippedPolicies []skippedPolicy `json:"skippedPolicies,omitempty"`
+}
+
+// skippedPolicy names a policy that was not applied because it failed validation.
+type skippedPolicy struct {
+	Policy string `json:"policy"`
+	Reason string `json:"reason"`
+}
+
+// scanSummary describes a completed apply_policies scan.
+type scanSummary struct {
+	resultSummary    `json:",inline"`
+	ResourcesScanned int    `json:"resourcesScanned"`
+	PoliciesApplied  int    `json:"policiesApplied"`
+	RulesApplied     int    `json:"rulesApplied"`
+	Exempted         int    `json:"exempted"`
+	Duration         string `json:"duration"`
+	// Engine holds the result counts reported by the Kyverno engine, before the namespace,
+	// controller, selector, severity and category filters and regardless of includePassing.
+	Engine *resultSummary `json:"engine,omitempty"`
+	// CachedAt is the time of the scan the results come from, when a recent scan was reused.
+	CachedAt string `json:"cachedAt,omitempty"`
+	// Incremental describes the reuse of the previous scan, for incremental scans.
+	Incremental *incrementalSummary `json:"incremental,omitempty"`
+	// Sampling describes the sample and extrapolates its counts, for sampled scans.
+	Sampling *samplingSummary `json:"sampling,omitempty"`
+	// ResourceLimit is the limit of resources per scan of the server, set when the scan reached
+	// it and did not evaluate every resource.
+	ResourceLimit int `json:"resourceLimit,omitempty"`
+}
+
+// resultSummary counts results by status.
+type resultSummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+// add records a single result with the given status.
+func (s *resultSummary) add(status policyreportv1alpha2.PolicyResult) {
+	switch status {
+	case policyreportv1alpha2.StatusPass:
+		s.Pass++
+	case policyreportv1alpha2.StatusFail:
+		s.Fail++
+	case policyreportv1alpha2.StatusWarn:
+		s.Warn++
+	case policyreportv1alpha2.StatusError:
+		s.Error++
+	case policyreportv1alpha2.StatusSkip:
+		s.Skip++
+	}
+}
+
+// addSummary adds the counts of a policy report summary.
+func (s *resultSummary) addSummary(summary policyreportv1alpha2.PolicyReportSummary) {
+	s.Pass += summary.Pass
+	s.Fail += summary.Fail
+	s.Warn += summary.Warn
+	s.Error += summary.Error
+	s.Skip += summary.Skip
+}
+
+// countsBreakdown holds result counts overall and broken down by policy and by namespace.
+type countsBreakdown struct {
+	Totals      resultSummary            `json:"totals"`
+	ByPolicy    map[string]resultSummary `json:"byPolicy"`
+	ByNamespace map[string]resultSummary `json:"byNamespace"`
+	// Sampling describes the sample and extrapolates its counts, for sampled scans.
+	Sampling *samplingSummary `json:"sampling,omitempty"`
+	// ResourceLimit is the limit of resources per scan of the server, set when the scan reached it.
+	ResourceLimit int `json:"resourceLimit,omitempty"`
+	// Warnings lists problems that did not prevent the counts from being computed, such as
+	// namespaces that could not be scanned.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// newCountsBreakdown returns an empty countsBreakdown ready for use.
+func newCountsBreakdown() *countsBreakdown {
+	return &countsBreakdown{
+		ByPolicy:    map[string]resultSummary{},
+		ByNamespace: map[string]resultSummary{},
+	}
+}
+
+// clusterScopedKey is the namespace key under which cluster-scoped results are counted.
+const clusterScopedKey = "cluster-scoped"
+
+// add records a single result for the given policy and namespace. Cluster-scoped results are
+// counted under the "cluster-scoped" namespace key.
+func (b *countsBreakdown) add(policy, namespace string, status policyreportv1alpha2.PolicyResult) {
+	if namespace == "" {
+		namespace = clusterScopedKey
+	}
+	b.Totals.add(status)
+
+	p := b.ByPolicy[policy]
+	p.add(status)
+	b.ByPolicy[policy] = p
+
+	n := b.ByNamespace[namespace]
+	n.add(status)
+	b.ByNamespace[namespace] = n
+}
+
+// addReport records the counts of a whole policy report. Totals and namespace counts are taken
+// from the report summary; policy counts are tallied from the report results.
+func (b *countsBreakdown) addReport(namespace string, summary policyreportv1alpha2.PolicyReportSummary, results []policyreportv1alpha2.PolicyReportResult) {
+	if namespace == "" {
+		namespace = clusterScopedKey
+	}
+	b.Totals.addSummary(summary)
+
+	n := b.ByNamespace[namespace]
+	n.addSummary(summary)
+	b.ByNamespace[namespace] = n
+
+	for _, result := range results {
+		p := b.ByPolicy[result.Policy]
+		p.add(result.Result)
+		b.ByPolicy[result.Policy] = p
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
 	"k8s.io/klog/v2"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -15,18 +17,34 @@ import (
 func SwitchContext(s *server.MCPServer) {
 	// Switch context tool
 	klog.InfoS("Registering tool: switch_context")
-	s.AddTool(mcp.NewTool("switch_context",
-		mcp.WithDescription("Switch to a different Kubernetes context. If no context is provided, the default context will be used."),
+	addWriteTool(s, mcp.NewTool("switch_context",
+		mcp.WithDescription("Switch to a different Kubernetes context. If no context is provided, the default context will be used. The change is saved to the kubeconfig, so it must be confirmed: the first call only describes the change and returns a confirmationToken, and the switch happens when the tool is called again with that token after the user agreed."),
+		// Switching rewrites current-context in the kubeconfig, which affects other kubectl users
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Switch Kubernetes context",
+			ReadOnlyHint:    mcp.ToBoolPtr(false),
+			DestructiveHint: mcp.ToBoolPtr(true),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
 		mcp.WithString("context",
 			mcp.Description("Name of the context to switch to"),
 			mcp.Required(),
 		),
-	), func(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcp.WithString("confirmationToken",
+			mcp.Description("Token returned by a previous call for the same context, confirming the user approved the switch"),
+		),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// The current context is shared by every API token, so confined tokens cannot change it
+		if _, ok := common.CredentialsOf(ctx); ok {
+			return toolError{Code: codePermissionDenied, Message: "the API token of this session is confined to its own credentials and cannot switch the kubeconfig context"}.result(), nil
+		}
+
 		// Get the context parameter
 		contextName, err := request.RequireString("context")
 		if err != nil {
 			klog.ErrorS(err, "Error in 'switch_context': Invalid context parameter")
-			return mcp.NewToolResultError(fmt.Sprintf("Invalid context parameter: %v", err)), nil
+			return invalidArgument("invalid context parameter: %v", err), nil
 		}
 
 		pathOpts := clientcmd.NewDefaultPathOptions()
@@ -34,7 +52,7 @@ func SwitchContext(s *server.MCPServer) {
 		cfg, err := pathOpts.GetStartingConfig()
 		if err != nil {
 			klog.ErrorS(err, "Error in 'switch_context': Error loading kubeconfig")
-			return mcp.NewToolResultError(fmt.Sprintf("Error loading kubeconfig: %v", err)), nil
+			return errorResult(fmt.Errorf("error loading kubeconfig: %w", err)), nil
 		}
 
 		if _, ok := cfg.Contexts[contextName]; !ok {
@@ -43,15 +61,32 @@ func SwitchContext(s *server.MCPServer) {
 				availableContexts = append(availableContexts, name)
 			}
 			klog.ErrorS(err, "Error in 'switch_context': Context '%s' not found. Available: %v", contextName, availableContexts)
-			return mcp.NewToolResultError(fmt.Sprintf("Context '%s' not found. Available contexts: %v", contextName, availableContexts)), nil
+			return notFound("call list_contexts for the available contexts", "context '%s' not found. Available contexts: %v", contextName, availableContexts), nil
+		}
+
+		action := "switch_context " + contextName
+		token := request.GetString("confirmationToken", "")
+		if token == "" {
+			if token, err = writeConfirmations.issue(action); err != nil {
+				return errorResult(fmt.Errorf("error issuing confirmation token: %w", err)), nil
+			}
+			return mcp.NewToolResultText(fmt.Sprintf("Switching from context %q to %q rewrites current-context in %s, which also affects kubectl and other tools using it. Ask the user to confirm, then call switch_context again with context %q and confirmationToken %q (valid for %s).",
+				cfg.CurrentContext, contextName, pathOpts.GetDefaultFilename(), contextName, token, confirmationTTL)), nil
+		}
+		if !writeConfirmations.redeem(token, action) {
+			return toolError{Code: codeInvalidArgument, Message: "invalid or expired confirmationToken", Hint: "call switch_context without a token to request a new one"}.result(), nil
 		}
 
 		cfg.CurrentContext = contextName
 
 		if err := clientcmd.ModifyConfig(pathOpts, *cfg, false); err != nil {
 			klog.ErrorS(err, "Error in 'switch_context': Error writing kubeconfig")
-			return mcp.NewToolResultError(fmt.Sprintf("Error writing kubeconfig: %v", err)), nil
+			return errorResult(fmt.Errorf("error writing kubeconfig: %w", err)), nil
 		}
+		// The new context may reach another cluster, or the same one with other permissions
+		common.InvalidateClients()
+		scanResults.clear()
+		incrementalBaselines.clear()
 
 		return mcp.NewToolResultText(fmt.Sprintf("Switched to context: %s (saved to kubeconfig)", contextName)), nil
 	},
@@ -0,0 +1,69 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"sort"
+	"time"
+
+	kyverno "github.com/nirmata/kyverno-mcp/pkg/kyverno-cli"
+
+	engineapi "github.com/kyverno/kyverno/pkg/engine/api"
+)
+
+// ruleTiming aggregates the time a single rule took across every resource it was evaluated on.
+type ruleTiming struct {
+	Policy      string  `json:"policy"`
+	Rule        string  `json:"rule"`
+	Evaluations int     `json:"evaluations"`
+	TotalMs     float64 `json:"totalMs"`
+	MaxMs       float64 `json:"maxMs"`
+}
+
+// timingBreakdown reports where evaluation time was spent, so slow rules can be identified before
+// they are enforced at admission.
+type timingBreakdown struct {
+	TotalMs  float64            `json:"totalMs"`
+	ByPolicy map[string]float64 `json:"byPolicy"`
+	// Rules is ordered from the slowest to the fastest rule in total.
+	Rules []ruleTiming `json:"rules"`
+}
+
+// ruleTimings aggregates the rule execution times recorded in the engine responses.
+func ruleTimings(engineResponses []engineapi.EngineResponse) timingBreakdown {
+	breakdown := timingBreakdown{ByPolicy: map[string]float64{}, Rules: []ruleTiming{}}
+	index := map[[2]string]int{}
+	for _, er := range engineResponses {
+		if er.Policy() == nil {
+			continue
+		}
+		policy := kyverno.PolicyKey(er.Policy())
+		for _, rule := range er.PolicyResponse.Rules {
+			ms := durationMs(rule.Stats().ProcessingTime())
+			breakdown.TotalMs += ms
+			breakdown.ByPolicy[policy] += ms
+
+			key := [2]string{policy, rule.Name()}
+			i, ok := index[key]
+			if !ok {
+				i = len(breakdown.Rules)
+				index[key] = i
+				breakdown.Rules = append(breakdown.Rules, ruleTiming{Policy: policy, Rule: rule.Name()})
+			}
+			t := &breakdown.Rules[i]
+			t.Evaluations++
+			t.TotalMs += ms
+			if ms > t.MaxMs {
+				t.MaxMs = ms
+			}
+		}
+	}
+	sort.SliceStable(breakdown.Rules, func(i, j int) bool {
+		return breakdown.Rules[i].TotalMs > breakdown.Rules[j].TotalMs
+	})
+	return breakdown
+}
+
+// durationMs converts a duration to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,115 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// errorCode classifies tool errors so that clients can react to them without parsing messages.
+type errorCode string
+
+const (
+	// codeInvalidArgument reports arguments that are missing, malformed or contradictory.
+	codeInvalidArgument errorCode = "invalid_argument"
+	// codeNotFound reports a referenced object, such as a context, namespace or file, that does
+	// not exist.
+	codeNotFound errorCode = "not_found"
+	// codeKyvernoNotInstalled reports a cluster without the Kyverno or PolicyReport CRDs.
+	codeKyvernoNotInstalled errorCode = "kyverno_not_installed"
+	// codeUnauthenticated reports credentials rejected by the API server.
+	codeUnauthenticated errorCode = "unauthenticated"
+	// codePermissionDenied reports a request denied by RBAC.
+	codePermissionDenied errorCode = "permission_denied"
+	// codeUnavailable reports a cluster or registry that cannot be reached or is overloaded.
+	codeUnavailable errorCode = "unavailable"
+	// codeFailedPrecondition reports a call the session or server cannot serve in its current
+	// state, such as a transport that cannot deliver notifications.
+	codeFailedPrecondition errorCode = "failed_precondition"
+	// codeTimeout reports a call that did not complete in time.
+	codeTimeout errorCode = "timeout"
+	// codeInternal reports any other failure.
+	codeInternal errorCode = "internal"
+)
+
+// toolError is the machine-readable body of a failed tool call.
+type toolError struct {
+	Code    errorCode `json:"code"`
+	Message string    `json:"message"`
+	// Hint suggests how to recover, when there is a known remedy.
+	Hint string `json:"hint,omitempty"`
+	// Retryable is set when the same call may succeed later without changes.
+	Retryable bool `json:"retryable"`
+	// Permission is the RBAC permission a denied request lacked, when the API server named it.
+	Permission *missingPermission `json:"permission,omitempty"`
+	// Manifest grants the missing Permission, ready to apply with kubectl apply -f.
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// result returns the error as a tool result flagged as an error, whose text is the JSON
+// encoded toolError.
+func (e toolError) result() *mcp.CallToolResult {
+	body, err := json.Marshal(map[string]toolError{"error": e})
+	if err != nil {
+		return mcp.NewToolResultError(e.Message)
+	}
+	return mcp.NewToolResultError(string(body))
+}
+
+// invalidArgument returns the result of a call rejected because of its arguments.
+func invalidArgument(format string, a ...any) *mcp.CallToolResult {
+	return toolError{Code: codeInvalidArgument, Message: fmt.Sprintf(format, a...), Hint: "fix the arguments and call the tool again"}.result()
+}
+
+// notFound returns the result of a call referring to something that does not exist.
+func notFound(hint, format string, a ...any) *mcp.CallToolResult {
+	return toolError{Code: codeNotFound, Message: fmt.Sprintf(format, a...), Hint: hint}.result()
+}
+
+// errorResult classifies err and returns it as a tool result.
+func errorResult(err error) *mcp.CallToolResult {
+	return classifyError(err).result()
+}
+
+// classifyError maps an error to its toolError, recognising Kubernetes API errors, missing
+// Kyverno CRDs and network failures.
+func classifyError(err error) toolError {
+	e := toolError{Code: codeInternal, Message: err.Error()}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errNoPolicyReportCRD):
+		e.Code, e.Hint = codeKyvernoNotInstalled, kyvernoHelmInstructions()
+	case errors.Is(err, context.DeadlineExceeded) || apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err):
+		e.Code, e.Retryable = codeTimeout, true
+		e.Hint = "retry, or narrow the call down, e.g. to fewer namespaces or policies"
+	case apierrors.IsUnauthorized(err):
+		e.Code, e.Hint = codeUnauthenticated, "refresh the credentials of the current kubeconfig context"
+	case apierrors.IsForbidden(err) || forbiddenPattern.MatchString(err.Error()):
+		e.Code, e.Hint = codePermissionDenied, "grant the server's service account or user the RBAC permissions named in the message, or narrow the call to namespaces it can read"
+		if p, ok := parseForbidden(err); ok {
+			e.Message = p.message() + ": " + err.Error()
+			e.Permission = &p
+			if manifest, err := p.manifest(); err == nil {
+				e.Manifest = manifest
+				e.Hint = "have a cluster administrator apply manifest, which grants only the missing permission, or narrow the call to namespaces the user can read"
+			}
+		}
+	case apierrors.IsNotFound(err) || errors.Is(err, fs.ErrNotExist):
+		e.Code = codeNotFound
+	case apierrors.IsBadRequest(err) || apierrors.IsInvalid(err):
+		e.Code = codeInvalidArgument
+	case apierrors.IsTooManyRequests(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err):
+		e.Code, e.Retryable, e.Hint = codeUnavailable, true, "the API server is overloaded or restarting; retry later"
+	case errors.As(err, &netErr):
+		e.Code, e.Retryable = codeUnavailable, true
+		e.Hint = "check that the cluster of the current kubeconfig context is reachable, or switch context with switch_context"
+	}
+	return e
+}
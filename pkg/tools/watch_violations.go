@@ -0,0 +1,389 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nirmata/kyverno-mcp/pkg/common"
+
+	policyreportv1alpha2 "github.com/kyverno/kyverno/api/policyreport/v1alpha2"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultWatchInterval is how often a watch reports the changes of the policy reports by
+	// default.
+	defaultWatchInterval = time.Minute
+	// minWatchInterval bounds the rate of the notifications of a watch.
+	minWatchInterval = 10 * time.Second
+	// maxWatchesPerSession bounds the number of watches a single session can run.
+	maxWatchesPerSession = 10
+	// maxWatchLifetime bounds how long a watch runs, so that watches forgotten by long-lived
+	// sessions do not keep their API server watch open forever.
+	maxWatchLifetime = 24 * time.Hour
+)
+
+// trackedViolation is a failing policy report result followed by a watch.
+type trackedViolation struct {
+	Policy   string `json:"policy"`
+	Rule     string `json:"rule,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	Result   string `json:"result"`
+	Message  string `json:"message,omitempty"`
+}
+
+// key identifies a violation across polls; the message may change without the violation being
+// resolved.
+func (v trackedViolation) key() string {
+	return strings.Join([]string{v.Policy, v.Rule, v.Resource}, "|")
+}
+
+// violationWatch follows the policy reports of a namespace through an informer and
+// periodically tells the client of the session that started it about new and resolved
+// violations.
+type violationWatch struct {
+	ID        string    `json:"watchId"`
+	Namespace string    `json:"namespace"`
+	Policy    string    `json:"policy,omitempty"`
+	Interval  string    `json:"interval"`
+	Started   time.Time `json:"started"`
+	Expires   time.Time `json:"expires"`
+
+	sessionID string
+	cancel    context.CancelFunc
+}
+
+// violationWatches holds the running watches of all sessions.
+type violationWatches struct {
+	mu      sync.Mutex
+	next    int
+	watches map[string]*violationWatch
+}
+
+var activeWatches = &violationWatches{watches: map[string]*violationWatch{}}
+
+// add registers a watch under a new ID. It reports false, without registering it, when the
+// session of the watch already runs maxWatchesPerSession watches.
+func (w *violationWatches) add(watch *violationWatch) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	running := 0
+	for _, other := range w.watches {
+		if other.sessionID == watch.sessionID {
+			running++
+		}
+	}
+	if running >= maxWatchesPerSession {
+		return false
+	}
+	w.next++
+	watch.ID = "watch-" + strconv.Itoa(w.next)
+	w.watches[watch.ID] = watch
+	return true
+}
+
+// stop cancels the watches of a session, either the one with the given ID or all of them when
+// id is empty, and returns the stopped watches.
+func (w *violationWatches) stop(sessionID, id string) []*violationWatch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var stopped []*violationWatch
+	for watchID, watch := range w.watches {
+		if watch.sessionID != sessionID || (id != "" && watchID != id) {
+			continue
+		}
+		watch.cancel()
+		delete(w.watches, watchID)
+		stopped = append(stopped, watch)
+	}
+	return stopped
+}
+
+// list returns the watches of a session, in the order they were started.
+func (w *violationWatches) list(sessionID string) []*violationWatch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	watches := []*violationWatch{}
+	for _, watch := range w.watches {
+		if watch.sessionID == sessionID {
+			watches = append(watches, watch)
+		}
+	}
+	sort.Slice(watches, func(i, j int) bool { return watches[i].Started.Before(watches[j].Started) })
+	return watches
+}
+
+// StopViolationWatches stops the watches of a session when it ends. It is registered as an
+// OnUnregisterSession hook.
+func StopViolationWatches(_ context.Context, session server.ClientSession) {
+	if stopped := activeWatches.stop(session.SessionID(), ""); len(stopped) > 0 {
+		klog.InfoS("stopped violation watches of closed session", "session", session.SessionID(), "count", len(stopped))
+	}
+}
+
+// WatchViolations registers the watch_violations tool with the MCP server.
+func WatchViolations(s *server.MCPServer) {
+	klog.InfoS("Registering tool: watch_violations")
+	s.AddTool(mcp.NewTool("watch_violations",
+		mcp.WithDescription(`Opt-in alerting on policy violations. action="start" watches the Kyverno PolicyReports of a namespace, optionally for a single policy, and sends MCP log notifications whenever violations appear (level warning) or are resolved (level info); set the logging level to info to receive both. Requires a transport that delivers log notifications, such as stdio or SSE. Watches run until stopped with action="stop", until the session ends or for at most 24 hours, and a session can run up to 10 watches. action="list" returns the watches of this session.`),
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			Title:           "Watch policy violations",
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(false),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		}),
+		mcp.WithString("action", mcp.Description(`start, stop or list (default: start)`), mcp.Enum("start", "stop", "list"), mcp.DefaultString("start")),
+		mcp.WithString("namespace", mcp.Description(`Namespace whose policy reports to watch (default: default)`), mcp.DefaultString(common.DefaultNamespace)),
+		mcp.WithString("policy", mcp.Description(`Only track violations of this policy, e.g. "disallow-latest-tag" (default: all policies)`)),
+		mcp.WithNumber("interval", mcp.Description(`Seconds between two notifications about the changes of the policy reports, at least 10 (default: 60)`)),
+		mcp.WithString("watchId", mcp.Description(`Watch to stop, as returned by action="start" (default: all watches of this session)`)),
+		asArgument,
+		asGroupsArgument,
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		srv := server.ServerFromContext(ctx)
+		session := server.ClientSessionFromContext(ctx)
+		if srv == nil || session == nil {
+			return toolError{Code: codeInternal, Message: "watch_violations requires a client session to send notifications to"}.result(), nil
+		}
+
+		switch action := request.GetString("action", "start"); action {
+		case "start":
+			// Sessions of transports without server-to-client messages, such as streamable HTTP,
+			// would never receive the notifications
+			if _, ok := session.(server.SessionWithLogging); !ok {
+				return toolError{Code: codeFailedPrecondition, Message: "this session cannot receive log notifications, so it cannot be notified of violations", Hint: "connect over stdio or SSE, or poll show_violations instead"}.result(), nil
+			}
+		case "stop":
+			stopped := activeWatches.stop(session.SessionID(), strings.TrimSpace(request.GetString("watchId", "")))
+			if len(stopped) == 0 {
+				return notFound(`call watch_violations with action="list" for the running watches`, "no matching watch is running in this session"), nil
+			}
+			ids := make([]string, 0, len(stopped))
+			for _, watch := range stopped {
+				ids = append(ids, watch.ID)
+			}
+			sort.Strings(ids)
+			return mcp.NewToolResultText("Stopped " + strings.Join(ids, ", ")), nil
+		case "list":
+			out, err := json.MarshalIndent(activeWatches.list(session.SessionID()), "", "  ")
+			if err != nil {
+				return errorResult(err), nil
+			}
+			return mcp.NewToolResultText(string(out)), nil
+		default:
+			return invalidArgument("invalid action %q: must be start, stop or list", action), nil
+		}
+
+		namespace := strings.TrimSpace(request.GetString("namespace", common.DefaultNamespace))
+		if namespace == "" {
+			namespace = common.DefaultNamespace
+		}
+		if namespace == common.AllNamespaces || strings.Contains(namespace, ",") {
+			return invalidArgument("watch_violations watches a single namespace; start one watch per namespace"), nil
+		}
+		if err := common.CheckNamespacesAllowed(namespace); err != nil {
+			return invalidArgument("%v", err), nil
+		}
+		if err := (common.NamespaceScope{Namespaces: []string{namespace}}).Validate(ctx); err != nil {
+			return notFound("list the namespaces of the cluster and pick an existing one", "%v", err), nil
+		}
+
+		interval := defaultWatchInterval
+		if seconds := request.GetInt("interval", 0); seconds > 0 {
+			interval = time.Duration(seconds) * time.Second
+		}
+		if interval < minWatchInterval {
+			return invalidArgument("interval must be at least %d seconds", int(minWatchInterval.Seconds())), nil
+		}
+
+		// The reports are listed once up front, so that missing permissions fail the call
+		policy := strings.TrimSpace(request.GetString("policy", ""))
+		clients, err := common.Clients(ctx)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		polrGVR, _, err := policyReportGVRs(clients.Discovery)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		items, err := listPaged(ctx, clients.Dynamic.Resource(polrGVR).Namespace(namespace), "")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		current := reportViolations(items, policy)
+
+		// The watch outlives the request, so it must not use the request context
+		started := time.Now()
+		watchCtx, cancel := context.WithDeadline(context.Background(), started.Add(maxWatchLifetime))
+		watch := &violationWatch{
+			Namespace: namespace,
+			Policy:    policy,
+			Interval:  interval.String(),
+			Started:   started,
+			Expires:   started.Add(maxWatchLifetime),
+			sessionID: session.SessionID(),
+			cancel:    cancel,
+		}
+		if !activeWatches.add(watch) {
+			cancel()
+			return toolError{Code: codeFailedPrecondition, Message: fmt.Sprintf("this session already runs %d watches", maxWatchesPerSession), Hint: `stop a watch with action="stop" before starting another one`}.result(), nil
+		}
+		informer := dynamicinformer.NewFilteredDynamicInformer(clients.Dynamic, polrGVR, namespace, 0, cache.Indexers{}, nil).Informer()
+		_ = informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+			klog.ErrorS(err, "failed to watch policy reports", "watch", watch.ID, "namespace", namespace)
+		})
+		go informer.Run(watchCtx.Done())
+		klog.InfoS("started violation watch", "watch", watch.ID, "namespace", namespace, "policy", policy, "interval", interval)
+		go watch.run(watchCtx, srv, session, interval, informer, current)
+
+		out, err := json.MarshalIndent(map[string]any{
+			"watchId":           watch.ID,
+			"namespace":         namespace,
+			"policy":            policy,
+			"interval":          watch.Interval,
+			"expires":           watch.Expires,
+			"currentViolations": len(current),
+		}, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	})
+}
+
+// run compares the policy reports held by informer every interval until the watch is stopped or
+// expires, notifying the client of the changes since the previous comparison. The watch stops
+// itself once its session is gone.
+func (w *violationWatch) run(ctx context.Context, srv *server.MCPServer, session server.ClientSession, interval time.Duration, informer cache.SharedIndexInformer, previous map[string]trackedViolation) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				activeWatches.stop(w.sessionID, w.ID)
+				klog.InfoS("violation watch expired", "watch", w.ID)
+				_ = sessionLog(srv, session, mcp.LoggingLevelInfo, fmt.Sprintf("watch %s of namespace %s expired after %s", w.ID, w.Namespace, maxWatchLifetime), "watchId", w.ID, "change", "expired")
+			}
+			return
+		case <-ticker.C:
+		}
+		// Until the informer has synced, e.g. while it cannot watch the reports, there is
+		// nothing to compare
+		if !informer.HasSynced() {
+			continue
+		}
+
+		var reports []unstructured.Unstructured
+		for _, obj := range informer.GetStore().List() {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				reports = append(reports, *u)
+			}
+		}
+		current := reportViolations(reports, w.Policy)
+
+		added, resolved := diffViolations(previous, current)
+		previous = current
+		if err := w.notify(srv, session, mcp.LoggingLevelWarning, "new", added); err != nil {
+			klog.InfoS("stopping violation watch", "watch", w.ID, "reason", err)
+			activeWatches.stop(w.sessionID, w.ID)
+			return
+		}
+		if err := w.notify(srv, session, mcp.LoggingLevelInfo, "resolved", resolved); err != nil {
+			klog.InfoS("stopping violation watch", "watch", w.ID, "reason", err)
+			activeWatches.stop(w.sessionID, w.ID)
+			return
+		}
+	}
+}
+
+// notify sends a single log notification summarizing changed violations, if there are any.
+func (w *violationWatch) notify(srv *server.MCPServer, session server.ClientSession, level mcp.LoggingLevel, change string, violations []trackedViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	subject := "namespace " + w.Namespace
+	if w.Policy != "" {
+		subject = fmt.Sprintf("policy %s in namespace %s", w.Policy, w.Namespace)
+	}
+	message := fmt.Sprintf("%d %s violation(s) of %s", len(violations), change, subject)
+	return sessionLog(srv, session, level, message, "watchId", w.ID, "change", change, "violations", violations)
+}
+
+// diffViolations returns the violations only present in current, and those only present in
+// previous, each ordered by policy, rule and resource.
+func diffViolations(previous, current map[string]trackedViolation) (added, resolved []trackedViolation) {
+	for key, v := range current {
+		if _, ok := previous[key]; !ok {
+			added = append(added, v)
+		}
+	}
+	for key, v := range previous {
+		if _, ok := current[key]; !ok {
+			resolved = append(resolved, v)
+		}
+	}
+	byKey := func(s []trackedViolation) {
+		sort.Slice(s, func(i, j int) bool { return s[i].key() < s[j].key() })
+	}
+	byKey(added)
+	byKey(resolved)
+	return added, resolved
+}
+
+// reportViolations returns the failing and erroring results of policy reports, optionally
+// restricted to a single policy, indexed by trackedViolation.key.
+func reportViolations(items []unstructured.Unstructured, policy string) map[string]trackedViolation {
+	violations := map[string]trackedViolation{}
+	for _, u := range items {
+		pr, _, err := decodePolicyReport(u)
+		if err != nil {
+			klog.ErrorS(err, "failed to convert to PolicyReport", "name", u.GetName(), "namespace", u.GetNamespace())
+			continue
+		}
+		for _, result := range pr.Results {
+			if result.Result != policyreportv1alpha2.StatusFail && result.Result != policyreportv1alpha2.StatusError {
+				continue
+			}
+			if policy != "" && result.Policy != policy {
+				continue
+			}
+			// Per-resource reports record the subject in the report scope rather than in each result
+			subjects := result.Resources
+			if len(subjects) == 0 && pr.Scope != nil {
+				subjects = []corev1.ObjectReference{*pr.Scope}
+			}
+			if len(subjects) == 0 {
+				subjects = []corev1.ObjectReference{{}}
+			}
+			for _, subject := range subjects {
+				v := trackedViolation{
+					Policy:  result.Policy,
+					Rule:    result.Rule,
+					Result:  string(result.Result),
+					Message: result.Message,
+				}
+				if subject.Kind != "" {
+					v.Resource = resourceIdentifier(subject)
+				}
+				violations[v.key()] = v
+			}
+		}
+	}
+	return violations
+}
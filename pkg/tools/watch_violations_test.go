@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestViolationWatches(t *testing.T) {
+	tests := []struct {
+		name         string
+		running      int
+		otherRunning int
+		wantAdded    bool
+	}{
+		{name: "first watch", wantAdded: true},
+		{name: "below the limit", running: maxWatchesPerSession - 1, wantAdded: true},
+		{name: "at the limit", running: maxWatchesPerSession},
+		{name: "other sessions at the limit", otherRunning: maxWatchesPerSession, wantAdded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &violationWatches{watches: map[string]*violationWatch{}}
+			start := time.Now()
+			watch := func(session string, i int) *violationWatch {
+				return &violationWatch{sessionID: session, Started: start.Add(time.Duration(i) * time.Second), cancel: func() {}}
+			}
+			for i := range tt.running {
+				w.add(watch("a", i))
+			}
+			for i := range tt.otherRunning {
+				w.add(watch("b", i))
+			}
+
+			added := watch("a", tt.running)
+			if got := w.add(added); got != tt.wantAdded {
+				t.Fatalf("add() = %v, want %v", got, tt.wantAdded)
+			}
+			listed := w.list("a")
+			if want := min(tt.running+1, maxWatchesPerSession); len(listed) != want {
+				t.Fatalf("list() returned %d watches, want %d", len(listed), want)
+			}
+			if !slices.IsSortedFunc(listed, func(a, b *violationWatch) int { return a.Started.Compare(b.Started) }) {
+				t.Error("list() did not order the watches by start time")
+			}
+			if !tt.wantAdded {
+				return
+			}
+
+			if stopped := w.stop("b", added.ID); len(stopped) != 0 {
+				t.Errorf("stop() of another session stopped %d watches", len(stopped))
+			}
+			if stopped := w.stop("a", added.ID); len(stopped) != 1 || stopped[0] != added {
+				t.Errorf("stop(%q) = %v, want the added watch", added.ID, stopped)
+			}
+			if stopped := w.stop("a", ""); len(stopped) != tt.running {
+				t.Errorf("stop() of every watch stopped %d, want %d", len(stopped), tt.running)
+			}
+			if n := len(w.list("b")); n != tt.otherRunning {
+				t.Errorf("other session runs %d watches, want %d", n, tt.otherRunning)
+			}
+		})
+	}
+}
+
+func TestDiffViolations(t *testing.T) {
+	violation := func(resource, result string) trackedViolation {
+		return trackedViolation{Policy: "require-labels", Rule: "check-app", Resource: resource, Result: result}
+	}
+	index := func(violations ...trackedViolation) map[string]trackedViolation {
+		m := map[string]trackedViolation{}
+		for _, v := range violations {
+			m[v.key()] = v
+		}
+		return m
+	}
+	tests := []struct {
+		name         string
+		previous     map[string]trackedViolation
+		current      map[string]trackedViolation
+		wantAdded    []trackedViolation
+		wantResolved []trackedViolation
+	}{
+		{name: "no violations"},
+		{name: "unchanged", previous: index(violation("Pod/team-a/web", "fail")), current: index(violation("Pod/team-a/web", "fail"))},
+		{name: "new violations", current: index(violation("Pod/team-a/web", "fail"), violation("Pod/team-a/api", "fail")), wantAdded: []trackedViolation{violation("Pod/team-a/api", "fail"), violation("Pod/team-a/web", "fail")}},
+		{name: "resolved violation", previous: index(violation("Pod/team-a/web", "fail")), current: index(), wantResolved: []trackedViolation{violation("Pod/team-a/web", "fail")}},
+		{
+			name:         "added and resolved",
+			previous:     index(violation("Pod/team-a/web", "fail"), violation("Pod/team-a/db", "fail")),
+			current:      index(violation("Pod/team-a/web", "fail"), violation("Pod/team-a/api", "error")),
+			wantAdded:    []trackedViolation{violation("Pod/team-a/api", "error")},
+			wantResolved: []trackedViolation{violation("Pod/team-a/db", "fail")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, resolved := diffViolations(tt.previous, tt.current)
+			if !slices.Equal(added, tt.wantAdded) {
+				t.Errorf("diffViolations() added = %v, want %v", added, tt.wantAdded)
+			}
+			if !slices.Equal(resolved, tt.wantResolved) {
+				t.Errorf("diffViolations() resolved = %v, want %v", resolved, tt.wantResolved)
+			}
+		})
+	}
+}
+
+func TestReportViolations(t *testing.T) {
+	report := func(scope map[string]any, results ...map[string]any) unstructured.Unstructured {
+		items := make([]any, 0, len(results))
+		for _, r := range results {
+			items = append(items, r)
+		}
+		u := unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "wgpolicyk8s.io/v1alpha2",
+			"kind":       "PolicyReport",
+			"metadata":   map[string]any{"name": "report", "namespace": "team-a"},
+			"results":    items,
+		}}
+		if scope != nil {
+			u.Object["scope"] = scope
+		}
+		return u
+	}
+	pod := func(name string) map[string]any {
+		return map[string]any{"apiVersion": "v1", "kind": "Pod", "namespace": "team-a", "name": name}
+	}
+	result := func(policy, status string, resources ...map[string]any) map[string]any {
+		r := map[string]any{"policy": policy, "rule": "check", "result": status}
+		if len(resources) > 0 {
+			subjects := make([]any, 0, len(resources))
+			for _, res := range resources {
+				subjects = append(subjects, res)
+			}
+			r["resources"] = subjects
+		}
+		return r
+	}
+	tests := []struct {
+		name   string
+		items  []unstructured.Unstructured
+		policy string
+		want   []string
+	}{
+		{name: "no reports"},
+		{
+			name:  "failing and erroring results",
+			items: []unstructured.Unstructured{report(nil, result("require-labels", "fail", pod("web")), result("require-labels", "pass", pod("api")), result("disallow-latest", "error", pod("api")), result("require-probes", "warn", pod("web")))},
+			want:  []string{"disallow-latest|check|Pod/team-a/api", "require-labels|check|Pod/team-a/web"},
+		},
+		{
+			name:   "single policy",
+			items:  []unstructured.Unstructured{report(nil, result("require-labels", "fail", pod("web")), result("disallow-latest", "fail", pod("api")))},
+			policy: "require-labels",
+			want:   []string{"require-labels|check|Pod/team-a/web"},
+		},
+		{
+			name:  "several subjects",
+			items: []unstructured.Unstructured{report(nil, result("require-labels", "fail", pod("web"), pod("api")))},
+			want:  []string{"require-labels|check|Pod/team-a/api", "require-labels|check|Pod/team-a/web"},
+		},
+		{
+			name:  "per-resource report",
+			items: []unstructured.Unstructured{report(pod("web"), result("require-labels", "fail"))},
+			want:  []string{"require-labels|check|Pod/team-a/web"},
+		},
+		{
+			name:  "no subject",
+			items: []unstructured.Unstructured{report(nil, result("require-labels", "fail"))},
+			want:  []string{"require-labels|check|"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Sorted(maps.Keys(reportViolations(tt.items, tt.policy)))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("reportViolations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
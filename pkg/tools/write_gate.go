@@ -0,0 +1,24 @@
+// Package tools provides tools for the MCP server.
+package tools
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/klog/v2"
+)
+
+// AllowWrites enables the tools that modify the cluster or the kubeconfig. It is set from the
+// --allow-writes flag.
+var AllowWrites bool
+
+// addWriteTool registers a tool that modifies the cluster or the kubeconfig. Such tools must be
+// registered through it rather than with AddTool, so that none of them is exposed unless the
+// server was started with --allow-writes. The tool is annotated as not read-only.
+func addWriteTool(s *server.MCPServer, tool mcp.Tool, handler server.ToolHandlerFunc) {
+	if !AllowWrites {
+		klog.InfoS("Not registering tool, it modifies cluster or kubeconfig state and --allow-writes is not set", "tool", tool.Name)
+		return
+	}
+	tool.Annotations.ReadOnlyHint = mcp.ToBoolPtr(false)
+	s.AddTool(tool, handler)
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// listedTools returns the tools a server lists to its clients, by name.
+func listedTools(t *testing.T, s *server.MCPServer) map[string]mcp.Tool {
+	t.Helper()
+	response := s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Result mcp.ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("tools/list response %s: %v", data, err)
+	}
+	tools := map[string]mcp.Tool{}
+	for _, tool := range list.Result.Tools {
+		tools[tool.Name] = tool
+	}
+	return tools
+}
+
+func TestWriteGate(t *testing.T) {
+	noop := func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	tests := []struct {
+		name        string
+		allowWrites bool
+		register    func(*server.MCPServer)
+		tool        string
+		wantListed  bool
+	}{
+		{
+			name:     "write tool without --allow-writes",
+			register: func(s *server.MCPServer) { addWriteTool(s, mcp.NewTool("delete_policy"), noop) },
+			tool:     "delete_policy",
+		},
+		{
+			name:        "write tool with --allow-writes",
+			allowWrites: true,
+			register:    func(s *server.MCPServer) { addWriteTool(s, mcp.NewTool("delete_policy"), noop) },
+			tool:        "delete_policy",
+			wantListed:  true,
+		},
+		{
+			name: "write tool annotated as read-only",
+			register: func(s *server.MCPServer) {
+				addWriteTool(s, mcp.NewTool("delete_policy", mcp.WithReadOnlyHintAnnotation(true)), noop)
+			},
+			allowWrites: true,
+			tool:        "delete_policy",
+			wantListed:  true,
+		},
+		{name: "switch_context without --allow-writes", register: SwitchContext, tool: "switch_context"},
+		{name: "switch_context with --allow-writes", allowWrites: true, register: SwitchContext, tool: "switch_context", wantListed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := AllowWrites
+			t.Cleanup(func() { AllowWrites = previous })
+			AllowWrites = tt.allowWrites
+
+			s := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(false))
+			tt.register(s)
+			tool, listed := listedTools(t, s)[tt.tool]
+			if listed != tt.wantListed {
+				t.Fatalf("tool %s listed = %v, want %v", tt.tool, listed, tt.wantListed)
+			}
+			// Registered write tools are never annotated as read-only
+			if listed && (tool.Annotations.ReadOnlyHint == nil || *tool.Annotations.ReadOnlyHint) {
+				t.Errorf("tool %s readOnlyHint = %v, want false", tt.tool, tool.Annotations.ReadOnlyHint)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package tracing sets up OpenTelemetry tracing of tool calls, from the MCP request down to the
+// Kubernetes API requests and Kyverno engine runs they cause.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by kyverno-mcp.
+const tracerName = "github.com/nirmata/kyverno-mcp"
+
+// Setup exports spans over OTLP/gRPC to endpoint, a URL such as "http://otel-collector:4317";
+// plain http endpoints are used without TLS. The returned function flushes pending spans and
+// must be called before the process exits. Until Setup is called, spans are not recorded.
+func Setup(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", "kyverno-mcp")))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if not nil, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport wraps the transport of Kubernetes clients so that every API request gets a span.
+// It matches the signature of rest.Config.Wrap.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt)
+}
+
+// ToolMiddleware starts a root span for every tool call, under which the spans of the call are
+// recorded.
+func ToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, span := Start(ctx, "tools/call "+request.Params.Name, attribute.String("mcp.tool.name", request.Params.Name))
+		result, err := next(ctx, request)
+		if err == nil && result != nil && result.IsError {
+			span.SetStatus(codes.Error, "tool returned an error")
+		}
+		End(span, err)
+		return result, err
+	}
+}